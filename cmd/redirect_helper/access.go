@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/storage"
+)
+
+// setLinkPasswordCmd 从标准输入读取路径条目的访问密码，避免密码出现在命令行历史中
+func setLinkPasswordCmd(name string, store *storage.ConfigStorage) {
+	fmt.Fprintf(os.Stderr, "Password for '%s': ", name)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		log.Fatal("Password must not be empty. Use -remove-link-password to remove it")
+	}
+
+	if err := store.SetForwardingAccess(nil, name, config.AccessChange{Password: &password}); err != nil {
+		log.Fatalf("Failed to set password: %v", err)
+	}
+
+	fmt.Printf("Forwarding '%s' now requires a password\n", name)
+}
+
+// removeLinkPasswordCmd 取消路径条目的访问密码
+func removeLinkPasswordCmd(name string, store *storage.ConfigStorage) {
+	empty := ""
+	if err := store.SetForwardingAccess(nil, name, config.AccessChange{Password: &empty}); err != nil {
+		log.Fatalf("Failed to remove password: %v", err)
+	}
+
+	fmt.Printf("Password of '%s' removed\n", name)
+}
+
+// setMaxUsesCmd 设置路径条目的使用次数上限并清零计数，0 表示不限制
+func setMaxUsesCmd(name string, maxUses int, store *storage.ConfigStorage) {
+	if err := store.SetForwardingAccess(nil, name, config.AccessChange{MaxUses: &maxUses}); err != nil {
+		log.Fatalf("Failed to set max uses: %v", err)
+	}
+
+	if maxUses == 0 {
+		fmt.Printf("Forwarding '%s' can now be used without limit\n", name)
+		return
+	}
+	fmt.Printf("Forwarding '%s' now stops working after %d redirect(s)\n", name, maxUses)
+}
+
+func formatAccess(f *models.ForwardingEntry) string {
+	result := ""
+	if f.HasPassword {
+		result += ", Password: yes"
+	}
+	if f.MaxUses > 0 {
+		result += fmt.Sprintf(", Uses: %d/%d", f.Uses, f.MaxUses)
+	}
+	return result
+}
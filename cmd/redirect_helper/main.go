@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"redirect_helper/internal/config"
 	"redirect_helper/internal/server"
 	"redirect_helper/internal/storage"
+	"redirect_helper/internal/webhook"
 	"redirect_helper/pkg/utils"
 )
 
@@ -23,14 +30,80 @@ func main() {
 		configFile   = flag.String("config", "", "Configuration file path (default: ./redirect_helper.json)")
 
 		// Domain management flags
-		listDomains  = flag.Bool("list-domains", false, "List all domain mappings")
-		removeDomain = flag.String("remove-domain", "", "Remove a domain mapping")
-		updateDomain = flag.String("update-domain", "", "Update/create target for a domain mapping")
+		listDomains   = flag.Bool("list-domains", false, "List all domain mappings")
+		removeDomain  = flag.String("remove-domain", "", "Remove a domain mapping")
+		updateDomain  = flag.String("update-domain", "", "Update/create target for a domain mapping")
+		approveDomain = flag.String("approve-domain", "", "Mark a domain mapping as verified without checking ownership")
 
 		// Token management flags
 		resetAdminToken    = flag.Bool("reset-admin-token", false, "Reset admin token for API authentication")
 		resetRedirectToken = flag.Bool("reset-redirect-token", false, "Reset redirect token for path redirects")
 		resetDomainToken   = flag.Bool("reset-domain-token", false, "Reset domain token for domain redirects")
+		rotateToken        = flag.String("rotate-token", "", "Rotate the admin, redirect or domain token, keeping the old one valid for -grace")
+		grace              = flag.Duration("grace", config.DefaultTokenGracePeriod, "How long the old token stays valid after -rotate-token")
+
+		// Relay management flags
+		setRelay            = flag.String("set-relay", "", "Relay TCP (and optionally UDP) connections on a local port to the host:port target of a forwarding name")
+		removeRelay         = flag.String("remove-relay", "", "Stop relaying connections for a forwarding name")
+		relayListen         = flag.String("relay-listen", "", "Listen address for -set-relay, e.g. :2222")
+		relayUDP            = flag.Bool("relay-udp", false, "Also relay UDP with -set-relay")
+		relayMaxConnections = flag.Int("relay-max-connections", 0, "Concurrent connection limit for -set-relay (0 = global default)")
+		relayIdleTimeout    = flag.Int("relay-idle-timeout", 0, "Idle timeout in seconds for -set-relay (0 = global default)")
+
+		// Wake-on-LAN flags
+		setWake          = flag.String("set-wake", "", "Send a Wake-on-LAN packet and wait for the target before redirecting a forwarding name")
+		setDomainWake    = flag.String("set-domain-wake", "", "Send a Wake-on-LAN packet and wait for the target before redirecting a domain mapping")
+		removeWake       = flag.String("remove-wake", "", "Remove Wake-on-LAN from a forwarding name")
+		removeDomainWake = flag.String("remove-domain-wake", "", "Remove Wake-on-LAN from a domain mapping")
+		wakeNow          = flag.String("wake", "", "Send a Wake-on-LAN packet for a forwarding name or domain mapping now")
+		wakeMAC          = flag.String("wake-mac", "", "MAC address for -set-wake/-set-domain-wake, e.g. 00:11:22:33:44:55")
+		wakeBroadcast    = flag.String("wake-broadcast", "", "Broadcast address for the magic packet, e.g. 192.168.1.255 (default 255.255.255.255:9)")
+		wakeTimeout      = flag.Int("wake-timeout", 0, "Seconds to wait for the target to come up (0 = 120)")
+
+		// Preview page flags
+		setPreview       = flag.String("set-preview", "", "Show a preview page with the destination instead of redirecting a forwarding name directly")
+		removePreview    = flag.String("remove-preview", "", "Redirect a forwarding name directly again")
+		previewMessage   = flag.String("preview-message", "", "Message shown on the preview page with -set-preview")
+		previewCountdown = flag.Int("preview-countdown", 0, "Seconds before continuing automatically with -set-preview (0 = wait for a click)")
+
+		// Link access flags
+		setLinkPassword    = flag.String("set-link-password", "", "Require a password (read from stdin) before redirecting a forwarding name")
+		removeLinkPassword = flag.String("remove-link-password", "", "Stop requiring a password for a forwarding name")
+		setMaxUses         = flag.String("set-max-uses", "", "Limit how many times a forwarding name redirects before returning 410 (use with -max-uses)")
+		maxUses            = flag.Int("max-uses", 0, "Number of redirects allowed with -set-max-uses (0 = unlimited); resets the use count")
+
+		// Routing rule flags
+		setRoutes       = flag.String("set-routes", "", "Replace the routing rules of a forwarding name with -routes")
+		setDomainRoutes = flag.String("set-domain-routes", "", "Replace the routing rules of a domain mapping with -routes")
+		routes          = flag.String("routes", "", `Routing rules as a JSON array, e.g. '[{"countries":["CN"],"target":"https://mirror.example.cn"}]' (empty = remove)`)
+
+		// Path rule flags
+		setPathRules = flag.String("set-path-rules", "", "Replace the path rules of a domain mapping with -path-rules")
+		pathRules    = flag.String("path-rules", "", `Path rules as a JSON array, e.g. '[{"type":"regex","pattern":"^/blog/(\\d+)$","target":"https://new.example.com/posts/$1","status":301}]' (empty = remove)`)
+
+		// Per-entry update token flags
+		rotateUpdateToken       = flag.String("rotate-update-token", "", "Generate a new update token that can only update this forwarding name")
+		revokeUpdateToken       = flag.String("revoke-update-token", "", "Remove the update token of a forwarding name")
+		rotateDomainUpdateToken = flag.String("rotate-domain-update-token", "", "Generate a new update token that can only update this domain mapping")
+		revokeDomainUpdateToken = flag.String("revoke-domain-update-token", "", "Remove the update token of a domain mapping")
+
+		// Admin UI login flags
+		setAdminPassword = flag.Bool("set-admin-password", false, "Set the admin UI login password (read from stdin)")
+		username         = flag.String("username", "admin", "Username for -set-admin-password")
+
+		// User management flags
+		owner           = flag.String("owner", "", "Filter -list/-list-domains by owner, or assign the owner with -update/-update-domain")
+		listUsers       = flag.Bool("list-users", false, "List all users")
+		addUser         = flag.String("add-user", "", "Create a user and print its API key")
+		removeUser      = flag.String("remove-user", "", "Remove a user (its entries become admin-only)")
+		setUserPassword = flag.String("set-user-password", "", "Set the login password of a user (read from stdin)")
+		resetUserKey    = flag.String("reset-user-key", "", "Generate a new API key for a user")
+		setUserQuota    = flag.String("set-user-quota", "", "Set the quota of a user (use with -max-redirects/-max-domains)")
+		disableUser     = flag.String("disable-user", "", "Disable a user")
+		enableUser      = flag.String("enable-user", "", "Enable a disabled user")
+		userAdmin       = flag.Bool("admin", false, "Grant admin privileges with -add-user")
+		maxRedirects    = flag.Int("max-redirects", 0, "Path redirect quota for -add-user/-set-user-quota (0 = global limit)")
+		maxDomains      = flag.Int("max-domains", 0, "Domain redirect quota for -add-user/-set-user-quota (0 = global limit)")
 	)
 	flag.Parse()
 
@@ -54,8 +127,20 @@ func main() {
 
 	store := storage.NewConfigStorage(cfg)
 
+	// 命令行修改同样触发 webhook，退出前同步投递
+	if !*serverMode {
+		dispatcher := webhook.NewDispatcher(store.GetWebhooksConfig(), false)
+		if dispatcher.Enabled() {
+			store.OnChange(dispatcher.Notify)
+			if err := dispatcher.Start(); err != nil {
+				log.Fatalf("Failed to start webhooks: %v", err)
+			}
+			defer drainWebhooks(dispatcher)
+		}
+	}
+
 	if *listMode {
-		listForwardings(store)
+		listForwardings(*owner, store)
 		return
 	}
 
@@ -65,13 +150,13 @@ func main() {
 	}
 
 	if *updateName != "" {
-		updateForwarding(*updateName, *updateTarget, store)
+		updateForwarding(*updateName, *updateTarget, *owner, store)
 		return
 	}
 
 	// Domain management commands
 	if *listDomains {
-		listDomainMappings(store)
+		listDomainMappings(*owner, store)
 		return
 	}
 
@@ -80,8 +165,93 @@ func main() {
 		return
 	}
 
+	if *approveDomain != "" {
+		approveDomainCmd(*approveDomain, store)
+		return
+	}
+
+	if *setRelay != "" {
+		setRelayCmd(*setRelay, config.RelayConfig{
+			Listen:         *relayListen,
+			UDP:            *relayUDP,
+			MaxConnections: *relayMaxConnections,
+			IdleTimeout:    *relayIdleTimeout,
+		}, store)
+		return
+	}
+
+	if *removeRelay != "" {
+		removeRelayCmd(*removeRelay, store)
+		return
+	}
+
+	if *setWake != "" || *setDomainWake != "" {
+		wake := &config.WakeConfig{MAC: *wakeMAC, Broadcast: *wakeBroadcast, Timeout: *wakeTimeout}
+		if *setWake != "" {
+			setWakeCmd(*setWake, false, wake, store)
+		} else {
+			setWakeCmd(*setDomainWake, true, wake, store)
+		}
+		return
+	}
+
+	if *removeWake != "" {
+		setWakeCmd(*removeWake, false, nil, store)
+		return
+	}
+
+	if *removeDomainWake != "" {
+		setWakeCmd(*removeDomainWake, true, nil, store)
+		return
+	}
+
+	if *wakeNow != "" {
+		wakeNowCmd(*wakeNow, store)
+		return
+	}
+
+	if *setPreview != "" {
+		setPreviewCmd(*setPreview, &config.PreviewConfig{Message: *previewMessage, Countdown: *previewCountdown}, store)
+		return
+	}
+
+	if *removePreview != "" {
+		setPreviewCmd(*removePreview, nil, store)
+		return
+	}
+
+	if *setLinkPassword != "" {
+		setLinkPasswordCmd(*setLinkPassword, store)
+		return
+	}
+
+	if *removeLinkPassword != "" {
+		removeLinkPasswordCmd(*removeLinkPassword, store)
+		return
+	}
+
+	if *setMaxUses != "" {
+		setMaxUsesCmd(*setMaxUses, *maxUses, store)
+		return
+	}
+
+	if *setRoutes != "" {
+		setRoutesCmd(*setRoutes, false, *routes, store)
+		return
+	}
+
+	if *setDomainRoutes != "" {
+		setRoutesCmd(*setDomainRoutes, true, *routes, store)
+		return
+	}
+
+	if *setPathRules != "" {
+		setPathRulesCmd(*setPathRules, *pathRules, store)
+		return
+	}
+
 	if *updateDomain != "" {
-		updateDomainMapping(*updateDomain, *updateTarget, store)
+		updateDomainMapping(*updateDomain, *updateTarget, *owner, store)
 		return
 	}
 
@@ -101,8 +271,86 @@ func main() {
 		return
 	}
 
+	if *rotateToken != "" {
+		rotateTokenCmd(*rotateToken, *grace, store)
+		return
+	}
+
+	if *rotateUpdateToken != "" {
+		rotateUpdateTokenCmd(*rotateUpdateToken, false, store)
+		return
+	}
+
+	if *rotateDomainUpdateToken != "" {
+		rotateUpdateTokenCmd(*rotateDomainUpdateToken, true, store)
+		return
+	}
+
+	if *revokeUpdateToken != "" {
+		revokeUpdateTokenCmd(*revokeUpdateToken, false, store)
+		return
+	}
+
+	if *revokeDomainUpdateToken != "" {
+		revokeUpdateTokenCmd(*revokeDomainUpdateToken, true, store)
+		return
+	}
+
+	if *setAdminPassword {
+		setAdminPasswordCmd(*username, store)
+		return
+	}
+
+	// User management commands
+	if *listUsers {
+		listUsersCmd(store)
+		return
+	}
+
+	if *addUser != "" {
+		addUserCmd(*addUser, *userAdmin, *maxRedirects, *maxDomains, store)
+		return
+	}
+
+	if *removeUser != "" {
+		removeUserCmd(*removeUser, store)
+		return
+	}
+
+	if *setUserPassword != "" {
+		setUserPasswordCmd(*setUserPassword, store)
+		return
+	}
+
+	if *resetUserKey != "" {
+		resetUserKeyCmd(*resetUserKey, store)
+		return
+	}
+
+	if *setUserQuota != "" {
+		setUserQuotaCmd(*setUserQuota, *maxRedirects, *maxDomains, store)
+		return
+	}
+
+	if *disableUser != "" {
+		setUserDisabledCmd(*disableUser, true, store)
+		return
+	}
+
+	if *enableUser != "" {
+		setUserDisabledCmd(*enableUser, false, store)
+		return
+	}
+
 	if *serverMode {
-		startServer(*port, store, cfg)
+		// 只有命令行显式指定 -port（包括 -port 8001）时才覆盖配置文件中的 port 和 listen
+		portSet := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "port" {
+				portSet = true
+			}
+		})
+		startServer(*port, portSet, store, cfg)
 		return
 	}
 
@@ -110,11 +358,20 @@ func main() {
 }
 
 
-func listForwardings(store *storage.ConfigStorage) {
+func listForwardings(owner string, store *storage.ConfigStorage) {
 	forwardings, err := store.ListForwardings()
 	if err != nil {
 		log.Fatalf("Failed to list forwardings: %v", err)
 	}
+	if owner != "" {
+		filtered := forwardings[:0]
+		for _, f := range forwardings {
+			if f.Owner == owner {
+				filtered = append(filtered, f)
+			}
+		}
+		forwardings = filtered
+	}
 
 	if len(forwardings) == 0 {
 		fmt.Println("No forwardings found")
@@ -123,8 +380,8 @@ func listForwardings(store *storage.ConfigStorage) {
 
 	fmt.Println("Existing forwardings:")
 	for _, f := range forwardings {
-		fmt.Printf("Name: %s, Target: %s, Created: %s\n",
-			f.Name, f.Target, f.CreatedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Name: %s, Target: %s, Created: %s%s%s%s%s%s%s\n",
+			f.Name, f.Target, f.CreatedAt.Format("2006-01-02 15:04:05"), formatOwner(f.Owner), formatRelay(f.Relay), formatWake(f.Wake), formatPreview(f.Preview), formatAccess(f), formatRoutes(f.Routes))
 	}
 }
 
@@ -137,10 +394,13 @@ func removeForwarding(name string, store *storage.ConfigStorage) {
 	fmt.Printf("Forwarding '%s' removed successfully\n", name)
 }
 
-func updateForwarding(name, target string, store *storage.ConfigStorage) {
+func updateForwarding(name, target, owner string, store *storage.ConfigStorage) {
 	if target == "" {
 		log.Fatal("Target is required for update. Use -target flag")
 	}
+	if owner != "" && !store.HasUser(owner) {
+		log.Fatalf("User '%s' not found", owner)
+	}
 
 	// 获取redirect token
 	redirectToken := store.GetRedirectToken()
@@ -153,38 +413,96 @@ func updateForwarding(name, target string, store *storage.ConfigStorage) {
 		log.Fatalf("Failed to update/create forwarding: %v", err)
 	}
 
+	if owner != "" {
+		if err := store.SetForwardingOwner(name, owner); err != nil {
+			log.Fatalf("Failed to set owner: %v", err)
+		}
+	}
+
 	fmt.Printf("Forwarding '%s' updated/created successfully with target: %s\n", name, target)
 }
 
-func startServer(port string, store *storage.ConfigStorage, cfg *config.Config) {
-	// 使用配置文件中的端口，如果命令行没有指定非默认端口的话
+func startServer(port string, portSet bool, store *storage.ConfigStorage, cfg *config.Config) {
+	// 使用配置文件中的端口，如果命令行没有指定端口的话
 	actualPort := port
-	if port == "8001" && cfg.Server != nil && cfg.Server.Port != "" {
+	if !portSet && cfg.Server != nil && cfg.Server.Port != "" {
 		actualPort = cfg.Server.Port
 	}
 
+	// 配置了 listen 地址（如 Unix socket）且命令行未指定端口时使用该地址
+	listenAddr := ":" + actualPort
+	if !portSet && cfg.Server != nil && cfg.Server.Listen != "" {
+		listenAddr = cfg.Server.Listen
+	}
+
 	// 显示当前配置信息
 	displayServerConfig(cfg, actualPort)
 
 	srv := server.NewServer(store)
-	fmt.Printf("🚀 Starting server on port %s...\n", actualPort)
+	fmt.Printf("🚀 Starting server on %s...\n", listenAddr)
+
+	// 收到 SIGINT/SIGTERM 时优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// 启动服务器（阻塞运行）
-	fmt.Printf("Server started on port %s\n", actualPort)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(listenAddr)
+	}()
+
+	fmt.Printf("Server started on %s\n", listenAddr)
 	fmt.Printf("Press Ctrl+C to stop server\n\n")
-	
-	if err := srv.Start(":" + actualPort); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+		return
+	case <-ctx.Done():
 	}
+
+	stop()
+	fmt.Printf("\n🛑 Shutting down server, draining connections...\n")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), srv.ShutdownTimeout())
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("Server shutdown failed: %v", err)
+	}
+	<-errCh
+
+	fmt.Printf("Server stopped\n")
+}
+
+// drainWebhooks 等待命令触发的 webhook 投递完成，超时后放弃剩余投递
+func drainWebhooks(dispatcher *webhook.Dispatcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if pending := dispatcher.Drain(ctx); pending > 0 {
+		fmt.Printf("⚠️  %d webhook deliveries could not be completed\n", pending)
+	}
+	dispatcher.Close(ctx)
 }
 
 // Domain management functions
 
-func listDomainMappings(store *storage.ConfigStorage) {
+func listDomainMappings(owner string, store *storage.ConfigStorage) {
 	domains, err := store.ListDomains()
 	if err != nil {
 		log.Fatalf("Failed to list domain mappings: %v", err)
 	}
+	if owner != "" {
+		filtered := domains[:0]
+		for _, d := range domains {
+			if d.Owner == owner {
+				filtered = append(filtered, d)
+			}
+		}
+		domains = filtered
+	}
 
 	if len(domains) == 0 {
 		fmt.Println("No domain mappings found")
@@ -193,8 +511,8 @@ func listDomainMappings(store *storage.ConfigStorage) {
 
 	fmt.Println("Existing domain mappings:")
 	for _, d := range domains {
-		fmt.Printf("Domain: %s, Target: %s, Created: %s\n",
-			d.Domain, d.Target, d.CreatedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Domain: %s, Target: %s, Created: %s%s%s%s%s%s\n",
+			d.Domain, d.Target, d.CreatedAt.Format("2006-01-02 15:04:05"), formatOwner(d.Owner), formatVerification(d.Verification), formatWake(d.Wake), formatRoutes(d.Routes), formatPathRules(d.PathRules))
 	}
 }
 
@@ -207,10 +525,13 @@ func removeDomainMapping(domain string, store *storage.ConfigStorage) {
 	fmt.Printf("Domain mapping '%s' removed successfully\n", domain)
 }
 
-func updateDomainMapping(domain, target string, store *storage.ConfigStorage) {
+func updateDomainMapping(domain, target, owner string, store *storage.ConfigStorage) {
 	if target == "" {
 		log.Fatal("Target is required for update. Use -target flag")
 	}
+	if owner != "" && !store.HasUser(owner) {
+		log.Fatalf("User '%s' not found", owner)
+	}
 
 	// 获取domain token
 	domainToken := store.GetDomainToken()
@@ -223,7 +544,14 @@ func updateDomainMapping(domain, target string, store *storage.ConfigStorage) {
 		log.Fatalf("Failed to update/create domain mapping: %v", err)
 	}
 
+	if owner != "" {
+		if err := store.SetDomainOwner(domain, owner); err != nil {
+			log.Fatalf("Failed to set owner: %v", err)
+		}
+	}
+
 	fmt.Printf("Domain mapping '%s' updated/created successfully with target: %s\n", domain, target)
+	printVerificationChallenge(domain, store)
 }
 
 // Token management functions
@@ -272,6 +600,68 @@ func resetDomainTokenCmd(store *storage.ConfigStorage) {
 	fmt.Printf("New domain token: %s\n", token)
 }
 
+// rotateTokenCmd 轮换全局 token，旧 token 在宽限期内继续有效，给设备留出更新时间
+func rotateTokenCmd(kind string, grace time.Duration, store *storage.ConfigStorage) {
+	token, expiresAt, err := store.RotateToken(kind, grace)
+	if err != nil {
+		log.Fatalf("Failed to rotate %s token: %v", kind, err)
+	}
+
+	fmt.Printf("%s token rotated successfully\n", strings.ToUpper(kind[:1])+kind[1:])
+	fmt.Printf("New %s token: %s\n", kind, token)
+	if !expiresAt.IsZero() {
+		fmt.Printf("💡 The old %s token stays valid until %s\n", kind, expiresAt.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+// rotateUpdateTokenCmd 为单个条目生成更新 token，分发给只负责更新这一个名称的设备
+func rotateUpdateTokenCmd(name string, domain bool, store *storage.ConfigStorage) {
+	var token string
+	var err error
+	if domain {
+		token, err = store.RotateDomainUpdateToken(nil, name)
+	} else {
+		token, err = store.RotateForwardingUpdateToken(nil, name)
+	}
+	if err != nil {
+		log.Fatalf("Failed to rotate update token: %v", err)
+	}
+
+	fmt.Printf("Update token for '%s' rotated successfully\n", name)
+	fmt.Printf("New update token: %s\n", token)
+	fmt.Printf("💡 The token is only shown once. It can only update '%s'\n", name)
+}
+
+func revokeUpdateTokenCmd(name string, domain bool, store *storage.ConfigStorage) {
+	var err error
+	if domain {
+		err = store.RevokeDomainUpdateToken(nil, name)
+	} else {
+		err = store.RevokeForwardingUpdateToken(nil, name)
+	}
+	if err != nil {
+		log.Fatalf("Failed to revoke update token: %v", err)
+	}
+
+	fmt.Printf("Update token for '%s' revoked successfully\n", name)
+}
+
+// setAdminPasswordCmd 从标准输入读取密码，避免密码出现在命令行历史中
+func setAdminPasswordCmd(username string, store *storage.ConfigStorage) {
+	fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	if err := store.SetAdminPassword(username, password); err != nil {
+		log.Fatalf("Failed to set admin password: %v", err)
+	}
+
+	fmt.Printf("Admin password for '%s' set successfully\n", username)
+}
+
 
 // displayServerConfig shows current configuration when starting server
 func displayServerConfig(cfg *config.Config, port string) {
@@ -283,6 +673,56 @@ func displayServerConfig(cfg *config.Config, port string) {
 	fmt.Printf("🌐 Server Port: %s\n", port)
 	fmt.Printf("📁 Config File: %s\n", config.GetConfigPath())
 	
+	if cfg.Server != nil && cfg.Server.AdminListen != "" {
+		fmt.Printf("🔐 Admin Listener: %s\n", cfg.Server.AdminListen)
+	}
+	if cfg.Server != nil && cfg.Server.BasePath != "" {
+		fmt.Printf("📂 Base Path: %s\n", cfg.Server.BasePath)
+	}
+	if cfg.Server != nil && cfg.Server.DNS != nil && cfg.Server.DNS.Enabled {
+		fmt.Printf("🧭 DNS Server: %s → %s\n", cfg.Server.DNS.Listen, strings.Join(cfg.Server.DNS.PublicIPs, ", "))
+	}
+	if cfg.Server != nil && cfg.Server.SNI != nil && cfg.Server.SNI.Enabled {
+		listen := cfg.Server.SNI.Listen
+		if listen == "" {
+			listen = ":443"
+		}
+		fallback := cfg.Server.SNI.Default
+		if fallback == "" {
+			fallback = "closed"
+			if tls := cfg.Server.TLS; tls != nil && tls.Enabled {
+				tlsPort := tls.Port
+				if tlsPort == "" {
+					tlsPort = "443"
+				}
+				if strings.HasSuffix(listen, ":"+tlsPort) {
+					fallback = "local TLS"
+				}
+			}
+		}
+		fmt.Printf("🔀 SNI Passthrough: %s (default: %s)\n", listen, fallback)
+	}
+	if cfg.Server != nil && cfg.Server.Relay != nil && cfg.Server.Relay.Enabled {
+		relays := 0
+		for _, f := range cfg.Forwardings {
+			if f.Relay != nil {
+				relays++
+			}
+		}
+		fmt.Printf("🔌 TCP/UDP Relay: %d port(s)\n", relays)
+	}
+	if cfg.Server != nil && cfg.Server.GeoIP != nil {
+		var databases []string
+		for _, path := range []string{cfg.Server.GeoIP.Country, cfg.Server.GeoIP.ASN} {
+			if path != "" {
+				databases = append(databases, path)
+			}
+		}
+		if len(databases) > 0 {
+			fmt.Printf("🌍 GeoIP: %s\n", strings.Join(databases, ", "))
+		}
+	}
+
 	// Limits
 	if cfg.Server != nil {
 		fmt.Printf("📊 Limits: %d redirects, %d domains\n", 
@@ -304,6 +744,9 @@ func displayServerConfig(cfg *config.Config, port string) {
 		fmt.Printf("   Admin Token:    %s\n", getTokenStatus(adminSet))
 		fmt.Printf("   Redirect Token: %s\n", getTokenStatus(redirectSet))
 		fmt.Printf("   Domain Token:   %s\n", getTokenStatus(domainSet))
+		for _, retired := range cfg.RetiredTokens() {
+			fmt.Printf("   Previous %s token valid until %s\n", retired.Kind, retired.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+		}
 	}
 	
 	// List existing entries if any
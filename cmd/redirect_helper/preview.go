@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/storage"
+)
+
+// setPreviewCmd 设置或取消（preview 为 nil）路径条目的预览页面
+func setPreviewCmd(name string, preview *config.PreviewConfig, store *storage.ConfigStorage) {
+	if err := store.SetForwardingPreview(nil, name, preview); err != nil {
+		log.Fatalf("Failed to update preview: %v", err)
+	}
+
+	if preview == nil {
+		fmt.Printf("Preview of '%s' removed\n", name)
+		return
+	}
+	fmt.Printf("Visiting '%s' now shows a preview page before redirecting\n", name)
+}
+
+func formatPreview(preview *models.Preview) string {
+	if preview == nil {
+		return ""
+	}
+	if preview.Countdown > 0 {
+		return fmt.Sprintf(", Preview: %ds", preview.Countdown)
+	}
+	return ", Preview"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/storage"
+)
+
+// setRelayCmd 为路径条目设置四层转发，服务重启后生效
+func setRelayCmd(name string, relay config.RelayConfig, store *storage.ConfigStorage) {
+	if relay.Listen == "" {
+		log.Fatal("Listen address is required. Use -relay-listen flag, e.g. -relay-listen :2222")
+	}
+	if err := store.SetRelay(name, relay); err != nil {
+		log.Fatalf("Failed to set relay: %v", err)
+	}
+
+	forwarding, err := store.GetForwarding(name)
+	if err != nil {
+		log.Fatalf("Failed to read forwarding: %v", err)
+	}
+	fmt.Printf("Forwarding '%s' now relays %s → %s\n", name, formatRelayProtocols(forwarding.Relay), forwarding.Target)
+	fmt.Printf("💡 A running server picks this up after restart; POST /api/relay applies it immediately\n")
+}
+
+// removeRelayCmd 取消路径条目的四层转发
+func removeRelayCmd(name string, store *storage.ConfigStorage) {
+	if err := store.RemoveRelay(name); err != nil {
+		log.Fatalf("Failed to remove relay: %v", err)
+	}
+
+	fmt.Printf("Relay of '%s' removed\n", name)
+}
+
+func formatRelay(relay *models.ForwardingRelay) string {
+	if relay == nil {
+		return ""
+	}
+	return ", Relay: " + formatRelayProtocols(relay)
+}
+
+func formatRelayProtocols(relay *models.ForwardingRelay) string {
+	if relay.UDP {
+		return relay.Listen + " (tcp/udp)"
+	}
+	return relay.Listen + " (tcp)"
+}
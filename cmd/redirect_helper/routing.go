@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/storage"
+)
+
+// setRoutesCmd 替换路径条目或域名映射（domain 为 true）的路由规则，rulesJSON 为空时清空规则
+func setRoutesCmd(name string, domain bool, rulesJSON string, store *storage.ConfigStorage) {
+	var rules []config.RouteRule
+	if strings.TrimSpace(rulesJSON) != "" {
+		decoder := json.NewDecoder(strings.NewReader(rulesJSON))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rules); err != nil {
+			log.Fatalf("Invalid -routes JSON: %v", err)
+		}
+	}
+
+	var err error
+	if domain {
+		err = store.SetDomainRoutes(nil, name, rules)
+	} else {
+		err = store.SetForwardingRoutes(nil, name, rules)
+	}
+	if err != nil {
+		log.Fatalf("Failed to set routes: %v", err)
+	}
+
+	if len(rules) == 0 {
+		fmt.Printf("Routes of '%s' removed\n", name)
+		return
+	}
+	fmt.Printf("'%s' now has %d routing rule(s)\n", name, len(rules))
+}
+
+func formatRoutes(rules []models.RouteRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", Routes: %d", len(rules))
+}
+
+// setPathRulesCmd 替换域名映射的路径规则，rulesJSON 为空时清空规则
+func setPathRulesCmd(domain, rulesJSON string, store *storage.ConfigStorage) {
+	var rules []config.PathRule
+	if strings.TrimSpace(rulesJSON) != "" {
+		decoder := json.NewDecoder(strings.NewReader(rulesJSON))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rules); err != nil {
+			log.Fatalf("Invalid -path-rules JSON: %v", err)
+		}
+	}
+
+	if err := store.SetDomainPathRules(nil, domain, rules); err != nil {
+		log.Fatalf("Failed to set path rules: %v", err)
+	}
+
+	if len(rules) == 0 {
+		fmt.Printf("Path rules of '%s' removed\n", domain)
+		return
+	}
+	fmt.Printf("'%s' now has %d path rule(s)\n", domain, len(rules))
+}
+
+func formatPathRules(rules []models.PathRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", Path rules: %d", len(rules))
+}
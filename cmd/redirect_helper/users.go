@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"redirect_helper/internal/storage"
+)
+
+// User management functions
+
+func listUsersCmd(store *storage.ConfigStorage) {
+	users := store.ListUsers()
+	if len(users) == 0 {
+		fmt.Println("No users found")
+		return
+	}
+
+	fmt.Println("Existing users:")
+	for _, u := range users {
+		flags := make([]string, 0, 4)
+		if u.Admin {
+			flags = append(flags, "admin")
+		}
+		if u.Disabled {
+			flags = append(flags, "disabled")
+		}
+		if u.HasPassword {
+			flags = append(flags, "password")
+		}
+		if u.HasAPIKey {
+			flags = append(flags, "api-key")
+		}
+		fmt.Printf("User: %s, Redirects: %d/%d, Domains: %d/%d, Flags: [%s], Created: %s\n",
+			u.Username, u.Forwardings, u.MaxRedirectCount, u.Domains, u.MaxDomainCount,
+			strings.Join(flags, ","), u.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func addUserCmd(username string, admin bool, maxRedirects, maxDomains int, store *storage.ConfigStorage) {
+	apiKey, err := store.AddUser(username, admin, maxRedirects, maxDomains)
+	if err != nil {
+		log.Fatalf("Failed to add user: %v", err)
+	}
+
+	fmt.Printf("User '%s' created successfully\n", username)
+	fmt.Printf("API key: %s\n", apiKey)
+	fmt.Printf("💡 The API key is only shown once. Use -set-user-password to enable UI login\n")
+}
+
+func removeUserCmd(username string, store *storage.ConfigStorage) {
+	if err := store.RemoveUser(username); err != nil {
+		log.Fatalf("Failed to remove user: %v", err)
+	}
+
+	fmt.Printf("User '%s' removed successfully\n", username)
+}
+
+// setUserPasswordCmd 从标准输入读取密码，避免密码出现在命令行历史中
+func setUserPasswordCmd(username string, store *storage.ConfigStorage) {
+	fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	if err := store.SetUserPassword(username, password); err != nil {
+		log.Fatalf("Failed to set password: %v", err)
+	}
+
+	fmt.Printf("Password for user '%s' set successfully\n", username)
+}
+
+func resetUserKeyCmd(username string, store *storage.ConfigStorage) {
+	apiKey, err := store.ResetUserAPIKey(username)
+	if err != nil {
+		log.Fatalf("Failed to reset API key: %v", err)
+	}
+
+	fmt.Printf("API key for user '%s' reset successfully\n", username)
+	fmt.Printf("New API key: %s\n", apiKey)
+}
+
+func setUserQuotaCmd(username string, maxRedirects, maxDomains int, store *storage.ConfigStorage) {
+	if err := store.SetUserQuota(username, maxRedirects, maxDomains); err != nil {
+		log.Fatalf("Failed to set quota: %v", err)
+	}
+
+	fmt.Printf("Quota for user '%s' set successfully\n", username)
+}
+
+func setUserDisabledCmd(username string, disabled bool, store *storage.ConfigStorage) {
+	if err := store.SetUserDisabled(username, disabled); err != nil {
+		log.Fatalf("Failed to update user: %v", err)
+	}
+
+	if disabled {
+		fmt.Printf("User '%s' disabled\n", username)
+	} else {
+		fmt.Printf("User '%s' enabled\n", username)
+	}
+}
+
+// formatOwner 列表输出中的所属用户
+func formatOwner(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	return ", Owner: " + owner
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/storage"
+)
+
+// approveDomainCmd 跳过所有权检查，直接让域名映射生效
+func approveDomainCmd(domain string, store *storage.ConfigStorage) {
+	if err := store.ApproveDomain(domain); err != nil {
+		log.Fatalf("Failed to approve domain: %v", err)
+	}
+
+	fmt.Printf("Domain '%s' approved\n", domain)
+}
+
+// printVerificationChallenge 提示如何发布 challenge；域名已生效时不输出
+func printVerificationChallenge(domain string, store *storage.ConfigStorage) {
+	entry, err := store.GetDomain(domain)
+	if err != nil || entry.Verification == nil || entry.Verification.Status == config.VerificationVerified {
+		return
+	}
+
+	v := entry.Verification
+	fmt.Printf("⏳ Domain ownership verification pending. Publish one of:\n")
+	fmt.Printf("   TXT %s = %s\n", v.DNSRecord, v.Challenge)
+	fmt.Printf("   %s (content: %s)\n", v.HTTPURL, v.Challenge)
+	fmt.Printf("💡 The running server checks periodically; -approve-domain %s skips the check\n", entry.Domain)
+}
+
+func formatVerification(v *models.DomainVerification) string {
+	if v == nil || v.Status == config.VerificationVerified {
+		return ""
+	}
+	if v.LastError != "" {
+		return fmt.Sprintf(", Verification: %s (%d attempts, last error: %s)", v.Status, v.Attempts, v.LastError)
+	}
+	return fmt.Sprintf(", Verification: %s", v.Status)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/storage"
+	"redirect_helper/internal/wol"
+)
+
+// setWakeCmd 为路径条目或域名映射设置网络唤醒，domain 为 true 时 name 是域名
+func setWakeCmd(name string, domain bool, wake *config.WakeConfig, store *storage.ConfigStorage) {
+	if wake != nil && wake.MAC == "" {
+		log.Fatal("MAC address is required. Use -wake-mac flag, e.g. -wake-mac 00:11:22:33:44:55")
+	}
+
+	var err error
+	if domain {
+		err = store.SetDomainWake(nil, name, wake)
+	} else {
+		err = store.SetForwardingWake(nil, name, wake)
+	}
+	if err != nil {
+		log.Fatalf("Failed to update Wake-on-LAN: %v", err)
+	}
+
+	if wake == nil {
+		fmt.Printf("Wake-on-LAN of '%s' removed\n", name)
+		return
+	}
+	fmt.Printf("Visiting '%s' now wakes %s first\n", name, wake.MAC)
+}
+
+// wakeNowCmd 立即向路径条目或域名映射的设备发送魔术包
+func wakeNowCmd(name string, store *storage.ConfigStorage) {
+	var wake *models.Wake
+	if forwarding, err := store.GetForwarding(name); err == nil {
+		wake = forwarding.Wake
+	} else if domain, err := store.GetDomain(name); err == nil {
+		wake = domain.Wake
+	} else {
+		log.Fatalf("Forwarding or domain '%s' not found", name)
+	}
+	if wake == nil {
+		log.Fatalf("'%s' has no Wake-on-LAN configuration. Use -set-wake or -set-domain-wake first", name)
+	}
+
+	if err := wol.Send(wake.MAC, wake.Broadcast); err != nil {
+		log.Fatalf("Failed to send magic packet: %v", err)
+	}
+	fmt.Printf("Magic packet sent to %s\n", wake.MAC)
+}
+
+func formatWake(wake *models.Wake) string {
+	if wake == nil {
+		return ""
+	}
+	return ", Wake: " + wake.MAC
+}
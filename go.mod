@@ -1,3 +1,14 @@
 module redirect_helper
 
 go 1.22.2
+
+require (
+	github.com/oschwald/maxminddb-golang v1.13.1
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.21.0
+)
+
+require (
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 访问限制：路径跳转可以要求访问密码，也可以限制成功跳转的次数（一次性链接）
+
+// ErrLinkExhausted 链接的使用次数已经用完
+var ErrLinkExhausted = errors.New("link has reached its maximum number of uses")
+
+// minLinkPasswordLength 访问密码的最短长度
+const minLinkPasswordLength = 4
+
+// AccessChange 访问限制的修改，为 nil 的字段保持不变
+type AccessChange struct {
+	Password *string // 访问密码，空字符串取消密码；修改后已经输入过旧密码的访问者需要重新输入
+	MaxUses  *int    // 成功跳转次数上限，0 取消限制；设置时已使用次数清零
+}
+
+// SetForwardingAccess 在一个事务中修改路径跳转的访问密码和使用次数上限，密码只保存 bcrypt 哈希
+func (c *Config) SetForwardingAccess(actor *Actor, name string, change AccessChange) error {
+	hash := ""
+	if change.Password != nil && *change.Password != "" {
+		if len(*change.Password) < minLinkPasswordLength {
+			return fmt.Errorf("password too short (min %d characters)", minLinkPasswordLength)
+		}
+		generated, err := bcrypt.GenerateFromPassword([]byte(*change.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %v", err)
+		}
+		hash = string(generated)
+	}
+	if change.MaxUses != nil && *change.MaxUses < 0 {
+		return fmt.Errorf("max_uses must not be negative")
+	}
+
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.authorize(forwarding.Owner); err != nil {
+			return err
+		}
+		if change.Password != nil {
+			forwarding.PasswordHash = hash
+		}
+		if change.MaxUses != nil {
+			forwarding.MaxUses = *change.MaxUses
+			forwarding.Uses = 0
+		}
+		forwarding.touch()
+		return nil
+	})
+}
+
+// ValidateForwardingPassword 校验路径跳转的访问密码，条目不存在或未设置密码时返回 false
+func (c *Config) ValidateForwardingPassword(name, password string) bool {
+	c.mu.RLock()
+	hash := ""
+	if forwarding, exists := c.Forwardings[name]; exists {
+		hash = forwarding.PasswordHash
+	}
+	c.mu.RUnlock()
+
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// ForwardingPasswordDigest 返回访问密码哈希的摘要，用于签名访问 cookie；未设置密码时返回空字符串
+// bcrypt 哈希带随机盐，重新设置密码（包括删除后重新创建条目）后摘要一定不同，修改目标等其它设置不影响摘要
+func (c *Config) ForwardingPasswordDigest(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	forwarding, exists := c.Forwardings[name]
+	if !exists || forwarding.PasswordHash == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(forwarding.PasswordHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// ConsumeForwardingUse 记录一次成功跳转，次数已用完时返回 ErrLinkExhausted
+// 计数在写锁内检查并保存到配置文件，并发访问时也不会超过上限；使用次数不是条目修改，不改变版本号
+func (c *Config) ConsumeForwardingUse(name string) error {
+	c.mu.RLock()
+	forwarding, exists := c.Forwardings[name]
+	limited := exists && forwarding.MaxUses > 0
+	c.mu.RUnlock()
+	if !limited {
+		return nil
+	}
+
+	return c.Update(func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if forwarding.MaxUses > 0 {
+			if forwarding.Uses >= forwarding.MaxUses {
+				return ErrLinkExhausted
+			}
+			forwarding.Uses++
+		}
+		return nil
+	})
+}
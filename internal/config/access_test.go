@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConsumeForwardingUseConcurrent(t *testing.T) {
+	const maxUses, callers = 5, 50
+
+	previousPath := configPath
+	SetConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	t.Cleanup(func() { SetConfigPath(previousPath) })
+
+	c := NewConfig()
+	c.Forwardings["once"] = &ForwardingConfig{Target: "https://example.com", MaxUses: maxUses}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.ConsumeForwardingUse("once")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrLinkExhausted):
+			t.Errorf("ConsumeForwardingUse() error = %v, want nil or ErrLinkExhausted", err)
+		}
+	}
+	if succeeded != maxUses {
+		t.Errorf("%d calls succeeded, want %d", succeeded, maxUses)
+	}
+
+	saved, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uses := saved.Forwardings["once"].Uses; uses != maxUses {
+		t.Errorf("saved uses = %d, want %d", uses, maxUses)
+	}
+}
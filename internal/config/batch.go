@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBatchRolledBack 原子模式下有条目失败，整批修改已回滚
+var ErrBatchRolledBack = errors.New("batch rolled back")
+
+// errDryRun 用于在试运行结束时丢弃事务
+var errDryRun = errors.New("dry run")
+
+// 批量操作类型
+const (
+	BatchOpUpsert = "upsert" // 创建或更新目标（默认）
+	BatchOpDelete = "delete" // 删除条目，需要 admin token
+	BatchOpRename = "rename" // 重命名条目，可同时更新目标
+)
+
+// BatchOperation 批量操作中的单个条目
+type BatchOperation struct {
+	Op              string
+	Name            string
+	Domain          string
+	Target          string
+	NewName         string // rename 时的新名称
+	NewDomain       string // rename 时的新域名
+	ExpectedVersion int64  // 非 0 时条目当前版本必须与之一致
+}
+
+// BatchResult 单个条目的执行结果
+type BatchResult struct {
+	Err     error
+	Version int64 // 成功后条目的版本，删除时为 0
+}
+
+// BatchOptions 批量操作的认证信息和执行模式
+type BatchOptions struct {
+	AdminToken    string
+	RedirectToken string
+	DomainToken   string
+	Actor         *Actor   // 已认证的用户（API key 或登录会话），非 nil 时不再需要 token，只能修改自己的条目
+	Atomic        bool     // 全部成功才提交，否则整批回滚
+	DryRun        bool     // 只返回每个条目的结果，不保存任何修改
+	SelfHosts     []string // 额外的本服务主机名，用于检测跳转循环
+}
+
+// ApplyBatch 在一个事务中按顺序应用批量操作，成功时只保存一次
+// 返回每个条目的结果（与 ops 顺序一致）；提交失败或原子模式回滚时返回整体错误
+func (c *Config) ApplyBatch(ops []BatchOperation, opts BatchOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+
+	err := c.UpdateAs(opts.Actor, func(tx *Tx) error {
+		failed := false
+		for i, op := range ops {
+			// 每个条目在修改前完成校验，失败的条目不会影响工作副本
+			results[i].Version, results[i].Err = tx.applyBatchOperation(op, opts)
+			if results[i].Err != nil {
+				failed = true
+			}
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		if opts.Atomic && failed {
+			return ErrBatchRolledBack
+		}
+		return nil
+	})
+
+	if errors.Is(err, errDryRun) {
+		return results, nil
+	}
+	return results, err
+}
+
+// applyBatchOperation 执行单个条目：先校验 token，再检查版本，最后修改
+func (tx *Tx) applyBatchOperation(op BatchOperation, opts BatchOptions) (int64, error) {
+	if (op.Name == "") == (op.Domain == "") {
+		// 同时指定了 name 和 domain，或者都没指定
+		return 0, fmt.Errorf("Must specify either name or domain, not both or neither")
+	}
+
+	if err := tx.authorizeBatchOperation(op, opts); err != nil {
+		return 0, err
+	}
+
+	if op.Name != "" {
+		if err := tx.authorizeForwarding(op.Name); err != nil {
+			return 0, err
+		}
+		if err := tx.CheckForwardingVersion(op.Name, op.ExpectedVersion); err != nil {
+			return 0, err
+		}
+	} else {
+		if err := tx.authorizeDomain(op.Domain); err != nil {
+			return 0, err
+		}
+		if err := tx.CheckDomainVersion(op.Domain, op.ExpectedVersion); err != nil {
+			return 0, err
+		}
+	}
+
+	switch op.Op {
+	case "", BatchOpUpsert:
+		if op.Target == "" {
+			return 0, fmt.Errorf("Missing target")
+		}
+		if op.Name != "" {
+			// 路径重定向
+			if err := tx.setTarget(op.Name, op.Target, opts.SelfHosts...); err != nil {
+				return 0, err
+			}
+			return tx.forwardings[op.Name].Version, nil
+		}
+		// 域名重定向
+		if err := tx.setDomainTarget(op.Domain, op.Target, opts.SelfHosts...); err != nil {
+			return 0, err
+		}
+		return tx.domains[domainKey(op.Domain)].Version, nil
+
+	case BatchOpDelete:
+		if op.Name != "" {
+			return 0, tx.RemoveForwarding(op.Name)
+		}
+		return 0, tx.RemoveDomain(op.Domain)
+
+	case BatchOpRename:
+		if op.Name != "" {
+			if op.NewName == "" {
+				return 0, fmt.Errorf("Missing new_name")
+			}
+			if err := tx.RenameForwarding(op.Name, op.NewName, op.Target, opts.SelfHosts...); err != nil {
+				return 0, err
+			}
+			return tx.forwardings[op.NewName].Version, nil
+		}
+		if op.NewDomain == "" {
+			return 0, fmt.Errorf("Missing new_domain")
+		}
+		if err := tx.RenameDomain(op.Domain, op.NewDomain, op.Target, opts.SelfHosts...); err != nil {
+			return 0, err
+		}
+		return tx.domains[domainKey(op.NewDomain)].Version, nil
+
+	default:
+		return 0, fmt.Errorf("Unknown op %q (expected upsert, delete or rename)", op.Op)
+	}
+}
+
+// authorizeBatchOperation 按操作类型校验 token：删除需要 admin token，其它操作需要对应类型的 token
+// 有效的 admin token 可以执行所有操作，已认证的用户可以操作自己的条目
+func (tx *Tx) authorizeBatchOperation(op BatchOperation, opts BatchOptions) error {
+	if opts.AdminToken != "" && tx.config.validateAdminToken(opts.AdminToken) {
+		return nil
+	}
+	if opts.Actor != nil {
+		return nil
+	}
+
+	switch op.Op {
+	case BatchOpDelete:
+		if opts.AdminToken == "" {
+			return fmt.Errorf("Missing admin_token")
+		}
+		if !tx.config.validateAdminToken(opts.AdminToken) {
+			return fmt.Errorf("invalid admin token")
+		}
+	case "", BatchOpUpsert, BatchOpRename:
+		if op.Name != "" {
+			if opts.RedirectToken == "" {
+				return fmt.Errorf("Missing redirect_token")
+			}
+			if !tx.config.validateRedirectToken(opts.RedirectToken) {
+				return fmt.Errorf("invalid redirect token")
+			}
+		} else {
+			if opts.DomainToken == "" {
+				return fmt.Errorf("Missing domain_token")
+			}
+			if !tx.config.validateDomainToken(opts.DomainToken) {
+				return fmt.Errorf("invalid domain token")
+			}
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 	
 	"redirect_helper/pkg/utils"
@@ -14,20 +15,69 @@ type Config struct {
 	Forwardings map[string]*ForwardingConfig `json:"forwardings"`
 	Domains     map[string]*DomainConfig     `json:"domains"`
 	Server      *ServerConfig                `json:"server"`
+	Users       map[string]*UserConfig       `json:"users,omitempty"`
+
+	// mu 保护条目和 token 的并发读写
+	mu sync.RWMutex
+
+	listeners changeListeners
+	// expiryTimer 在最早的旧 token 过期时触发清理
+	expiryTimer *time.Timer
 }
 
 type ForwardingConfig struct {
 	Name      string    `json:"name"`
 	Target    string    `json:"target"`
+	Version   int64     `json:"version"`         // 每次修改递增，用于乐观并发控制
+	Owner     string    `json:"owner,omitempty"` // 所属用户，为空表示只有管理员可见
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// UpdateTokenHash 条目自己的更新 token 的 SHA-256 哈希，只能用于修改该条目的目标
+	UpdateTokenHash string `json:"update_token_hash,omitempty"`
+
+	// Relay 四层转发设置，为空表示只提供 HTTP 跳转
+	Relay *RelayConfig `json:"relay,omitempty"`
+
+	// Wake 访问前先唤醒目标主机，为空表示直接跳转
+	Wake *WakeConfig `json:"wake,omitempty"`
+
+	// Preview 访问时先显示目标地址的预览页面，为空表示直接跳转
+	Preview *PreviewConfig `json:"preview,omitempty"`
+
+	// PasswordHash 访问密码的 bcrypt 哈希，为空表示不需要密码
+	PasswordHash string `json:"password_hash,omitempty"`
+	// MaxUses 成功跳转的次数上限，用完后返回 410；0 表示不限制
+	MaxUses int `json:"max_uses,omitempty"`
+	// Uses 设置上限后已成功跳转的次数
+	Uses int `json:"uses,omitempty"`
+
+	// Routes 按访问者选择目标的路由规则，都不匹配时使用 Target
+	Routes []RouteRule `json:"routes,omitempty"`
 }
 
 type DomainConfig struct {
 	Domain    string    `json:"domain"`
 	Target    string    `json:"target"`
+	Version   int64     `json:"version"`         // 每次修改递增，用于乐观并发控制
+	Owner     string    `json:"owner,omitempty"` // 所属用户，为空表示只有管理员可见
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// UpdateTokenHash 条目自己的更新 token 的 SHA-256 哈希，只能用于修改该条目的目标
+	UpdateTokenHash string `json:"update_token_hash,omitempty"`
+
+	// Verification 所有权验证状态，为空表示创建时未启用验证，映射直接生效
+	Verification *DomainVerification `json:"verification,omitempty"`
+
+	// Wake 访问前先唤醒目标主机，为空表示直接跳转
+	Wake *WakeConfig `json:"wake,omitempty"`
+
+	// Routes 按访问者选择目标的路由规则，都不匹配时使用 Target
+	Routes []RouteRule `json:"routes,omitempty"`
+
+	// PathRules 按访问路径选择跳转地址的规则，都不匹配时保持原路径跳转到 Target
+	PathRules []PathRule `json:"path_rules,omitempty"`
 }
 
 type ServerConfig struct {
@@ -37,6 +87,130 @@ type ServerConfig struct {
 	DomainToken       string `json:"domain_token"`
 	MaxRedirectCount  int    `json:"max_redirect_count"`
 	MaxDomainCount    int    `json:"max_domain_count"`
+
+	// TrustedProxies 可信反向代理的 CIDR 列表，只有来自这些地址的转发头才会被采信
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	// ClientIPHeader 可信代理设置客户端地址的请求头：x-forwarded-for（默认）、forwarded 或 x-real-ip，只解析这一个头
+	ClientIPHeader string `json:"client_ip_header,omitempty"`
+	// ProxyProtocol 在监听器上启用 PROXY protocol v1/v2（仅对可信代理生效）
+	ProxyProtocol bool `json:"proxy_protocol,omitempty"`
+
+	TLS *TLSConfig `json:"tls,omitempty"`
+	DNS *DNSConfig `json:"dns,omitempty"`
+	SNI *SNIConfig `json:"sni,omitempty"`
+
+	// Relay 路径条目的 TCP/UDP 四层转发
+	Relay *RelayServerConfig `json:"relay,omitempty"`
+
+	// DomainVerification 新建域名映射前要求证明域名所有权
+	DomainVerification *DomainVerificationConfig `json:"domain_verification,omitempty"`
+
+	// GeoIP 路由规则按国家和 ASN 匹配时使用的本地数据库
+	GeoIP *GeoIPConfig `json:"geoip,omitempty"`
+
+	// Listen 公共监听地址（跳转流量），为空时使用 Port；支持 "unix:/path/to.sock"
+	Listen string `json:"listen,omitempty"`
+	// AdminListen 独立的管理监听地址（API、管理界面），为空时与公共端口共用
+	AdminListen string `json:"admin_listen,omitempty"`
+	// BasePath 部署在反向代理子路径下时的路径前缀，例如 "/redirect"
+	BasePath string `json:"base_path,omitempty"`
+
+	// 名称与目标地址校验
+	ReservedNames    []string `json:"reserved_names,omitempty"`     // 额外的保留名称
+	AllowedSchemes   []string `json:"allowed_schemes,omitempty"`    // 允许的目标协议，默认 http/https
+	TargetAllowHosts []string `json:"target_allow_hosts,omitempty"` // 目标主机白名单，支持 "*.example.com" 和 CIDR
+	TargetDenyHosts  []string `json:"target_deny_hosts,omitempty"`  // 目标主机黑名单
+	PublicHosts      []string `json:"public_hosts,omitempty"`       // 本服务的公网主机名，用于检测跳转循环
+
+	// HTTP 服务器超时（秒）和请求头大小限制，0 表示使用默认值
+	ReadHeaderTimeout int `json:"read_header_timeout,omitempty"`
+	ReadTimeout       int `json:"read_timeout,omitempty"`
+	WriteTimeout      int `json:"write_timeout,omitempty"`
+	IdleTimeout       int `json:"idle_timeout,omitempty"`
+	ShutdownTimeout   int `json:"shutdown_timeout,omitempty"`
+	MaxHeaderBytes    int `json:"max_header_bytes,omitempty"`
+
+	Webhooks *WebhooksConfig `json:"webhooks,omitempty"`
+
+	// EventBufferSize /api/v2/events 断线续传保留的事件数量，默认 1024
+	EventBufferSize int `json:"event_buffer_size,omitempty"`
+
+	// 管理界面登录：除 admin token 外也可以使用用户名和密码（bcrypt 哈希）
+	AdminUsername     string `json:"admin_username,omitempty"`
+	AdminPasswordHash string `json:"admin_password_hash,omitempty"`
+	// SessionTTL 登录会话有效期（秒），默认 12 小时
+	SessionTTL int `json:"session_ttl,omitempty"`
+
+	// RetiredTokens 轮换后仍在宽限期内的旧 token，键为 admin/redirect/domain
+	RetiredTokens map[string]*RetiredToken `json:"retired_tokens,omitempty"`
+}
+
+// TLSConfig HTTPS 监听器配置，证书来自文件或通过 ACME HTTP-01 自动签发
+type TLSConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Port     string `json:"port"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// ACME 只为 Config.Domains 中的域名签发证书
+	ACME             bool   `json:"acme,omitempty"`
+	ACMEDirectoryURL string `json:"acme_directory_url,omitempty"`
+	ACMEEmail        string `json:"acme_email,omitempty"`
+	ACMECacheDir     string `json:"acme_cache_dir,omitempty"`
+	ACMECARoot       string `json:"acme_ca_root,omitempty"` // ACME 服务的自定义 CA（如本地 Pebble）
+
+	// RedirectHTTP 将明文 HTTP 请求 308 跳转到 HTTPS
+	RedirectHTTP bool `json:"redirect_http,omitempty"`
+}
+
+// DNSConfig 内置权威 DNS：为 Config.Domains 中的每个域名返回指向本服务的 A/AAAA 记录，
+// 以及 Records 中配置的 TXT、CNAME 等静态记录
+type DNSConfig struct {
+	Enabled    bool        `json:"enabled"`
+	Listen     string      `json:"listen,omitempty"`     // UDP/TCP 监听地址，默认 ":53"
+	PublicIPs  []string    `json:"public_ips"`           // 本服务的公网 IPv4/IPv6 地址
+	TTL        int         `json:"ttl,omitempty"`        // 记录 TTL（秒），默认 60
+	Nameserver string      `json:"nameserver,omitempty"` // SOA/NS 记录中的名称服务器，默认 ns.<域名>
+	Records    []DNSRecord `json:"records,omitempty"`
+}
+
+// DNSRecord 静态 DNS 记录；同名的 A/AAAA 记录会替换默认的公网地址
+type DNSRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"` // A、AAAA、TXT 或 CNAME
+	Value string `json:"value"`
+}
+
+// SNIConfig TLS 透传：按 ClientHello 中的 SNI 查找 Config.Domains，目标为 host:port 的域名直接转发原始 TCP 流，
+// 证书留在后端；其它名称转发到 Default，或在与内置 HTTPS 共用端口时由本服务终止 TLS
+type SNIConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Listen         string `json:"listen,omitempty"`          // 监听地址，默认 ":443"
+	Default        string `json:"default,omitempty"`         // 未知名称转发到的 host:port
+	MaxConnections int    `json:"max_connections,omitempty"` // 最大并发连接数，默认 256
+	IdleTimeout    int    `json:"idle_timeout,omitempty"`    // 空闲超时（秒），默认 300
+}
+
+// WebhooksConfig 条目变更时的外发通知
+type WebhooksConfig struct {
+	Subscriptions []WebhookSubscription `json:"subscriptions"`
+
+	// QueueFile 待投递队列和投递日志的持久化文件，默认为配置文件旁的 <name>.webhooks.json
+	QueueFile   string `json:"queue_file,omitempty"`
+	QueueSize   int    `json:"queue_size,omitempty"`   // 队列上限，满时丢弃最旧的投递，默认 1000
+	MaxAttempts int    `json:"max_attempts,omitempty"` // 每次投递的最大尝试次数，默认 8
+	Timeout     int    `json:"timeout,omitempty"`      // 单次请求超时（秒），默认 10
+}
+
+// WebhookSubscription 一个 webhook 订阅
+type WebhookSubscription struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Events 事件过滤，如 "forwarding.updated"、"domain.*"；为空时订阅全部事件
+	Events []string `json:"events,omitempty"`
+	// Secret 非空时用 HMAC-SHA256 对请求体签名
+	Secret   string `json:"secret,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
 }
 
 func NewConfig() *Config {
@@ -56,6 +230,9 @@ func NewConfig() *Config {
 
 var configPath string
 
+// saveMu 串行化配置文件写入，避免并发保存互相覆盖
+var saveMu sync.Mutex
+
 func SetConfigPath(path string) {
 	configPath = path
 }
@@ -84,6 +261,7 @@ func LoadConfig() (*Config, error) {
 	if err := json.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	config.normalizeVersions()
 
 	return config, nil
 }
@@ -150,6 +328,8 @@ func LoadConfigForServer() (*Config, error) {
 	if err := json.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	config.normalizeVersions()
+	config.scheduleTokenExpiry()
 
 	return config, nil
 }
@@ -165,67 +345,95 @@ func ensureConfigDir(configPath string) error {
 }
 
 func (c *Config) Save() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.save()
+}
+
+// save 将配置写入磁盘，调用方需持有锁
+func (c *Config) save() error {
 	configPath := GetConfigPath()
 
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// 先写临时文件再重命名，保证进程被中断时配置文件不会只写了一半
+	tmpFile, err := os.CreateTemp(filepath.Dir(configPath), filepath.Base(configPath)+".tmp-*")
+	if err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
 
-	return nil
-}
-
-func (c *Config) AddForwarding(name string) error {
-	if _, exists := c.Forwardings[name]; exists {
-		return fmt.Errorf("forwarding name already exists")
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write config file: %v", err)
 	}
-
-	// Check max redirect count
-	if len(c.Forwardings) >= c.Server.MaxRedirectCount {
-		return fmt.Errorf("maximum redirect count (%d) reached", c.Server.MaxRedirectCount)
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to sync config file: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
-	c.Forwardings[name] = &ForwardingConfig{
-		Name:      name,
-		Target:    "",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		return fmt.Errorf("failed to replace config file: %v", err)
 	}
 
-	return c.Save()
+	return nil
 }
 
-func (c *Config) SetTarget(name, token, target string) error {
-	// Validate redirect token
-	if c.Server.RedirectToken == "" || c.Server.RedirectToken != token {
-		return fmt.Errorf("invalid redirect token")
+// CheckStorage 检查配置文件是否可读、配置目录是否可写
+func (c *Config) CheckStorage() error {
+	configPath := GetConfigPath()
+
+	if _, err := os.ReadFile(configPath); err != nil {
+		return fmt.Errorf("config file not readable: %v", err)
 	}
 
-	// Create forwarding if it doesn't exist
-	if _, exists := c.Forwardings[name]; !exists {
-		if err := c.AddForwarding(name); err != nil {
-			return err
-		}
+	probe, err := os.CreateTemp(filepath.Dir(configPath), ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("config directory not writable: %v", err)
 	}
+	probe.Close()
+	os.Remove(probe.Name())
 
-	forwarding := c.Forwardings[name]
-	forwarding.Target = target
-	forwarding.UpdatedAt = time.Now()
+	return nil
+}
 
-	return c.Save()
+func (c *Config) AddForwarding(name string) error {
+	return c.Update(func(tx *Tx) error {
+		return tx.AddForwarding(name)
+	})
+}
+
+func (c *Config) SetTarget(name, token, target string) error {
+	return c.Update(func(tx *Tx) error {
+		return tx.SetTarget(name, token, target)
+	})
 }
 
 func (c *Config) GetForwarding(name string) (*ForwardingConfig, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	forwarding, exists := c.Forwardings[name]
 	if !exists {
 		return nil, fmt.Errorf("forwarding name not found")
 	}
 
-	return forwarding, nil
+	copied := *forwarding
+	return &copied, nil
 }
 
 func (c *Config) GetTarget(name string) (string, error) {
@@ -242,82 +450,53 @@ func (c *Config) GetTarget(name string) (string, error) {
 }
 
 func (c *Config) ListForwardings() []*ForwardingConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	result := make([]*ForwardingConfig, 0, len(c.Forwardings))
 	for _, forwarding := range c.Forwardings {
-		result = append(result, forwarding)
+		copied := *forwarding
+		result = append(result, &copied)
 	}
 	return result
 }
 
 func (c *Config) RemoveForwarding(name string) error {
-	if _, exists := c.Forwardings[name]; !exists {
-		return fmt.Errorf("forwarding name not found")
-	}
-
-	delete(c.Forwardings, name)
-	return c.Save()
+	return c.Update(func(tx *Tx) error {
+		return tx.RemoveForwarding(name)
+	})
 }
 
 func (c *Config) UpdateTarget(name, target string) error {
-	forwarding, exists := c.Forwardings[name]
-	if !exists {
-		return fmt.Errorf("forwarding name not found")
-	}
-
-	forwarding.Target = target
-	forwarding.UpdatedAt = time.Now()
-
-	return c.Save()
+	return c.Update(func(tx *Tx) error {
+		return tx.UpdateTarget(name, target)
+	})
 }
 
 // Domain management methods
 func (c *Config) AddDomain(domain string) error {
-	if _, exists := c.Domains[domain]; exists {
-		return fmt.Errorf("domain already exists")
-	}
-
-	// Check max domain count
-	if len(c.Domains) >= c.Server.MaxDomainCount {
-		return fmt.Errorf("maximum domain count (%d) reached", c.Server.MaxDomainCount)
-	}
-
-	c.Domains[domain] = &DomainConfig{
-		Domain:    domain,
-		Target:    "",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	return c.Save()
+	return c.Update(func(tx *Tx) error {
+		return tx.AddDomain(domain)
+	})
 }
 
 func (c *Config) SetDomainTarget(domain, token, target string) error {
-	// Validate domain token
-	if c.Server.DomainToken == "" || c.Server.DomainToken != token {
-		return fmt.Errorf("invalid domain token")
-	}
-
-	// Create domain if it doesn't exist
-	if _, exists := c.Domains[domain]; !exists {
-		if err := c.AddDomain(domain); err != nil {
-			return err
-		}
-	}
-
-	domainConfig := c.Domains[domain]
-	domainConfig.Target = target
-	domainConfig.UpdatedAt = time.Now()
-
-	return c.Save()
+	return c.Update(func(tx *Tx) error {
+		return tx.SetDomainTarget(domain, token, target)
+	})
 }
 
 func (c *Config) GetDomain(domain string) (*DomainConfig, error) {
-	domainConfig, exists := c.Domains[domain]
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	domainConfig, exists := c.Domains[domainKey(domain)]
 	if !exists {
 		return nil, fmt.Errorf("domain not found")
 	}
 
-	return domainConfig, nil
+	copied := *domainConfig
+	return &copied, nil
 }
 
 func (c *Config) GetDomainTarget(domain string) (string, error) {
@@ -326,6 +505,9 @@ func (c *Config) GetDomainTarget(domain string) (string, error) {
 		return "", err
 	}
 
+	if !domainConfig.Active() {
+		return "", fmt.Errorf("domain ownership not verified")
+	}
 	if domainConfig.Target == "" {
 		return "", fmt.Errorf("target not set")
 	}
@@ -334,36 +516,31 @@ func (c *Config) GetDomainTarget(domain string) (string, error) {
 }
 
 func (c *Config) ListDomains() []*DomainConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	result := make([]*DomainConfig, 0, len(c.Domains))
 	for _, domain := range c.Domains {
-		result = append(result, domain)
+		copied := *domain
+		result = append(result, &copied)
 	}
 	return result
 }
 
 func (c *Config) RemoveDomain(domain string) error {
-	if _, exists := c.Domains[domain]; !exists {
-		return fmt.Errorf("domain not found")
-	}
-
-	delete(c.Domains, domain)
-	return c.Save()
+	return c.Update(func(tx *Tx) error {
+		return tx.RemoveDomain(domain)
+	})
 }
 
 func (c *Config) UpdateDomainTarget(domain, target string) error {
-	domainConfig, exists := c.Domains[domain]
-	if !exists {
-		return fmt.Errorf("domain not found")
-	}
-
-	domainConfig.Target = target
-	domainConfig.UpdatedAt = time.Now()
-
-	return c.Save()
+	return c.Update(func(tx *Tx) error {
+		return tx.UpdateDomainTarget(domain, target)
+	})
 }
 
-// Admin token management
-func (c *Config) SetAdminToken(token string) error {
+// ensureServer 确保 Server 配置存在，调用方需持有写锁
+func (c *Config) ensureServer() {
 	if c.Server == nil {
 		c.Server = &ServerConfig{
 			Port:             "8001",
@@ -374,41 +551,52 @@ func (c *Config) SetAdminToken(token string) error {
 			MaxDomainCount:   10,
 		}
 	}
+}
+
+// Admin token management
+func (c *Config) SetAdminToken(token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ensureServer()
 	c.Server.AdminToken = token
-	return c.Save()
+	c.clearRetiredToken(TokenAdmin)
+	return c.saveTokenChange(TokenAdmin)
 }
 
 func (c *Config) SetRedirectToken(token string) error {
-	if c.Server == nil {
-		c.Server = &ServerConfig{
-			Port:             "8001",
-			AdminToken:       "",
-			RedirectToken:    "",
-			DomainToken:      "",
-			MaxRedirectCount: 20,
-			MaxDomainCount:   10,
-		}
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ensureServer()
 	c.Server.RedirectToken = token
-	return c.Save()
+	c.clearRetiredToken(TokenRedirect)
+	return c.saveTokenChange(TokenRedirect)
 }
 
 func (c *Config) SetDomainToken(token string) error {
-	if c.Server == nil {
-		c.Server = &ServerConfig{
-			Port:             "8001",
-			AdminToken:       "",
-			RedirectToken:    "",
-			DomainToken:      "",
-			MaxRedirectCount: 20,
-			MaxDomainCount:   10,
-		}
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ensureServer()
 	c.Server.DomainToken = token
-	return c.Save()
+	c.clearRetiredToken(TokenDomain)
+	return c.saveTokenChange(TokenDomain)
+}
+
+// saveTokenChange 保存 token 修改并发出 token.reset 事件，调用方需持有写锁
+func (c *Config) saveTokenChange(kind string) error {
+	if err := c.save(); err != nil {
+		return err
+	}
+	c.emit([]ChangeEvent{{Type: EventTokenReset, Name: kind, Time: time.Now()}})
+	return nil
 }
 
 func (c *Config) GetAdminToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	if c.Server == nil {
 		return ""
 	}
@@ -416,11 +604,16 @@ func (c *Config) GetAdminToken() string {
 }
 
 func (c *Config) ValidateAdminToken(token string) bool {
-	adminToken := c.GetAdminToken()
-	return adminToken != "" && adminToken == token
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.validateAdminToken(token)
 }
 
 func (c *Config) GetRedirectToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	if c.Server == nil {
 		return ""
 	}
@@ -428,6 +621,9 @@ func (c *Config) GetRedirectToken() string {
 }
 
 func (c *Config) GetDomainToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	if c.Server == nil {
 		return ""
 	}
@@ -435,11 +631,30 @@ func (c *Config) GetDomainToken() string {
 }
 
 func (c *Config) ValidateRedirectToken(token string) bool {
-	redirectToken := c.GetRedirectToken()
-	return redirectToken != "" && redirectToken == token
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.validateRedirectToken(token)
 }
 
 func (c *Config) ValidateDomainToken(token string) bool {
-	domainToken := c.GetDomainToken()
-	return domainToken != "" && domainToken == token
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.validateDomainToken(token)
+}
+
+// validateAdminToken 校验 admin token（包括宽限期内的旧 token），调用方需持有锁
+func (c *Config) validateAdminToken(token string) bool {
+	return c.matchToken(TokenAdmin, token) != ""
+}
+
+// validateRedirectToken 校验 redirect token（包括宽限期内的旧 token），调用方需持有锁
+func (c *Config) validateRedirectToken(token string) bool {
+	return c.matchToken(TokenRedirect, token) != ""
+}
+
+// validateDomainToken 校验 domain token（包括宽限期内的旧 token），调用方需持有锁
+func (c *Config) validateDomainToken(token string) bool {
+	return c.matchToken(TokenDomain, token) != ""
 }
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 配置变更事件类型
+const (
+	EventForwardingCreated = "forwarding.created"
+	EventForwardingUpdated = "forwarding.updated"
+	EventForwardingRemoved = "forwarding.removed"
+	EventDomainCreated     = "domain.created"
+	EventDomainUpdated     = "domain.updated"
+	EventDomainRemoved     = "domain.removed"
+	EventDomainVerified    = "domain.verified"
+	EventTokenReset        = "token.reset"
+	EventTokenRotated      = "token.rotated"
+	EventTokenExpired      = "token.expired"
+)
+
+// EventTypes 所有配置变更事件类型，用于校验订阅过滤条件
+var EventTypes = []string{
+	EventForwardingCreated,
+	EventForwardingUpdated,
+	EventForwardingRemoved,
+	EventDomainCreated,
+	EventDomainUpdated,
+	EventDomainRemoved,
+	EventDomainVerified,
+	EventTokenReset,
+	EventTokenRotated,
+	EventTokenExpired,
+}
+
+// EntryState 条目在变更前后的状态
+type EntryState struct {
+	Target  string `json:"target"`
+	Version int64  `json:"version"`
+}
+
+// ChangeEvent 一次已提交的配置变更
+// Name 为路径名称、域名或 token 类型（admin/redirect/domain）；token 事件不携带 token 值
+type ChangeEvent struct {
+	Type string      `json:"type"`
+	Name string      `json:"name"`
+	Time time.Time   `json:"time"`
+	Old  *EntryState `json:"old,omitempty"`
+	New  *EntryState `json:"new,omitempty"`
+}
+
+// changeListeners 配置变更的监听器
+type changeListeners struct {
+	mu        sync.RWMutex
+	listeners []func(ChangeEvent)
+}
+
+// OnChange 注册变更监听器，每次成功保存后按顺序调用
+// 监听器在配置写锁内同步执行，必须尽快返回且不能再调用 Config 的方法
+func (c *Config) OnChange(fn func(ChangeEvent)) {
+	c.listeners.mu.Lock()
+	defer c.listeners.mu.Unlock()
+
+	c.listeners.listeners = append(c.listeners.listeners, fn)
+}
+
+// emit 通知所有监听器，调用方需持有写锁
+func (c *Config) emit(events []ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	c.listeners.mu.RLock()
+	listeners := c.listeners.listeners
+	c.listeners.mu.RUnlock()
+
+	for _, event := range events {
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+}
+
+// diffChanges 比较事务前后的条目，生成创建、更新和删除事件
+// 重命名表现为旧名称的删除加新名称的创建
+func diffChanges(oldForwardings, newForwardings map[string]*ForwardingConfig, oldDomains, newDomains map[string]*DomainConfig) []ChangeEvent {
+	now := time.Now()
+	var events []ChangeEvent
+
+	for _, name := range unionKeys(oldForwardings, newForwardings) {
+		oldEntry, newEntry := oldForwardings[name], newForwardings[name]
+		event := ChangeEvent{Name: name, Time: now}
+		switch {
+		case oldEntry == nil:
+			event.Type = EventForwardingCreated
+			event.New = &EntryState{Target: newEntry.Target, Version: newEntry.Version}
+		case newEntry == nil:
+			event.Type = EventForwardingRemoved
+			event.Old = &EntryState{Target: oldEntry.Target, Version: oldEntry.Version}
+		case oldEntry.Version != newEntry.Version || oldEntry.Target != newEntry.Target:
+			event.Type = EventForwardingUpdated
+			event.Old = &EntryState{Target: oldEntry.Target, Version: oldEntry.Version}
+			event.New = &EntryState{Target: newEntry.Target, Version: newEntry.Version}
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+
+	for _, domain := range unionKeys(oldDomains, newDomains) {
+		oldEntry, newEntry := oldDomains[domain], newDomains[domain]
+		event := ChangeEvent{Name: domain, Time: now}
+		switch {
+		case oldEntry == nil:
+			event.Type = EventDomainCreated
+			event.New = &EntryState{Target: newEntry.Target, Version: newEntry.Version}
+		case newEntry == nil:
+			event.Type = EventDomainRemoved
+			event.Old = &EntryState{Target: oldEntry.Target, Version: oldEntry.Version}
+		case oldEntry.Version != newEntry.Version || oldEntry.Target != newEntry.Target:
+			event.Type = EventDomainUpdated
+			event.Old = &EntryState{Target: oldEntry.Target, Version: oldEntry.Version}
+			event.New = &EntryState{Target: newEntry.Target, Version: newEntry.Version}
+		}
+		if event.Type != "" {
+			events = append(events, event)
+		}
+
+		// 通过所有权验证后映射开始生效
+		if oldEntry != nil && newEntry != nil && !oldEntry.Active() && newEntry.Active() {
+			events = append(events, ChangeEvent{
+				Type: EventDomainVerified,
+				Name: domain,
+				Time: now,
+				New:  &EntryState{Target: newEntry.Target, Version: newEntry.Version},
+			})
+		}
+	}
+
+	return events
+}
+
+// unionKeys 返回两个 map 的键的并集，按字典序排列使事件顺序稳定
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
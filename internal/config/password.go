@@ -0,0 +1,57 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash 未设置密码时也做一次 bcrypt 比较，避免通过响应时间判断是否启用了密码登录
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("redirect_helper"), bcrypt.DefaultCost)
+	return hash
+})
+
+// SetAdminPassword 设置管理界面的登录用户名和密码，只保存 bcrypt 哈希
+func (c *Config) SetAdminPassword(username, password string) error {
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if len(password) < 8 {
+		return fmt.Errorf("password must be at least 8 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ensureServer()
+	c.Server.AdminUsername = username
+	c.Server.AdminPasswordHash = string(hash)
+	return c.save()
+}
+
+// ValidateAdminPassword 校验管理界面的用户名和密码，未设置密码时总是返回 false
+func (c *Config) ValidateAdminPassword(username, password string) bool {
+	c.mu.RLock()
+	var expectedUsername, hash string
+	if c.Server != nil {
+		expectedUsername, hash = c.Server.AdminUsername, c.Server.AdminPasswordHash
+	}
+	c.mu.RUnlock()
+
+	if expectedUsername == "" || hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return false
+	}
+
+	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(expectedUsername)) == 1
+	passwordMatch := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	return usernameMatch && passwordMatch
+}
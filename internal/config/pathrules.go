@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// 路径规则：域名映射可以按访问路径跳转到不同的地址，规则按顺序匹配，第一条匹配的规则决定跳转地址，
+// 都不匹配时保持原路径跳转到域名的目标。目标中的 $1、${name} 替换为匹配到的内容
+
+const (
+	// maxPathRules 每个域名映射的路径规则数量上限
+	maxPathRules = 64
+	// maxPathPattern 路径规则匹配模式的长度上限
+	maxPathPattern = 1024
+
+	PathRulePrefix = "prefix"
+	PathRuleRegex  = "regex"
+)
+
+// PathRuleStatuses 路径规则可用的跳转状态码，默认 302
+var PathRuleStatuses = []int{301, 302, 303, 307, 308}
+
+// PathRule 一条路径规则
+// prefix：Pattern 是路径前缀，例如 "/docs/"，末尾的 "*" 可以省略，路径剩余部分为 $1
+// regex：Pattern 是匹配路径的正则表达式，例如 "^/blog/(\d+)$"，分组为 $1、$2 或 ${name}
+type PathRule struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Target  string `json:"target"`
+	Status  int    `json:"status,omitempty"`
+}
+
+// SetDomainPathRules 替换域名映射的路径规则，rules 为空时取消规则
+func (c *Config) SetDomainPathRules(actor *Actor, domain string, rules []PathRule, extraSelfHosts ...string) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.authorize(domainConfig.Owner); err != nil {
+			return err
+		}
+		normalized, err := tx.config.normalizePathRules(rules, extraSelfHosts)
+		if err != nil {
+			return err
+		}
+		domainConfig.PathRules = normalized
+		domainConfig.touch()
+		return nil
+	})
+}
+
+// normalizePathRules 校验规则并统一格式，返回新的切片
+func (c *Config) normalizePathRules(rules []PathRule, extraSelfHosts []string) ([]PathRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxPathRules {
+		return nil, fmt.Errorf("too many path rules (max %d)", maxPathRules)
+	}
+
+	normalized := make([]PathRule, 0, len(rules))
+	for i, rule := range rules {
+		result, err := normalizePathRule(rule)
+		if err == nil {
+			// 替换发生在跳转时，这里校验模板本身：协议和主机中不能使用替换
+			err = c.ValidateTarget(result.Target, extraSelfHosts...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		normalized = append(normalized, result)
+	}
+	return normalized, nil
+}
+
+// normalizePathRule 校验一条规则：类型默认为 prefix，前缀末尾的 "*" 去掉，状态码默认为 302
+func normalizePathRule(rule PathRule) (PathRule, error) {
+	result := PathRule{
+		Type:    strings.ToLower(strings.TrimSpace(rule.Type)),
+		Pattern: rule.Pattern,
+		Target:  strings.TrimSpace(rule.Target),
+		Status:  rule.Status,
+	}
+	if result.Type == "" {
+		result.Type = PathRulePrefix
+	}
+	if result.Target == "" {
+		return result, fmt.Errorf("target is required")
+	}
+	if result.Status == 0 {
+		result.Status = 302
+	}
+	if !slices.Contains(PathRuleStatuses, result.Status) {
+		return result, fmt.Errorf("invalid status %d, expected one of 301, 302, 303, 307, 308", rule.Status)
+	}
+	if len(result.Pattern) > maxPathPattern {
+		return result, fmt.Errorf("pattern is too long (max %d)", maxPathPattern)
+	}
+
+	switch result.Type {
+	case PathRulePrefix:
+		result.Pattern = strings.TrimSuffix(result.Pattern, "*")
+		if !strings.HasPrefix(result.Pattern, "/") {
+			return result, fmt.Errorf("invalid prefix %q, expected a path starting with /", rule.Pattern)
+		}
+	case PathRuleRegex:
+		if result.Pattern == "" {
+			return result, fmt.Errorf("pattern is required")
+		}
+		if _, err := regexp.Compile(result.Pattern); err != nil {
+			return result, fmt.Errorf("invalid regex %q: %v", rule.Pattern, err)
+		}
+	default:
+		return result, fmt.Errorf("invalid type %q, expected prefix or regex", rule.Type)
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// 预览页面：路径跳转不直接返回 302，而是先显示目标地址，访问者确认后继续；
+// 任何条目都可以通过在名称后加 "+"（/go/name+）临时预览，设置了 Preview 的条目总是预览
+
+const (
+	// maxPreviewCountdown 倒计时上限（秒）
+	maxPreviewCountdown = 300
+	// maxPreviewMessage 说明文字的长度上限（字符）
+	maxPreviewMessage = 1000
+)
+
+// PreviewConfig 条目的预览页面设置
+type PreviewConfig struct {
+	Message   string `json:"message,omitempty"`   // 页面上显示的说明，例如提醒目标是外部站点
+	Countdown int    `json:"countdown,omitempty"` // 倒计时结束后自动继续（秒），0 表示需要点击继续
+}
+
+// SetForwardingPreview 设置路径跳转的预览页面，preview 为 nil 时取消
+func (c *Config) SetForwardingPreview(actor *Actor, name string, preview *PreviewConfig) error {
+	if preview != nil {
+		if preview.Countdown < 0 || preview.Countdown > maxPreviewCountdown {
+			return fmt.Errorf("preview countdown must be between 0 and %d seconds", maxPreviewCountdown)
+		}
+		if utf8.RuneCountInString(preview.Message) > maxPreviewMessage {
+			return fmt.Errorf("preview message too long (max %d characters)", maxPreviewMessage)
+		}
+		copied := *preview
+		preview = &copied
+	}
+
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.authorize(forwarding.Owner); err != nil {
+			return err
+		}
+		forwarding.Preview = preview
+		forwarding.touch()
+		return nil
+	})
+}
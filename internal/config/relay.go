@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// 四层转发：路径条目可以绑定一个本地端口，把 TCP（可选 UDP）连接转发到条目的 host:port 目标，
+// 用于 SSH、游戏服务器、数据库等无法通过 HTTP 跳转访问的服务。只有管理员可以设置
+
+// minUnprivilegedPort 未配置 allowed_ports 时允许的最小端口
+const minUnprivilegedPort = 1024
+
+// RelayServerConfig 四层转发全局设置
+type RelayServerConfig struct {
+	Enabled        bool     `json:"enabled"`
+	AllowedPorts   []string `json:"allowed_ports,omitempty"`   // 允许监听的端口，例如 "2222"、"30000-30100"；为空时允许 1024 以上的端口
+	MaxConnections int      `json:"max_connections,omitempty"` // 每个端口的默认最大并发连接数（UDP 为会话数），默认 256
+	IdleTimeout    int      `json:"idle_timeout,omitempty"`    // 默认空闲超时（秒），默认 TCP 300、UDP 60
+}
+
+// RelayConfig 路径条目的四层转发设置
+type RelayConfig struct {
+	Listen         string `json:"listen"`                    // 监听地址，例如 ":2222" 或 "127.0.0.1:5432"
+	UDP            bool   `json:"udp,omitempty"`             // 同时转发 UDP
+	MaxConnections int    `json:"max_connections,omitempty"` // 0 使用全局设置
+	IdleTimeout    int    `json:"idle_timeout,omitempty"`    // 空闲超时（秒），0 使用全局设置
+}
+
+// RelaySettings 返回四层转发全局设置，未启用时返回 nil
+func (c *Config) RelaySettings() *RelayServerConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Server == nil || c.Server.Relay == nil || !c.Server.Relay.Enabled {
+		return nil
+	}
+	copied := *c.Server.Relay
+	return &copied
+}
+
+// Relays 返回设置了四层转发的条目名称和设置
+func (c *Config) Relays() map[string]RelayConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	relays := make(map[string]RelayConfig)
+	for name, forwarding := range c.Forwardings {
+		if forwarding.Relay != nil {
+			relays[name] = *forwarding.Relay
+		}
+	}
+	return relays
+}
+
+// RelayTarget 返回转发连接使用的当前目标
+func (c *Config) RelayTarget(name string) (string, error) {
+	target, err := c.GetTarget(name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkRelayTarget(target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// SetRelay 为路径条目设置四层转发，正在运行的服务会立即开始监听
+func (c *Config) SetRelay(name string, relay RelayConfig) error {
+	return c.Update(func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if tx.config.Server.Relay == nil || !tx.config.Server.Relay.Enabled {
+			return fmt.Errorf("relay is not enabled")
+		}
+		if relay.MaxConnections < 0 || relay.IdleTimeout < 0 {
+			return fmt.Errorf("max_connections and idle_timeout must not be negative")
+		}
+		if err := checkRelayTarget(forwarding.Target); err != nil {
+			return err
+		}
+
+		listen, err := tx.config.normalizeRelayListen(relay.Listen)
+		if err != nil {
+			return err
+		}
+		for otherName, other := range tx.forwardings {
+			if otherName != name && other.Relay != nil && relayListenConflict(listen, other.Relay.Listen) {
+				return fmt.Errorf("listen address %s is already used by %s", listen, otherName)
+			}
+		}
+
+		relay.Listen = listen
+		forwarding.Relay = &relay
+		forwarding.touch()
+		return nil
+	})
+}
+
+// RemoveRelay 取消路径条目的四层转发
+func (c *Config) RemoveRelay(name string) error {
+	return c.Update(func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if forwarding.Relay == nil {
+			return fmt.Errorf("forwarding has no relay")
+		}
+		forwarding.Relay = nil
+		forwarding.touch()
+		return nil
+	})
+}
+
+// checkRelayTarget 转发只支持 host:port 目标
+func checkRelayTarget(target string) error {
+	if strings.Contains(target, "://") {
+		return fmt.Errorf("relay requires a host:port target, got %q", target)
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return fmt.Errorf("relay requires a host:port target, got %q", target)
+	}
+	return nil
+}
+
+// checkForwardingRelayTarget 修改目标时保证设置了转发的条目仍然是 host:port 目标
+func checkForwardingRelayTarget(forwarding *ForwardingConfig, target string) error {
+	if forwarding == nil || forwarding.Relay == nil {
+		return nil
+	}
+	return checkRelayTarget(target)
+}
+
+// normalizeRelayListen 校验监听地址：主机为空或 IP 字面量，端口在允许范围内，调用方需持有锁
+func (c *Config) normalizeRelayListen(listen string) (string, error) {
+	host, portValue, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q, expected host:port or :port", listen)
+	}
+	port, err := strconv.Atoi(portValue)
+	if err != nil || port < 1 || port > 65535 {
+		return "", fmt.Errorf("invalid listen port %q", portValue)
+	}
+	if host != "" {
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return "", fmt.Errorf("listen host must be an IP address, got %q", host)
+		}
+		host = addr.Unmap().String()
+	}
+
+	allowed, err := relayPortAllowed(c.Server.Relay.AllowedPorts, port)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		if len(c.Server.Relay.AllowedPorts) == 0 {
+			return "", fmt.Errorf("port %d is not allowed, ports below %d must be listed in relay.allowed_ports", port, minUnprivilegedPort)
+		}
+		return "", fmt.Errorf("port %d is not in relay.allowed_ports", port)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// relayPortAllowed 端口是否在 allowed_ports 中，列表为空时允许 1024 以上的端口
+func relayPortAllowed(allowedPorts []string, port int) (bool, error) {
+	if len(allowedPorts) == 0 {
+		return port >= minUnprivilegedPort, nil
+	}
+	for _, value := range allowedPorts {
+		lowValue, highValue, isRange := strings.Cut(strings.TrimSpace(value), "-")
+		if !isRange {
+			highValue = lowValue
+		}
+		low, err1 := strconv.Atoi(lowValue)
+		high, err2 := strconv.Atoi(highValue)
+		if err1 != nil || err2 != nil || low > high {
+			return false, fmt.Errorf("invalid relay.allowed_ports entry %q", value)
+		}
+		if port >= low && port <= high {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// relayListenConflict 两个监听地址端口相同且主机相同或任一为全部地址时冲突
+func relayListenConflict(a, b string) bool {
+	hostA, portA, _ := net.SplitHostPort(a)
+	hostB, portB, _ := net.SplitHostPort(b)
+	return portA == portB && (hostA == hostB || isWildcardHost(hostA) || isWildcardHost(hostB))
+}
+
+func isWildcardHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"redirect_helper/pkg/utils"
+)
+
+// 全局 token 轮换：新 token 立即生效，旧 token 在宽限期内仍然有效，方便逐台更新设备；
+// 每种 token 只保留一个旧 token，宽限期结束后自动删除
+
+const (
+	TokenAdmin    = "admin"
+	TokenRedirect = "redirect"
+	TokenDomain   = "domain"
+
+	// TokenCurrent 和 TokenPrevious 表示请求使用的是当前 token 还是宽限期内的旧 token
+	TokenCurrent  = "current"
+	TokenPrevious = "previous"
+
+	// DefaultTokenGracePeriod 未指定宽限期时旧 token 的有效时间
+	DefaultTokenGracePeriod = 24 * time.Hour
+	// MaxTokenGracePeriod 宽限期上限，避免旧 token 被遗忘后长期有效
+	MaxTokenGracePeriod = 30 * 24 * time.Hour
+)
+
+// RetiredToken 轮换后仍在宽限期内的旧 token
+type RetiredToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RetiredTokenInfo 旧 token 的状态，不包含 token 值
+type RetiredTokenInfo struct {
+	Kind      string    `json:"type"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RotateToken 生成新的全局 token，当前 token 在 grace 时间内继续有效
+// 宽限期内再次轮换时，更早的旧 token 立即失效
+func (c *Config) RotateToken(kind string, grace time.Duration) (string, time.Time, error) {
+	if grace <= 0 || grace > MaxTokenGracePeriod {
+		return "", time.Time{}, fmt.Errorf("grace period must be between 0 and %s", MaxTokenGracePeriod)
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ensureServer()
+	current := c.Server.tokenField(kind)
+	if current == nil {
+		return "", time.Time{}, fmt.Errorf("invalid token type: %s", kind)
+	}
+
+	expiresAt := time.Now().Add(grace).Truncate(time.Second)
+	retired := make(map[string]*RetiredToken, len(c.Server.RetiredTokens)+1)
+	for k, v := range c.Server.RetiredTokens {
+		retired[k] = v
+	}
+	if *current != "" {
+		retired[kind] = &RetiredToken{Token: *current, ExpiresAt: expiresAt}
+	} else {
+		// 没有旧 token 可保留
+		delete(retired, kind)
+		expiresAt = time.Time{}
+	}
+	previousRetired, previousToken := c.Server.RetiredTokens, *current
+	c.Server.RetiredTokens = retired
+	*current = token
+
+	if err := c.save(); err != nil {
+		c.Server.RetiredTokens, *current = previousRetired, previousToken
+		return "", time.Time{}, err
+	}
+	c.emit([]ChangeEvent{{Type: EventTokenRotated, Name: kind, Time: time.Now()}})
+	c.scheduleTokenExpiry()
+	return token, expiresAt, nil
+}
+
+// RetiredTokens 返回仍在宽限期内的旧 token 状态
+func (c *Config) RetiredTokens() []RetiredTokenInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	infos := make([]RetiredTokenInfo, 0)
+	if c.Server == nil {
+		return infos
+	}
+	now := time.Now()
+	for _, kind := range []string{TokenAdmin, TokenRedirect, TokenDomain} {
+		if retired := c.Server.RetiredTokens[kind]; retired != nil && now.Before(retired.ExpiresAt) {
+			infos = append(infos, RetiredTokenInfo{Kind: kind, ExpiresAt: retired.ExpiresAt})
+		}
+	}
+	return infos
+}
+
+// ExpireRetiredTokens 删除已过宽限期的旧 token，每删除一个发出 token.expired 事件
+func (c *Config) ExpireRetiredTokens() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Server == nil || len(c.Server.RetiredTokens) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	retired := make(map[string]*RetiredToken, len(c.Server.RetiredTokens))
+	var expired []ChangeEvent
+	for kind, token := range c.Server.RetiredTokens {
+		if now.Before(token.ExpiresAt) {
+			retired[kind] = token
+			continue
+		}
+		expired = append(expired, ChangeEvent{Type: EventTokenExpired, Name: kind, Time: now})
+	}
+	if len(expired) == 0 {
+		c.scheduleTokenExpiry()
+		return nil
+	}
+
+	if len(retired) == 0 {
+		retired = nil
+	}
+	previous := c.Server.RetiredTokens
+	c.Server.RetiredTokens = retired
+	if err := c.save(); err != nil {
+		c.Server.RetiredTokens = previous
+		return err
+	}
+	c.emit(expired)
+	c.scheduleTokenExpiry()
+	return nil
+}
+
+// IdentifyToken 识别 token 的类型，仅用于日志和统计，不做授权判断
+// kind 为 admin/redirect/domain/api_key/update_token；全局 token 的 slot 为 current 或 previous，
+// API key 的 slot 为用户名；无法识别时返回空字符串
+func (c *Config) IdentifyToken(token string) (kind, slot string) {
+	if token == "" {
+		return "", ""
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, kind := range []string{TokenAdmin, TokenRedirect, TokenDomain} {
+		if slot := c.matchToken(kind, token); slot != "" {
+			return kind, slot
+		}
+	}
+	if user := c.userByAPIKey(token); user != nil {
+		return "api_key", user.Username
+	}
+	for _, forwarding := range c.Forwardings {
+		if matchUpdateToken(forwarding.UpdateTokenHash, token) {
+			return "update_token", ""
+		}
+	}
+	for _, domainConfig := range c.Domains {
+		if matchUpdateToken(domainConfig.UpdateTokenHash, token) {
+			return "update_token", ""
+		}
+	}
+	return "", ""
+}
+
+// matchToken 检查 token 是否为指定类型的当前 token 或未过期的旧 token，返回 current/previous，不匹配时返回空字符串
+// 调用方需持有锁
+func (c *Config) matchToken(kind, token string) string {
+	if c.Server == nil || token == "" {
+		return ""
+	}
+	if current := c.Server.tokenField(kind); current != nil && *current != "" && *current == token {
+		return TokenCurrent
+	}
+	// 已过期但尚未被删除的旧 token 同样视为无效
+	retired := c.Server.RetiredTokens[kind]
+	if retired != nil && retired.Token != "" && time.Now().Before(retired.ExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(retired.Token), []byte(token)) == 1 {
+		return TokenPrevious
+	}
+	return ""
+}
+
+// clearRetiredToken 删除指定类型的旧 token，直接重置 token 时调用，调用方需持有写锁
+func (c *Config) clearRetiredToken(kind string) {
+	if c.Server == nil || c.Server.RetiredTokens[kind] == nil {
+		return
+	}
+	retired := make(map[string]*RetiredToken, len(c.Server.RetiredTokens))
+	for k, v := range c.Server.RetiredTokens {
+		if k != kind {
+			retired[k] = v
+		}
+	}
+	if len(retired) == 0 {
+		retired = nil
+	}
+	c.Server.RetiredTokens = retired
+}
+
+// scheduleTokenExpiry 在最早的旧 token 到期时自动删除它，调用方需持有写锁
+func (c *Config) scheduleTokenExpiry() {
+	if c.expiryTimer != nil {
+		c.expiryTimer.Stop()
+		c.expiryTimer = nil
+	}
+	if c.Server == nil {
+		return
+	}
+
+	var next time.Time
+	for _, retired := range c.Server.RetiredTokens {
+		if next.IsZero() || retired.ExpiresAt.Before(next) {
+			next = retired.ExpiresAt
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	c.expiryTimer = time.AfterFunc(time.Until(next), func() {
+		c.ExpireRetiredTokens()
+	})
+}
+
+// tokenField 返回指定类型 token 字段的指针，类型无效时返回 nil
+func (s *ServerConfig) tokenField(kind string) *string {
+	switch kind {
+	case TokenAdmin:
+		return &s.AdminToken
+	case TokenRedirect:
+		return &s.RedirectToken
+	case TokenDomain:
+		return &s.DomainToken
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// 路由规则：条目可以按访问者的 IP 段、国家、ASN、设备、语言、请求头或查询参数选择不同的目标，
+// 规则按顺序匹配，第一条匹配的规则决定目标，都不匹配时使用条目的 Target。国家和 ASN 来自本地 GeoIP 数据库
+
+const (
+	// maxRouteRules 每个条目的规则数量上限
+	maxRouteRules = 32
+	// maxFieldValue 请求头和查询参数条件中值的长度上限
+	maxFieldValue = 256
+)
+
+// RouteDevices 设备条件可用的取值，由 User-Agent 判断
+var RouteDevices = []string{"ios", "android", "desktop"}
+
+// GeoIPConfig 本地 MaxMind 格式（.mmdb）数据库，例如 GeoLite2-Country 和 GeoLite2-ASN
+type GeoIPConfig struct {
+	Country string `json:"country,omitempty"` // 国家数据库路径，City 数据库也可以
+	ASN     string `json:"asn,omitempty"`     // ASN 数据库路径
+	// ReloadInterval 检查数据库文件是否更新的间隔（秒），默认 60，负数表示只通过 API 重新加载
+	ReloadInterval int `json:"reload_interval,omitempty"`
+}
+
+// RouteRule 一条路由规则，已设置的条件必须全部满足，每个条件的列表中满足任意一项即可
+type RouteRule struct {
+	CIDRs     []string `json:"cidrs,omitempty"`     // 访问者 IP 段，例如 "10.0.0.0/8"，单个 IP 也可以
+	Countries []string `json:"countries,omitempty"` // ISO 3166-1 两位国家代码，例如 "CN"
+	ASNs      []uint32 `json:"asns,omitempty"`      // 自治系统号，例如 4134
+	Devices   []string `json:"devices,omitempty"`   // ios、android 或 desktop
+	// Languages 访问者首选语言（Accept-Language 中优先级最高的一项），"zh" 同时匹配 "zh-CN" 等地区变体
+	Languages []string     `json:"languages,omitempty"`
+	Headers   []FieldMatch `json:"headers,omitempty"` // 请求头
+	Query     []FieldMatch `json:"query,omitempty"`   // 查询参数
+	Target    string       `json:"target"`
+}
+
+// FieldMatch 请求头或查询参数条件：Value 为空时只要求存在，否则要求值完全相同
+type FieldMatch struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// GeoIPSettings 返回 GeoIP 数据库设置，未配置时返回 nil
+func (c *Config) GeoIPSettings() *GeoIPConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Server == nil || c.Server.GeoIP == nil || (c.Server.GeoIP.Country == "" && c.Server.GeoIP.ASN == "") {
+		return nil
+	}
+	copied := *c.Server.GeoIP
+	return &copied
+}
+
+// SetForwardingRoutes 替换路径跳转的路由规则，rules 为空时取消规则
+func (c *Config) SetForwardingRoutes(actor *Actor, name string, rules []RouteRule, extraSelfHosts ...string) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.authorize(forwarding.Owner); err != nil {
+			return err
+		}
+		normalized, err := tx.config.normalizeRoutes(rules, extraSelfHosts)
+		if err != nil {
+			return err
+		}
+		forwarding.Routes = normalized
+		forwarding.touch()
+		return nil
+	})
+}
+
+// SetDomainRoutes 替换域名映射的路由规则，rules 为空时取消规则
+func (c *Config) SetDomainRoutes(actor *Actor, domain string, rules []RouteRule, extraSelfHosts ...string) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.authorize(domainConfig.Owner); err != nil {
+			return err
+		}
+		normalized, err := tx.config.normalizeRoutes(rules, extraSelfHosts)
+		if err != nil {
+			return err
+		}
+		domainConfig.Routes = normalized
+		domainConfig.touch()
+		return nil
+	})
+}
+
+// normalizeRoutes 校验规则并统一格式，返回新的切片；目标按与条目目标相同的策略校验
+func (c *Config) normalizeRoutes(rules []RouteRule, extraSelfHosts []string) ([]RouteRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxRouteRules {
+		return nil, fmt.Errorf("too many routing rules (max %d)", maxRouteRules)
+	}
+
+	normalized := make([]RouteRule, 0, len(rules))
+	for i, rule := range rules {
+		result, err := normalizeRouteRule(rule)
+		if err == nil {
+			err = c.ValidateTarget(rule.Target, extraSelfHosts...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		normalized = append(normalized, result)
+	}
+	return normalized, nil
+}
+
+// NormalizeRoutes 校验规则但不保存，用于试算尚未保存的规则
+func (c *Config) NormalizeRoutes(rules []RouteRule, extraSelfHosts ...string) ([]RouteRule, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.normalizeRoutes(rules, extraSelfHosts)
+}
+
+// normalizeRouteRule 校验一条规则的条件：IP 段转为网络地址，国家代码转为大写，语言转为小写，请求头名称转为规范格式
+func normalizeRouteRule(rule RouteRule) (RouteRule, error) {
+	result := RouteRule{Target: rule.Target}
+	if rule.Target == "" {
+		return result, fmt.Errorf("target is required")
+	}
+
+	for _, value := range rule.CIDRs {
+		prefix, err := parseRoutePrefix(value)
+		if err != nil {
+			return result, err
+		}
+		result.CIDRs = append(result.CIDRs, prefix.String())
+	}
+	for _, value := range rule.Countries {
+		country := strings.ToUpper(strings.TrimSpace(value))
+		if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+			return result, fmt.Errorf("invalid country code %q, expected two letters such as CN", value)
+		}
+		result.Countries = append(result.Countries, country)
+	}
+	for _, asn := range rule.ASNs {
+		if asn == 0 {
+			return result, fmt.Errorf("invalid ASN 0")
+		}
+		result.ASNs = append(result.ASNs, asn)
+	}
+
+	for _, value := range rule.Devices {
+		device := strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(RouteDevices, device) {
+			return result, fmt.Errorf("invalid device %q, expected one of: %s", value, strings.Join(RouteDevices, ", "))
+		}
+		result.Devices = append(result.Devices, device)
+	}
+	for _, value := range rule.Languages {
+		language, err := normalizeLanguage(value)
+		if err != nil {
+			return result, err
+		}
+		result.Languages = append(result.Languages, language)
+	}
+	for _, field := range rule.Headers {
+		name := strings.TrimSpace(field.Name)
+		if !validHeaderName(name) {
+			return result, fmt.Errorf("invalid header name %q", field.Name)
+		}
+		if len(field.Value) > maxFieldValue {
+			return result, fmt.Errorf("value of header %s is too long (max %d)", name, maxFieldValue)
+		}
+		result.Headers = append(result.Headers, FieldMatch{Name: http.CanonicalHeaderKey(name), Value: field.Value})
+	}
+	for _, field := range rule.Query {
+		if field.Name == "" {
+			return result, fmt.Errorf("query parameter name is required")
+		}
+		if len(field.Name) > maxFieldValue || len(field.Value) > maxFieldValue {
+			return result, fmt.Errorf("query parameter %q is too long (max %d)", field.Name, maxFieldValue)
+		}
+		result.Query = append(result.Query, field)
+	}
+
+	// 没有条件的规则总是匹配，会使后面的规则失效，默认目标应直接设置为条目的目标
+	if len(result.CIDRs) == 0 && len(result.Countries) == 0 && len(result.ASNs) == 0 &&
+		len(result.Devices) == 0 && len(result.Languages) == 0 && len(result.Headers) == 0 && len(result.Query) == 0 {
+		return result, fmt.Errorf("at least one condition is required")
+	}
+	return result, nil
+}
+
+// normalizeLanguage 校验语言标签（例如 "zh"、"zh-CN"、"zh-Hant-TW"）并转为小写
+func normalizeLanguage(value string) (string, error) {
+	language := strings.ToLower(strings.TrimSpace(value))
+	subtags := strings.Split(language, "-")
+	valid := len(subtags[0]) >= 2 && len(subtags[0]) <= 3
+	for _, subtag := range subtags {
+		if len(subtag) == 0 || len(subtag) > 8 {
+			valid = false
+		}
+		for _, ch := range subtag {
+			if (ch < 'a' || ch > 'z') && (ch < '0' || ch > '9') {
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid language %q, expected a tag such as zh or en-US", value)
+	}
+	return language, nil
+}
+
+// validHeaderName 请求头名称只能包含 token 字符（RFC 9110）
+func validHeaderName(name string) bool {
+	if name == "" || len(name) > maxFieldValue {
+		return false
+	}
+	for _, ch := range name {
+		if ch > 0x7e || ch <= 0x20 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRoutePrefix 解析 IP 段，单个 IP 视为 /32 或 /128
+func parseRoutePrefix(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", value)
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,415 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"redirect_helper/internal/validation"
+)
+
+// Tx 配置修改事务
+// 修改先作用在条目副本上，提交时一次性替换并只保存一次；保存失败时回滚内存状态
+type Tx struct {
+	config      *Config
+	forwardings map[string]*ForwardingConfig
+	domains     map[string]*DomainConfig
+
+	// actor 为 nil 时不限制条目归属（命令行、admin token 和全局 redirect/domain token）
+	actor *Actor
+}
+
+// Update 在写锁内执行 fn：fn 返回 nil 时提交并保存，返回错误时丢弃全部修改
+func (c *Config) Update(fn func(tx *Tx) error) error {
+	return c.UpdateAs(nil, fn)
+}
+
+// UpdateAs 与 Update 相同，但以 actor 的身份执行：只能修改自己的条目，新建条目归属 actor 并检查其配额
+func (c *Config) UpdateAs(actor *Actor, fn func(tx *Tx) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx := c.begin()
+	tx.actor = actor
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// SetActor 在事务中切换身份，用于事务内才能确定调用方身份的场景
+func (tx *Tx) SetActor(actor *Actor) {
+	tx.actor = actor
+}
+
+// authorize 检查当前身份能否修改属于 owner 的条目
+func (tx *Tx) authorize(owner string) error {
+	if tx.actor == nil || tx.actor.CanAccess(owner) {
+		return nil
+	}
+	return ErrPermissionDenied
+}
+
+// authorizeForwarding 检查当前身份能否修改已存在的路径跳转，条目不存在时不做限制
+func (tx *Tx) authorizeForwarding(name string) error {
+	if forwarding, exists := tx.forwardings[name]; exists {
+		return tx.authorize(forwarding.Owner)
+	}
+	return nil
+}
+
+// authorizeDomain 检查当前身份能否修改已存在的域名映射，条目不存在时不做限制
+func (tx *Tx) authorizeDomain(domain string) error {
+	if domainConfig, exists := tx.domains[domainKey(domain)]; exists {
+		return tx.authorize(domainConfig.Owner)
+	}
+	return nil
+}
+
+// owner 新建条目的所属用户
+func (tx *Tx) owner() string {
+	if tx.actor == nil {
+		return ""
+	}
+	return tx.actor.User
+}
+
+// checkQuota 检查普通用户自己的条目数量上限，管理员只受全局上限限制
+func (tx *Tx) checkQuota(forwarding bool) error {
+	if tx.actor == nil || tx.actor.Admin {
+		return nil
+	}
+	user, exists := tx.config.Users[tx.actor.User]
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+
+	forwardings, domains := tx.config.countOwned(tx.forwardings, tx.domains, user.Username)
+	if forwarding {
+		if limit := tx.config.userRedirectLimit(user); forwardings >= limit {
+			return fmt.Errorf("maximum redirect count for user %s (%d) reached", user.Username, limit)
+		}
+		return nil
+	}
+	if limit := tx.config.userDomainLimit(user); domains >= limit {
+		return fmt.Errorf("maximum domain count for user %s (%d) reached", user.Username, limit)
+	}
+	return nil
+}
+
+// begin 复制当前条目作为事务的工作副本，调用方需持有写锁
+func (c *Config) begin() *Tx {
+	tx := &Tx{
+		config:      c,
+		forwardings: make(map[string]*ForwardingConfig, len(c.Forwardings)),
+		domains:     make(map[string]*DomainConfig, len(c.Domains)),
+	}
+	for name, forwarding := range c.Forwardings {
+		copied := *forwarding
+		tx.forwardings[name] = &copied
+	}
+	for domain, domainConfig := range c.Domains {
+		copied := *domainConfig
+		tx.domains[domain] = &copied
+	}
+	return tx
+}
+
+// commit 用工作副本替换当前条目并保存，保存失败时恢复原状态，成功后通知变更监听器
+func (tx *Tx) commit() error {
+	c := tx.config
+	oldForwardings, oldDomains := c.Forwardings, c.Domains
+
+	c.Forwardings, c.Domains = tx.forwardings, tx.domains
+	if err := c.save(); err != nil {
+		c.Forwardings, c.Domains = oldForwardings, oldDomains
+		return err
+	}
+
+	c.emit(diffChanges(oldForwardings, tx.forwardings, oldDomains, tx.domains))
+	return nil
+}
+
+// 以下方法在修改前完成全部校验，失败时不会留下部分修改
+
+func (tx *Tx) AddForwarding(name string) error {
+	if _, exists := tx.forwardings[name]; exists {
+		return fmt.Errorf("forwarding name already exists")
+	}
+
+	if err := tx.config.ValidateName(name); err != nil {
+		return err
+	}
+
+	// Check max redirect count
+	if len(tx.forwardings) >= tx.config.Server.MaxRedirectCount {
+		return fmt.Errorf("maximum redirect count (%d) reached", tx.config.Server.MaxRedirectCount)
+	}
+	if err := tx.checkQuota(true); err != nil {
+		return err
+	}
+
+	tx.forwardings[name] = &ForwardingConfig{
+		Name:      name,
+		Target:    "",
+		Version:   1,
+		Owner:     tx.owner(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (tx *Tx) SetTarget(name, token, target string, extraSelfHosts ...string) error {
+	// Validate redirect token
+	if !tx.config.validateRedirectToken(token) {
+		return fmt.Errorf("invalid redirect token")
+	}
+
+	return tx.setTarget(name, target, extraSelfHosts...)
+}
+
+// setTarget 创建或更新路径跳转的目标，token 由调用方校验
+func (tx *Tx) setTarget(name, target string, extraSelfHosts ...string) error {
+	if err := tx.authorizeForwarding(name); err != nil {
+		return err
+	}
+	if err := tx.config.ValidateTarget(target, extraSelfHosts...); err != nil {
+		return err
+	}
+
+	// Create forwarding if it doesn't exist
+	existing, exists := tx.forwardings[name]
+	if err := checkForwardingRelayTarget(existing, target); err != nil {
+		return err
+	}
+	if !exists {
+		if err := tx.AddForwarding(name); err != nil {
+			return err
+		}
+	}
+
+	forwarding := tx.forwardings[name]
+	forwarding.Target = target
+	if exists {
+		forwarding.touch()
+	}
+	return nil
+}
+
+func (tx *Tx) UpdateTarget(name, target string) error {
+	forwarding, exists := tx.forwardings[name]
+	if !exists {
+		return fmt.Errorf("forwarding name not found")
+	}
+	if err := tx.authorize(forwarding.Owner); err != nil {
+		return err
+	}
+
+	if err := tx.config.ValidateTarget(target); err != nil {
+		return err
+	}
+	if err := checkForwardingRelayTarget(forwarding, target); err != nil {
+		return err
+	}
+
+	forwarding.Target = target
+	forwarding.touch()
+	return nil
+}
+
+func (tx *Tx) RemoveForwarding(name string) error {
+	forwarding, exists := tx.forwardings[name]
+	if !exists {
+		return fmt.Errorf("forwarding name not found")
+	}
+	if err := tx.authorize(forwarding.Owner); err != nil {
+		return err
+	}
+
+	delete(tx.forwardings, name)
+	return nil
+}
+
+// RenameForwarding 重命名条目，target 非空时同时更新目标
+func (tx *Tx) RenameForwarding(name, newName, target string, extraSelfHosts ...string) error {
+	forwarding, exists := tx.forwardings[name]
+	if !exists {
+		return fmt.Errorf("forwarding name not found")
+	}
+	if err := tx.authorize(forwarding.Owner); err != nil {
+		return err
+	}
+	if _, exists := tx.forwardings[newName]; exists {
+		return fmt.Errorf("forwarding name already exists")
+	}
+
+	if err := tx.config.ValidateName(newName); err != nil {
+		return err
+	}
+	if target != "" {
+		if err := tx.config.ValidateTarget(target, extraSelfHosts...); err != nil {
+			return err
+		}
+		if err := checkForwardingRelayTarget(forwarding, target); err != nil {
+			return err
+		}
+		forwarding.Target = target
+	}
+
+	delete(tx.forwardings, name)
+	forwarding.Name = newName
+	forwarding.touch()
+	tx.forwardings[newName] = forwarding
+	return nil
+}
+
+func (tx *Tx) AddDomain(domain string) error {
+	domain, err := validation.NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := tx.domains[domain]; exists {
+		return fmt.Errorf("domain already exists")
+	}
+
+	// Check max domain count
+	if len(tx.domains) >= tx.config.Server.MaxDomainCount {
+		return fmt.Errorf("maximum domain count (%d) reached", tx.config.Server.MaxDomainCount)
+	}
+	if err := tx.checkQuota(false); err != nil {
+		return err
+	}
+	verification, err := tx.config.newVerification()
+	if err != nil {
+		return err
+	}
+
+	tx.domains[domain] = &DomainConfig{
+		Domain:       domain,
+		Target:       "",
+		Version:      1,
+		Owner:        tx.owner(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Verification: verification,
+	}
+	return nil
+}
+
+func (tx *Tx) SetDomainTarget(domain, token, target string, extraSelfHosts ...string) error {
+	// Validate domain token
+	if !tx.config.validateDomainToken(token) {
+		return fmt.Errorf("invalid domain token")
+	}
+
+	return tx.setDomainTarget(domain, target, extraSelfHosts...)
+}
+
+// setDomainTarget 创建或更新域名映射的目标，token 由调用方校验
+func (tx *Tx) setDomainTarget(domain, target string, extraSelfHosts ...string) error {
+	domain, err := validation.NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	if err := tx.authorizeDomain(domain); err != nil {
+		return err
+	}
+
+	// 目标指向域名自身会造成跳转循环
+	if err := tx.config.ValidateTarget(target, append([]string{domain}, extraSelfHosts...)...); err != nil {
+		return err
+	}
+
+	// Create domain if it doesn't exist
+	_, exists := tx.domains[domain]
+	if !exists {
+		if err := tx.AddDomain(domain); err != nil {
+			return err
+		}
+	}
+
+	domainConfig := tx.domains[domain]
+	domainConfig.Target = target
+	if exists {
+		domainConfig.touch()
+	}
+	return nil
+}
+
+func (tx *Tx) UpdateDomainTarget(domain, target string) error {
+	domain = domainKey(domain)
+	domainConfig, exists := tx.domains[domain]
+	if !exists {
+		return fmt.Errorf("domain not found")
+	}
+	if err := tx.authorize(domainConfig.Owner); err != nil {
+		return err
+	}
+
+	if err := tx.config.ValidateTarget(target, domain); err != nil {
+		return err
+	}
+
+	domainConfig.Target = target
+	domainConfig.touch()
+	return nil
+}
+
+func (tx *Tx) RemoveDomain(domain string) error {
+	domain = domainKey(domain)
+	domainConfig, exists := tx.domains[domain]
+	if !exists {
+		return fmt.Errorf("domain not found")
+	}
+	if err := tx.authorize(domainConfig.Owner); err != nil {
+		return err
+	}
+
+	delete(tx.domains, domain)
+	return nil
+}
+
+// RenameDomain 重命名域名映射，target 非空时同时更新目标
+func (tx *Tx) RenameDomain(domain, newDomain, target string, extraSelfHosts ...string) error {
+	domain = domainKey(domain)
+	domainConfig, exists := tx.domains[domain]
+	if !exists {
+		return fmt.Errorf("domain not found")
+	}
+	if err := tx.authorize(domainConfig.Owner); err != nil {
+		return err
+	}
+
+	newDomain, err := validation.NormalizeDomain(newDomain)
+	if err != nil {
+		return err
+	}
+	if _, exists := tx.domains[newDomain]; exists {
+		return fmt.Errorf("domain already exists")
+	}
+
+	if target == "" {
+		target = domainConfig.Target
+	}
+	// 新域名可能与现有目标构成跳转循环，需要重新校验
+	if target != "" {
+		if err := tx.config.ValidateTarget(target, append([]string{newDomain}, extraSelfHosts...)...); err != nil {
+			return err
+		}
+	}
+
+	// 新域名需要重新证明所有权
+	verification, err := tx.config.newVerification()
+	if err != nil {
+		return err
+	}
+
+	delete(tx.domains, domain)
+	domainConfig.Domain = newDomain
+	domainConfig.Target = target
+	domainConfig.Verification = verification
+	domainConfig.touch()
+	tx.domains[newDomain] = domainConfig
+	return nil
+}
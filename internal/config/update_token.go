@@ -0,0 +1,135 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"redirect_helper/pkg/utils"
+)
+
+// 条目自己的更新 token：只能通过 /api/update、/api/update-domain 和 /nic/update 修改该条目的目标，
+// 适合分发给只负责更新一个名称的动态 IP 设备。配置中只保存 SHA-256 哈希，token 只在生成时显示一次
+
+// RotateForwardingUpdateToken 为路径跳转生成新的更新 token，旧 token 立即失效
+func (c *Config) RotateForwardingUpdateToken(actor *Actor, name string) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	err = c.UpdateAs(actor, func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.authorize(forwarding.Owner); err != nil {
+			return err
+		}
+		forwarding.UpdateTokenHash = hashAPIKey(token)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeForwardingUpdateToken 删除路径跳转的更新 token
+func (c *Config) RevokeForwardingUpdateToken(actor *Actor, name string) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.authorize(forwarding.Owner); err != nil {
+			return err
+		}
+		forwarding.UpdateTokenHash = ""
+		return nil
+	})
+}
+
+// RotateDomainUpdateToken 为域名映射生成新的更新 token，旧 token 立即失效
+func (c *Config) RotateDomainUpdateToken(actor *Actor, domain string) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	err = c.UpdateAs(actor, func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.authorize(domainConfig.Owner); err != nil {
+			return err
+		}
+		domainConfig.UpdateTokenHash = hashAPIKey(token)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeDomainUpdateToken 删除域名映射的更新 token
+func (c *Config) RevokeDomainUpdateToken(actor *Actor, domain string) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.authorize(domainConfig.Owner); err != nil {
+			return err
+		}
+		domainConfig.UpdateTokenHash = ""
+		return nil
+	})
+}
+
+// CanUpdateForwarding 检查 token 能否修改该路径跳转：全局 redirect token、所属用户（或管理员）的 API key 或条目自己的更新 token
+func (c *Config) CanUpdateForwarding(name, token string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	forwarding, exists := c.Forwardings[name]
+	if !exists {
+		return false
+	}
+	if actor, ok := c.tokenActor(token, c.validateRedirectToken); ok {
+		return actor == nil || actor.CanAccess(forwarding.Owner)
+	}
+	return matchUpdateToken(forwarding.UpdateTokenHash, token)
+}
+
+// CanUpdateDomain 检查 token 能否修改该域名映射：全局 domain token、所属用户（或管理员）的 API key 或条目自己的更新 token
+func (c *Config) CanUpdateDomain(domain, token string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	domainConfig, exists := c.Domains[domainKey(domain)]
+	if !exists {
+		return false
+	}
+	if actor, ok := c.tokenActor(token, c.validateDomainToken); ok {
+		return actor == nil || actor.CanAccess(domainConfig.Owner)
+	}
+	return matchUpdateToken(domainConfig.UpdateTokenHash, token)
+}
+
+// validForwardingUpdateToken 检查 token 是否为已存在条目自己的更新 token
+func (tx *Tx) validForwardingUpdateToken(name, token string) bool {
+	forwarding, exists := tx.forwardings[name]
+	return exists && matchUpdateToken(forwarding.UpdateTokenHash, token)
+}
+
+// validDomainUpdateToken 检查 token 是否为已存在域名映射自己的更新 token
+func (tx *Tx) validDomainUpdateToken(domain, token string) bool {
+	domainConfig, exists := tx.domains[domainKey(domain)]
+	return exists && matchUpdateToken(domainConfig.UpdateTokenHash, token)
+}
+
+func matchUpdateToken(hash, token string) bool {
+	return hash != "" && token != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(token))) == 1
+}
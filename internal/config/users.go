@@ -0,0 +1,370 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"redirect_helper/pkg/utils"
+)
+
+// ErrPermissionDenied 普通用户试图修改属于其他用户的条目
+var ErrPermissionDenied = errors.New("entry belongs to another user")
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,31}$`)
+
+// UserConfig 用户账户，可以用密码登录管理界面，或用 API key 调用接口
+type UserConfig struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash,omitempty"` // bcrypt
+	APIKeyHash   string `json:"api_key_hash,omitempty"`  // SHA-256，API key 只在生成时显示一次
+	Admin        bool   `json:"admin,omitempty"`         // 管理员可以查看和修改所有条目
+	Disabled     bool   `json:"disabled,omitempty"`
+
+	// 该用户自己的条目数量上限，0 表示使用全局的 max_redirect_count / max_domain_count
+	MaxRedirectCount int `json:"max_redirect_count,omitempty"`
+	MaxDomainCount   int `json:"max_domain_count,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserInfo 用户信息，不包含凭据
+type UserInfo struct {
+	Username         string    `json:"username"`
+	Admin            bool      `json:"admin"`
+	Disabled         bool      `json:"disabled"`
+	HasPassword      bool      `json:"has_password"`
+	HasAPIKey        bool      `json:"has_api_key"`
+	MaxRedirectCount int       `json:"max_redirect_count"`
+	MaxDomainCount   int       `json:"max_domain_count"`
+	Forwardings      int       `json:"forwardings"`
+	Domains          int       `json:"domains"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Actor 执行操作的身份
+// 普通用户只能查看和修改自己的条目，新建的条目归属该用户并计入其配额；管理员不受限制
+type Actor struct {
+	User  string // 用户名；admin token 登录时为空
+	Admin bool
+}
+
+// CanAccess 是否可以查看和修改属于 owner 的条目
+func (a Actor) CanAccess(owner string) bool {
+	return a.Admin || (a.User != "" && a.User == owner)
+}
+
+// AddUser 创建用户并生成 API key，quota 为 0 时使用全局上限
+func (c *Config) AddUser(username string, admin bool, maxRedirectCount, maxDomainCount int) (string, error) {
+	if !usernamePattern.MatchString(username) {
+		return "", fmt.Errorf("invalid username %q: 1-32 letters, digits, '.', '_' or '-', starting with a letter or digit", username)
+	}
+	if maxRedirectCount < 0 || maxDomainCount < 0 {
+		return "", fmt.Errorf("quota must not be negative")
+	}
+
+	apiKey, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate API key: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.Users[username]; exists {
+		return "", fmt.Errorf("user already exists")
+	}
+	if c.Users == nil {
+		c.Users = make(map[string]*UserConfig)
+	}
+	c.Users[username] = &UserConfig{
+		Username:         username,
+		APIKeyHash:       hashAPIKey(apiKey),
+		Admin:            admin,
+		MaxRedirectCount: maxRedirectCount,
+		MaxDomainCount:   maxDomainCount,
+		CreatedAt:        time.Now(),
+	}
+	if err := c.save(); err != nil {
+		delete(c.Users, username)
+		return "", err
+	}
+	return apiKey, nil
+}
+
+// RemoveUser 删除用户，其条目保留原 owner，只有管理员可见
+func (c *Config) RemoveUser(username string) error {
+	return c.updateUser(username, func(users map[string]*UserConfig, user *UserConfig) {
+		delete(users, username)
+	})
+}
+
+// SetUserPassword 设置用户的登录密码
+func (c *Config) SetUserPassword(username, password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	return c.updateUser(username, func(users map[string]*UserConfig, user *UserConfig) {
+		user.PasswordHash = string(hash)
+	})
+}
+
+// ResetUserAPIKey 生成新的 API key，旧 key 立即失效
+func (c *Config) ResetUserAPIKey(username string) (string, error) {
+	apiKey, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate API key: %v", err)
+	}
+
+	err = c.updateUser(username, func(users map[string]*UserConfig, user *UserConfig) {
+		user.APIKeyHash = hashAPIKey(apiKey)
+	})
+	if err != nil {
+		return "", err
+	}
+	return apiKey, nil
+}
+
+// SetUserQuota 设置用户的条目数量上限，0 表示使用全局上限
+func (c *Config) SetUserQuota(username string, maxRedirectCount, maxDomainCount int) error {
+	if maxRedirectCount < 0 || maxDomainCount < 0 {
+		return fmt.Errorf("quota must not be negative")
+	}
+	return c.updateUser(username, func(users map[string]*UserConfig, user *UserConfig) {
+		user.MaxRedirectCount = maxRedirectCount
+		user.MaxDomainCount = maxDomainCount
+	})
+}
+
+// SetUserDisabled 禁用或启用用户，禁用后密码、API key 和已登录的会话都不再有效
+func (c *Config) SetUserDisabled(username string, disabled bool) error {
+	return c.updateUser(username, func(users map[string]*UserConfig, user *UserConfig) {
+		user.Disabled = disabled
+	})
+}
+
+// updateUser 在写锁内修改用户并保存，保存失败时恢复原状态
+func (c *Config) updateUser(username string, fn func(users map[string]*UserConfig, user *UserConfig)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, exists := c.Users[username]
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+
+	oldUsers := c.Users
+	users := make(map[string]*UserConfig, len(oldUsers))
+	for name, u := range oldUsers {
+		users[name] = u
+	}
+	copied := *user
+	users[username] = &copied
+	fn(users, &copied)
+
+	c.Users = users
+	if err := c.save(); err != nil {
+		c.Users = oldUsers
+		return err
+	}
+	return nil
+}
+
+// SetForwardingOwner 修改路径跳转的所属用户，owner 为空表示只有管理员可见
+func (c *Config) SetForwardingOwner(name, owner string) error {
+	return c.Update(func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.checkOwner(owner); err != nil {
+			return err
+		}
+		forwarding.Owner = owner
+		forwarding.touch()
+		return nil
+	})
+}
+
+// SetDomainOwner 修改域名映射的所属用户，owner 为空表示只有管理员可见
+func (c *Config) SetDomainOwner(domain, owner string) error {
+	return c.Update(func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.checkOwner(owner); err != nil {
+			return err
+		}
+		domainConfig.Owner = owner
+		domainConfig.touch()
+		return nil
+	})
+}
+
+// checkOwner 检查 owner 是否为已存在的用户
+func (tx *Tx) checkOwner(owner string) error {
+	if owner == "" {
+		return nil
+	}
+	if _, exists := tx.config.Users[owner]; !exists {
+		return fmt.Errorf("user %q not found", owner)
+	}
+	return nil
+}
+
+// ListUsers 返回按用户名排序的用户列表和各自的条目数量
+func (c *Config) ListUsers() []UserInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]UserInfo, 0, len(c.Users))
+	for _, user := range c.Users {
+		info := UserInfo{
+			Username:         user.Username,
+			Admin:            user.Admin,
+			Disabled:         user.Disabled,
+			HasPassword:      user.PasswordHash != "",
+			HasAPIKey:        user.APIKeyHash != "",
+			MaxRedirectCount: c.userRedirectLimit(user),
+			MaxDomainCount:   c.userDomainLimit(user),
+			CreatedAt:        user.CreatedAt,
+		}
+		info.Forwardings, info.Domains = c.countOwned(c.Forwardings, c.Domains, user.Username)
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Username < result[j].Username
+	})
+	return result
+}
+
+// LookupActor 返回用户当前的身份，用户不存在或已禁用时返回 false
+func (c *Config) LookupActor(username string) (Actor, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	user, exists := c.Users[username]
+	if !exists || user.Disabled {
+		return Actor{}, false
+	}
+	return Actor{User: user.Username, Admin: user.Admin}, true
+}
+
+// HasUser 用户是否存在（包括已禁用的用户）
+func (c *Config) HasUser(username string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, exists := c.Users[username]
+	return exists
+}
+
+// AuthenticateAPIKey 根据 API key 查找用户
+func (c *Config) AuthenticateAPIKey(apiKey string) (Actor, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	user := c.userByAPIKey(apiKey)
+	if user == nil {
+		return Actor{}, false
+	}
+	return Actor{User: user.Username, Admin: user.Admin}, true
+}
+
+// AuthenticatePassword 校验用户名和密码：先匹配 server.admin_username，再匹配用户账户
+func (c *Config) AuthenticatePassword(username, password string) (Actor, bool) {
+	c.mu.RLock()
+	user, exists := c.Users[username]
+	hash := ""
+	if exists && !user.Disabled {
+		hash = user.PasswordHash
+	}
+	c.mu.RUnlock()
+
+	if c.ValidateAdminPassword(username, password) {
+		return Actor{Admin: true}, true
+	}
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return Actor{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return Actor{}, false
+	}
+	return Actor{User: user.Username, Admin: user.Admin}, true
+}
+
+// tokenActor 解析写接口的 token：全局 token 不限制条目归属（返回 nil），用户 API key 返回该用户，调用方需持有锁
+func (c *Config) tokenActor(token string, validateGlobal func(string) bool) (*Actor, bool) {
+	if validateGlobal(token) {
+		return nil, true
+	}
+	if user := c.userByAPIKey(token); user != nil {
+		return &Actor{User: user.Username, Admin: user.Admin}, true
+	}
+	return nil, false
+}
+
+// userByAPIKey 查找 API key 对应的有效用户，调用方需持有锁
+func (c *Config) userByAPIKey(apiKey string) *UserConfig {
+	if apiKey == "" || len(c.Users) == 0 {
+		return nil
+	}
+	hash := []byte(hashAPIKey(apiKey))
+	for _, user := range c.Users {
+		if user.APIKeyHash != "" && !user.Disabled && subtle.ConstantTimeCompare(hash, []byte(user.APIKeyHash)) == 1 {
+			return user
+		}
+	}
+	return nil
+}
+
+// userRedirectLimit 用户的路径跳转数量上限，调用方需持有锁
+func (c *Config) userRedirectLimit(user *UserConfig) int {
+	if user.MaxRedirectCount > 0 {
+		return user.MaxRedirectCount
+	}
+	return c.Server.MaxRedirectCount
+}
+
+// userDomainLimit 用户的域名映射数量上限，调用方需持有锁
+func (c *Config) userDomainLimit(user *UserConfig) int {
+	if user.MaxDomainCount > 0 {
+		return user.MaxDomainCount
+	}
+	return c.Server.MaxDomainCount
+}
+
+// countOwned 统计属于 owner 的条目数量
+func (c *Config) countOwned(forwardings map[string]*ForwardingConfig, domains map[string]*DomainConfig, owner string) (int, int) {
+	forwardingCount, domainCount := 0, 0
+	for _, forwarding := range forwardings {
+		if forwarding.Owner == owner {
+			forwardingCount++
+		}
+	}
+	for _, domainConfig := range domains {
+		if domainConfig.Owner == owner {
+			domainCount++
+		}
+	}
+	return forwardingCount, domainCount
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
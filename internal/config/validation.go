@@ -0,0 +1,41 @@
+package config
+
+import (
+	"redirect_helper/internal/validation"
+)
+
+// TargetPolicy 根据服务器配置构建目标地址校验策略
+// extraSelfHosts 用于追加当前请求的 Host 或正在映射的域名，以检测跳转循环
+func (c *Config) TargetPolicy(extraSelfHosts ...string) validation.TargetPolicy {
+	policy := validation.TargetPolicy{}
+	if c.Server != nil {
+		policy.AllowedSchemes = c.Server.AllowedSchemes
+		policy.AllowHosts = c.Server.TargetAllowHosts
+		policy.DenyHosts = c.Server.TargetDenyHosts
+		policy.SelfHosts = append(policy.SelfHosts, c.Server.PublicHosts...)
+	}
+	policy.SelfHosts = append(policy.SelfHosts, extraSelfHosts...)
+	return policy
+}
+
+// ValidateName 校验新建的路径跳转名称
+func (c *Config) ValidateName(name string) error {
+	var reserved []string
+	if c.Server != nil {
+		reserved = c.Server.ReservedNames
+	}
+	return validation.ValidateName(name, reserved)
+}
+
+// ValidateTarget 按服务器配置校验跳转目标
+func (c *Config) ValidateTarget(target string, extraSelfHosts ...string) error {
+	return validation.ValidateTarget(target, c.TargetPolicy(extraSelfHosts...))
+}
+
+// domainKey 返回域名在 Domains 中的键；无法规范化时原样返回，兼容旧配置
+func domainKey(domain string) string {
+	if normalized, err := validation.NormalizeDomain(domain); err == nil {
+		return normalized
+	}
+	return domain
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"redirect_helper/pkg/utils"
+)
+
+// 域名所有权验证：启用后新建（或重命名得到）的域名映射处于 pending 状态，不参与跳转、证书签发和 DNS 应答，
+// 直到 DNS TXT 记录或 HTTP well-known 文件中出现创建时返回的 challenge
+
+const (
+	VerificationPending  = "pending"
+	VerificationVerified = "verified"
+	VerificationFailed   = "failed"
+
+	// 验证方式
+	VerificationMethodDNS    = "dns"
+	VerificationMethodHTTP   = "http"
+	VerificationMethodManual = "manual"
+
+	// ChallengeDNSPrefix TXT 记录名为 <prefix><域名>
+	ChallengeDNSPrefix = "_redirect-helper-challenge."
+	// ChallengeHTTPPath HTTP 验证文件路径为 http://<域名><path><challenge>，内容为 challenge
+	ChallengeHTTPPath = "/.well-known/redirect-helper-challenge/"
+
+	defaultVerificationInterval    = time.Minute
+	maxVerificationInterval        = time.Hour
+	defaultVerificationMaxAttempts = 48
+)
+
+// DomainVerificationConfig 域名所有权验证配置
+type DomainVerificationConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Resolver      string `json:"resolver,omitempty"`       // 查询 TXT 记录的 DNS 服务器，例如 "1.1.1.1:53"，为空时使用系统解析器
+	RetryInterval int    `json:"retry_interval,omitempty"` // 首次重试间隔（秒），之后每次翻倍，最长 1 小时，默认 60
+	MaxAttempts   int    `json:"max_attempts,omitempty"`   // 达到次数后标记为 failed，默认 48
+}
+
+// DomainVerification 域名映射的验证状态
+type DomainVerification struct {
+	Challenge     string     `json:"challenge"`
+	Status        string     `json:"status"`
+	Method        string     `json:"method,omitempty"` // 验证通过的方式
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	NextCheckAt   *time.Time `json:"next_check_at,omitempty"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+}
+
+// PendingVerification 等待检查的域名和 challenge
+type PendingVerification struct {
+	Domain    string
+	Challenge string
+}
+
+// Active 域名映射是否生效：未要求验证或已通过验证
+func (d *DomainConfig) Active() bool {
+	return d.Verification == nil || d.Verification.Status == VerificationVerified
+}
+
+// VerificationConfig 返回域名验证配置，未启用时返回 nil
+func (c *Config) VerificationConfig() *DomainVerificationConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Server == nil || c.Server.DomainVerification == nil || !c.Server.DomainVerification.Enabled {
+		return nil
+	}
+	copied := *c.Server.DomainVerification
+	return &copied
+}
+
+// DueVerifications 返回已到检查时间的 pending 域名
+func (c *Config) DueVerifications(now time.Time) []PendingVerification {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var due []PendingVerification
+	for domain, domainConfig := range c.Domains {
+		v := domainConfig.Verification
+		if v == nil || v.Status != VerificationPending {
+			continue
+		}
+		if v.NextCheckAt == nil || !now.Before(*v.NextCheckAt) {
+			due = append(due, PendingVerification{Domain: domain, Challenge: v.Challenge})
+		}
+	}
+	return due
+}
+
+// RecordVerification 记录一次检查结果：checkErr 为 nil 时标记为已验证，否则增加重试次数并安排下次检查
+// challenge 与当前值不一致（检查期间被重命名或重新生成）时忽略结果
+func (c *Config) RecordVerification(domain, challenge, method string, checkErr error) error {
+	return c.Update(func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists || domainConfig.Verification == nil || domainConfig.Verification.Challenge != challenge {
+			return nil
+		}
+
+		now := time.Now()
+		v := *domainConfig.Verification
+		v.Attempts++
+		v.LastCheckedAt = &now
+		if checkErr == nil {
+			v.Status = VerificationVerified
+			v.Method = method
+			v.LastError = ""
+			v.NextCheckAt = nil
+			v.VerifiedAt = &now
+		} else {
+			v.LastError = checkErr.Error()
+			if v.Attempts >= tx.config.verificationMaxAttempts() {
+				v.Status = VerificationFailed
+				v.NextCheckAt = nil
+			} else {
+				next := now.Add(tx.config.verificationBackoff(v.Attempts))
+				v.NextCheckAt = &next
+			}
+		}
+		domainConfig.Verification = &v
+		return nil
+	})
+}
+
+// RetryVerification 重置重试次数并让域名在下一轮立即检查，已验证的域名不受影响
+func (c *Config) RetryVerification(actor *Actor, domain string) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.authorize(domainConfig.Owner); err != nil {
+			return err
+		}
+		if domainConfig.Active() {
+			return fmt.Errorf("domain is already verified")
+		}
+
+		v := *domainConfig.Verification
+		v.Status = VerificationPending
+		v.Attempts = 0
+		v.NextCheckAt = nil
+		domainConfig.Verification = &v
+		return nil
+	})
+}
+
+// ApproveDomain 管理员跳过检查，直接将域名标记为已验证
+func (c *Config) ApproveDomain(domain string) error {
+	return c.Update(func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if domainConfig.Active() {
+			return nil
+		}
+
+		now := time.Now()
+		v := *domainConfig.Verification
+		v.Status = VerificationVerified
+		v.Method = VerificationMethodManual
+		v.LastError = ""
+		v.NextCheckAt = nil
+		v.VerifiedAt = &now
+		domainConfig.Verification = &v
+		return nil
+	})
+}
+
+// newVerification 启用验证时为新域名生成 challenge，未启用时返回 nil，调用方需持有锁
+func (c *Config) newVerification() (*DomainVerification, error) {
+	if c.Server == nil || c.Server.DomainVerification == nil || !c.Server.DomainVerification.Enabled {
+		return nil, nil
+	}
+	challenge, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate verification challenge: %v", err)
+	}
+	return &DomainVerification{Challenge: challenge, Status: VerificationPending}, nil
+}
+
+// verificationBackoff 第 attempts 次失败后的等待时间，调用方需持有锁
+func (c *Config) verificationBackoff(attempts int) time.Duration {
+	interval := defaultVerificationInterval
+	if v := c.Server.DomainVerification; v != nil && v.RetryInterval > 0 {
+		interval = time.Duration(v.RetryInterval) * time.Second
+	}
+	for i := 1; i < attempts && interval < maxVerificationInterval; i++ {
+		interval *= 2
+	}
+	return min(interval, maxVerificationInterval)
+}
+
+// verificationMaxAttempts 调用方需持有锁
+func (c *Config) verificationMaxAttempts() int {
+	if v := c.Server.DomainVerification; v != nil && v.MaxAttempts > 0 {
+		return v.MaxAttempts
+	}
+	return defaultVerificationMaxAttempts
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AnyVersion 作为期望版本时只要求条目存在（对应 If-Match: *）
+const AnyVersion int64 = -1
+
+// ErrVersionConflict 条目的当前版本与期望版本不一致
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError 携带当前版本的冲突错误，可用 errors.Is(err, ErrVersionConflict) 判断
+type VersionConflictError struct {
+	Expected int64
+	Current  int64 // 0 表示条目不存在
+}
+
+func (e *VersionConflictError) Error() string {
+	if e.Current == 0 {
+		return "version conflict: entry does not exist"
+	}
+	if e.Expected == AnyVersion {
+		return fmt.Sprintf("version conflict: current version is %d", e.Current)
+	}
+	return fmt.Sprintf("version conflict: expected version %d, current version is %d", e.Expected, e.Current)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// checkVersion 比较期望版本，expected 为 0 时不检查
+func checkVersion(expected, current int64, exists bool) error {
+	if expected == 0 {
+		return nil
+	}
+	if !exists {
+		return &VersionConflictError{Expected: expected}
+	}
+	if expected != AnyVersion && expected != current {
+		return &VersionConflictError{Expected: expected, Current: current}
+	}
+	return nil
+}
+
+// touch 修改条目后递增版本并更新时间
+func (f *ForwardingConfig) touch() {
+	f.Version++
+	f.UpdatedAt = time.Now()
+}
+
+// touch 修改条目后递增版本并更新时间
+func (d *DomainConfig) touch() {
+	d.Version++
+	d.UpdatedAt = time.Now()
+}
+
+// CheckForwardingVersion 检查路径跳转条目的版本
+func (tx *Tx) CheckForwardingVersion(name string, expected int64) error {
+	forwarding, exists := tx.forwardings[name]
+	if !exists {
+		return checkVersion(expected, 0, false)
+	}
+	return checkVersion(expected, forwarding.Version, true)
+}
+
+// CheckDomainVersion 检查域名映射的版本
+func (tx *Tx) CheckDomainVersion(domain string, expected int64) error {
+	domainConfig, exists := tx.domains[domainKey(domain)]
+	if !exists {
+		return checkVersion(expected, 0, false)
+	}
+	return checkVersion(expected, domainConfig.Version, true)
+}
+
+// SetTargetWithVersion 设置目标并返回新版本；expectedVersion 非 0 时必须与当前版本一致
+// token 可以是全局 redirect token、用户的 API key（只能修改该用户自己的条目）或条目自己的更新 token
+func (c *Config) SetTargetWithVersion(name, token, target string, expectedVersion int64, extraSelfHosts ...string) (int64, error) {
+	var version int64
+	err := c.Update(func(tx *Tx) error {
+		// 先校验 token，避免向未认证的调用方暴露版本信息
+		actor, ok := c.tokenActor(token, c.validateRedirectToken)
+		if !ok && !tx.validForwardingUpdateToken(name, token) {
+			return fmt.Errorf("invalid redirect token")
+		}
+		tx.SetActor(actor)
+		if err := tx.authorizeForwarding(name); err != nil {
+			return err
+		}
+		if err := tx.CheckForwardingVersion(name, expectedVersion); err != nil {
+			return err
+		}
+		if err := tx.setTarget(name, target, extraSelfHosts...); err != nil {
+			return err
+		}
+		version = tx.forwardings[name].Version
+		return nil
+	})
+	return version, err
+}
+
+// SetDomainTargetWithVersion 设置域名目标并返回新版本；expectedVersion 非 0 时必须与当前版本一致
+// token 可以是全局 domain token、用户的 API key 或域名映射自己的更新 token
+func (c *Config) SetDomainTargetWithVersion(domain, token, target string, expectedVersion int64, extraSelfHosts ...string) (int64, error) {
+	var version int64
+	err := c.Update(func(tx *Tx) error {
+		actor, ok := c.tokenActor(token, c.validateDomainToken)
+		if !ok && !tx.validDomainUpdateToken(domain, token) {
+			return fmt.Errorf("invalid domain token")
+		}
+		tx.SetActor(actor)
+		if err := tx.authorizeDomain(domain); err != nil {
+			return err
+		}
+		if err := tx.CheckDomainVersion(domain, expectedVersion); err != nil {
+			return err
+		}
+		if err := tx.setDomainTarget(domain, target, extraSelfHosts...); err != nil {
+			return err
+		}
+		version = tx.domains[domainKey(domain)].Version
+		return nil
+	})
+	return version, err
+}
+
+// RemoveForwardingWithVersion 以 actor 的身份删除条目；expectedVersion 非 0 时必须与当前版本一致
+func (c *Config) RemoveForwardingWithVersion(actor *Actor, name string, expectedVersion int64) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		if err := tx.authorizeForwarding(name); err != nil {
+			return err
+		}
+		if err := tx.CheckForwardingVersion(name, expectedVersion); err != nil {
+			return err
+		}
+		return tx.RemoveForwarding(name)
+	})
+}
+
+// RemoveDomainWithVersion 以 actor 的身份删除域名映射；expectedVersion 非 0 时必须与当前版本一致
+func (c *Config) RemoveDomainWithVersion(actor *Actor, domain string, expectedVersion int64) error {
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		if err := tx.authorizeDomain(domain); err != nil {
+			return err
+		}
+		if err := tx.CheckDomainVersion(domain, expectedVersion); err != nil {
+			return err
+		}
+		return tx.RemoveDomain(domain)
+	})
+}
+
+// normalizeVersions 为旧配置中没有版本号的条目设置初始版本
+func (c *Config) normalizeVersions() {
+	for _, forwarding := range c.Forwardings {
+		if forwarding.Version == 0 {
+			forwarding.Version = 1
+		}
+	}
+	for _, domainConfig := range c.Domains {
+		if domainConfig.Version == 0 {
+			domainConfig.Version = 1
+		}
+	}
+}
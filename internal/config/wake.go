@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+
+	"redirect_helper/internal/wol"
+)
+
+// 网络唤醒：条目设置 MAC 地址后，访问时如果目标端口无法连接，先发送魔术包并显示等待页面，
+// 目标端口可以连接后再跳转，适合会休眠的 NAS 等家庭服务器
+
+// DefaultWakeTimeout 未配置时等待目标端口可连接的最长时间（秒）
+const DefaultWakeTimeout = 120
+
+// maxWakeTimeout 等待时间上限（秒）
+const maxWakeTimeout = 1800
+
+// WakeConfig 条目的网络唤醒设置
+type WakeConfig struct {
+	MAC       string `json:"mac"`
+	Broadcast string `json:"broadcast,omitempty"` // 魔术包的广播地址，例如 "192.168.1.255:9"，默认 255.255.255.255:9
+	Timeout   int    `json:"timeout,omitempty"`   // 等待目标端口可连接的最长时间（秒），默认 120
+}
+
+// SetForwardingWake 设置路径跳转的网络唤醒，wake 为 nil 时取消
+func (c *Config) SetForwardingWake(actor *Actor, name string, wake *WakeConfig) error {
+	normalized, err := normalizeWake(wake)
+	if err != nil {
+		return err
+	}
+
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		forwarding, exists := tx.forwardings[name]
+		if !exists {
+			return fmt.Errorf("forwarding name not found")
+		}
+		if err := tx.authorize(forwarding.Owner); err != nil {
+			return err
+		}
+		forwarding.Wake = normalized
+		forwarding.touch()
+		return nil
+	})
+}
+
+// SetDomainWake 设置域名映射的网络唤醒，wake 为 nil 时取消
+func (c *Config) SetDomainWake(actor *Actor, domain string, wake *WakeConfig) error {
+	normalized, err := normalizeWake(wake)
+	if err != nil {
+		return err
+	}
+
+	return c.UpdateAs(actor, func(tx *Tx) error {
+		domainConfig, exists := tx.domains[domainKey(domain)]
+		if !exists {
+			return fmt.Errorf("domain not found")
+		}
+		if err := tx.authorize(domainConfig.Owner); err != nil {
+			return err
+		}
+		domainConfig.Wake = normalized
+		domainConfig.touch()
+		return nil
+	})
+}
+
+// normalizeWake 校验 MAC 和广播地址，统一格式后返回副本
+func normalizeWake(wake *WakeConfig) (*WakeConfig, error) {
+	if wake == nil {
+		return nil, nil
+	}
+
+	mac, err := wol.ParseMAC(wake.MAC)
+	if err != nil {
+		return nil, err
+	}
+	broadcast := ""
+	if wake.Broadcast != "" {
+		if broadcast, err = wol.NormalizeBroadcast(wake.Broadcast); err != nil {
+			return nil, err
+		}
+	}
+	if wake.Timeout < 0 || wake.Timeout > maxWakeTimeout {
+		return nil, fmt.Errorf("wake timeout must be between 0 and %d seconds", maxWakeTimeout)
+	}
+
+	return &WakeConfig{MAC: mac.String(), Broadcast: broadcast, Timeout: wake.Timeout}, nil
+}
@@ -0,0 +1,440 @@
+// Package dnsserver 为域名映射提供一个最小的权威 DNS 服务（UDP 和 TCP）
+// 只回答 Lookup 声明负责的名称，不做递归查询
+package dnsserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// DefaultTTL 记录默认 TTL（秒）
+	DefaultTTL = 60
+
+	// maxUDPSize 不带 EDNS 的 UDP 应答上限，超过时设置 TC 让客户端改用 TCP
+	maxUDPSize = 512
+	// maxEDNSSize 接受的 EDNS 缓冲区上限
+	maxEDNSSize = 4096
+	// tcpIdleTimeout TCP 连接的读写超时
+	tcpIdleTimeout = 10 * time.Second
+)
+
+// Result 一个名称的查询结果
+// Zone 为负责该名称的区域（用于 SOA），为空表示不负责，返回 REFUSED；
+// Exists 为 false 表示名称属于该区域但不存在，返回 NXDOMAIN
+type Result struct {
+	Zone   string
+	Exists bool
+
+	A     []netip.Addr
+	AAAA  []netip.Addr
+	TXT   []string
+	CNAME string
+}
+
+// LookupFunc 按小写、不带末尾点的名称查询记录，每次查询都会调用，因此配置修改立即生效
+type LookupFunc func(name string) Result
+
+// Server 权威 DNS 服务
+type Server struct {
+	Addr       string     // 监听地址，UDP 和 TCP 共用，例如 ":53"
+	TTL        uint32     // 记录 TTL，0 时使用 DefaultTTL
+	Nameserver string     // SOA 和 NS 记录中的主名称服务器，为空时使用 "ns.<zone>"
+	Lookup     LookupFunc // 记录来源
+
+	mu       sync.Mutex
+	udpConn  net.PacketConn
+	listener net.Listener
+	closed   bool
+}
+
+// ListenAndServe 同时在 UDP 和 TCP 上提供服务，直到 Close 被调用
+func (s *Server) ListenAndServe() error {
+	udpConn, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		udpConn.Close()
+		return err
+	}
+	return s.Serve(udpConn, listener)
+}
+
+// Serve 在已经打开的 UDP 连接和 TCP 监听器上提供服务，直到 Close 被调用；返回时两者都已关闭
+func (s *Server) Serve(udpConn net.PacketConn, listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		udpConn.Close()
+		listener.Close()
+		return nil
+	}
+	s.udpConn, s.listener = udpConn, listener
+	s.mu.Unlock()
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- s.serveUDP(udpConn) }()
+	go func() { errCh <- s.serveTCP(listener) }()
+
+	err := <-errCh
+	s.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close 停止服务
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var errs []error
+	if s.udpConn != nil {
+		errs = append(errs, s.udpConn.Close())
+		s.udpConn = nil
+	}
+	if s.listener != nil {
+		errs = append(errs, s.listener.Close())
+		s.listener = nil
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxEDNSSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if resp := s.handle(buf[:n], false); resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *Server) serveTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.serveTCPConn(conn)
+	}
+}
+
+// serveTCPConn 处理一个 TCP 连接上的多个查询，每条消息前有 2 字节长度
+func (s *Server) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	var length [2]byte
+	for {
+		conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		resp := s.handle(req, true)
+		if resp == nil {
+			return
+		}
+		out := make([]byte, 2, 2+len(resp))
+		binary.BigEndian.PutUint16(out, uint16(len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle 解析查询并生成应答；无法解析的报文返回 nil（丢弃）
+func (s *Server) handle(req []byte, tcp bool) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(req)
+	if err != nil || h.Response {
+		return nil
+	}
+
+	header := dnsmessage.Header{
+		ID:               h.ID,
+		Response:         true,
+		OpCode:           h.OpCode,
+		RecursionDesired: h.RecursionDesired,
+	}
+
+	questions, err := p.AllQuestions()
+	if err != nil || len(questions) != 1 {
+		header.RCode = dnsmessage.RCodeFormatError
+		return s.build(header, nil, nil, false, maxUDPSize)
+	}
+	q := questions[0]
+
+	// 读取 EDNS 声明的 UDP 缓冲区大小
+	edns := false
+	maxSize := maxUDPSize
+	if tcp {
+		maxSize = 65535
+	}
+	if err := p.SkipAllAnswers(); err == nil {
+		if err := p.SkipAllAuthorities(); err == nil {
+			for {
+				rh, err := p.AdditionalHeader()
+				if err != nil {
+					break
+				}
+				if rh.Type == dnsmessage.TypeOPT {
+					edns = true
+					if size := int(rh.Class); !tcp && size > maxSize {
+						maxSize = min(size, maxEDNSSize)
+					}
+				}
+				if err := p.SkipAdditional(); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if h.OpCode != 0 {
+		header.RCode = dnsmessage.RCodeNotImplemented
+		return s.build(header, &q, nil, edns, maxSize)
+	}
+	if q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY {
+		header.RCode = dnsmessage.RCodeRefused
+		return s.build(header, &q, nil, edns, maxSize)
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+	result := s.Lookup(name)
+	if result.Zone == "" {
+		header.RCode = dnsmessage.RCodeRefused
+		return s.build(header, &q, nil, edns, maxSize)
+	}
+
+	header.Authoritative = true
+	answers := s.answers(q, name, result)
+	if !result.Exists {
+		header.RCode = dnsmessage.RCodeNameError
+	}
+	return s.build(header, &q, &response{answers: answers, zone: result.Zone}, edns, maxSize)
+}
+
+// response 应答内容：answers 为空时在 authority 中附带 SOA（NODATA/NXDOMAIN）
+type response struct {
+	answers []dnsmessage.Resource
+	zone    string
+}
+
+// answers 按查询类型生成应答记录；存在 CNAME 时只返回 CNAME，由客户端继续解析
+func (s *Server) answers(q dnsmessage.Question, name string, result Result) []dnsmessage.Resource {
+	if !result.Exists {
+		return nil
+	}
+
+	ttl := s.ttl()
+	header := func(t dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: q.Name, Type: t, Class: dnsmessage.ClassINET, TTL: ttl}
+	}
+
+	var answers []dnsmessage.Resource
+	if result.CNAME != "" {
+		target, err := dnsmessage.NewName(fqdn(result.CNAME))
+		if err != nil {
+			return nil
+		}
+		return append(answers, dnsmessage.Resource{Header: header(dnsmessage.TypeCNAME), Body: &dnsmessage.CNAMEResource{CNAME: target}})
+	}
+
+	all := q.Type == dnsmessage.TypeALL
+	if q.Type == dnsmessage.TypeA || all {
+		for _, addr := range result.A {
+			answers = append(answers, dnsmessage.Resource{Header: header(dnsmessage.TypeA), Body: &dnsmessage.AResource{A: addr.As4()}})
+		}
+	}
+	if q.Type == dnsmessage.TypeAAAA || all {
+		for _, addr := range result.AAAA {
+			answers = append(answers, dnsmessage.Resource{Header: header(dnsmessage.TypeAAAA), Body: &dnsmessage.AAAAResource{AAAA: addr.As16()}})
+		}
+	}
+	if q.Type == dnsmessage.TypeTXT || all {
+		for _, txt := range result.TXT {
+			answers = append(answers, dnsmessage.Resource{Header: header(dnsmessage.TypeTXT), Body: &dnsmessage.TXTResource{TXT: splitTXT(txt)}})
+		}
+	}
+	if name == result.Zone {
+		if q.Type == dnsmessage.TypeSOA || all {
+			if soa, ok := s.soa(result.Zone); ok {
+				answers = append(answers, dnsmessage.Resource{Header: header(dnsmessage.TypeSOA), Body: soa})
+			}
+		}
+		if q.Type == dnsmessage.TypeNS || all {
+			if ns, err := dnsmessage.NewName(s.nameserver(result.Zone)); err == nil {
+				answers = append(answers, dnsmessage.Resource{Header: header(dnsmessage.TypeNS), Body: &dnsmessage.NSResource{NS: ns}})
+			}
+		}
+	}
+	return answers
+}
+
+// build 编码应答；UDP 应答超过客户端缓冲区时去掉记录并设置 TC
+func (s *Server) build(header dnsmessage.Header, q *dnsmessage.Question, resp *response, edns bool, maxSize int) []byte {
+	msg, err := s.encode(header, q, resp, edns)
+	if err == nil && len(msg) <= maxSize {
+		return msg
+	}
+	if err != nil {
+		log.Printf("[DNS] failed to encode response: %v", err)
+		header.RCode = dnsmessage.RCodeServerFailure
+	} else {
+		header.Truncated = true
+	}
+	msg, err = s.encode(header, q, nil, edns)
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+func (s *Server) encode(header dnsmessage.Header, q *dnsmessage.Question, resp *response, edns bool) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 0, maxUDPSize), header)
+	b.EnableCompression()
+
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if q != nil {
+		if err := b.Question(*q); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp != nil {
+		if err := b.StartAnswers(); err != nil {
+			return nil, err
+		}
+		for _, answer := range resp.answers {
+			if err := addResource(&b, answer); err != nil {
+				return nil, err
+			}
+		}
+
+		// NODATA 和 NXDOMAIN 在 authority 中附带 SOA，供解析器做否定缓存
+		if len(resp.answers) == 0 {
+			if err := b.StartAuthorities(); err != nil {
+				return nil, err
+			}
+			if soa, ok := s.soa(resp.zone); ok {
+				name, _ := dnsmessage.NewName(fqdn(resp.zone))
+				rh := dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: s.ttl()}
+				if err := b.SOAResource(rh, *soa); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if edns {
+		if err := b.StartAdditionals(); err != nil {
+			return nil, err
+		}
+		var rh dnsmessage.ResourceHeader
+		if err := rh.SetEDNS0(maxEDNSSize, dnsmessage.RCodeSuccess, false); err != nil {
+			return nil, err
+		}
+		if err := b.OPTResource(rh, dnsmessage.OPTResource{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Finish()
+}
+
+func addResource(b *dnsmessage.Builder, r dnsmessage.Resource) error {
+	switch body := r.Body.(type) {
+	case *dnsmessage.AResource:
+		return b.AResource(r.Header, *body)
+	case *dnsmessage.AAAAResource:
+		return b.AAAAResource(r.Header, *body)
+	case *dnsmessage.TXTResource:
+		return b.TXTResource(r.Header, *body)
+	case *dnsmessage.CNAMEResource:
+		return b.CNAMEResource(r.Header, *body)
+	case *dnsmessage.SOAResource:
+		return b.SOAResource(r.Header, *body)
+	case *dnsmessage.NSResource:
+		return b.NSResource(r.Header, *body)
+	}
+	return errors.New("unsupported resource type")
+}
+
+// soa 区域的 SOA 记录；序列号取当前时间，记录随配置实时变化
+func (s *Server) soa(zone string) (*dnsmessage.SOAResource, bool) {
+	ns, err := dnsmessage.NewName(s.nameserver(zone))
+	if err != nil {
+		return nil, false
+	}
+	mbox, err := dnsmessage.NewName(fqdn("hostmaster." + zone))
+	if err != nil {
+		return nil, false
+	}
+	return &dnsmessage.SOAResource{
+		NS:      ns,
+		MBox:    mbox,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		MinTTL:  s.ttl(),
+	}, true
+}
+
+func (s *Server) nameserver(zone string) string {
+	if s.Nameserver != "" {
+		return fqdn(s.Nameserver)
+	}
+	return fqdn("ns." + zone)
+}
+
+func (s *Server) ttl() uint32 {
+	if s.TTL == 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+// splitTXT 按 DNS 字符串 255 字节的上限拆分 TXT 值
+func splitTXT(value string) []string {
+	parts := make([]string, 0, len(value)/255+1)
+	for len(value) > 255 {
+		parts = append(parts, value[:255])
+		value = value[255:]
+	}
+	return append(parts, value)
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,113 @@
+package dnsserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+// startTestServer 在 127.0.0.1 的随机端口上启动服务，返回地址
+func startTestServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpConn, err := net.ListenPacket("udp", listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		t.Skipf("UDP port %s not available: %v", listener.Addr(), err)
+	}
+
+	s := &Server{
+		Lookup: func(name string) Result {
+			switch name {
+			case "example.test":
+				return Result{Zone: "example.test", Exists: true}
+			case "www.example.test":
+				return Result{
+					Zone:   "example.test",
+					Exists: true,
+					A:      []netip.Addr{netip.MustParseAddr("192.0.2.10")},
+					AAAA:   []netip.Addr{netip.MustParseAddr("2001:db8::10")},
+					TXT:    []string{"hello world"},
+				}
+			case "alias.example.test":
+				return Result{Zone: "example.test", Exists: true, CNAME: "www.example.test"}
+			case "missing.example.test":
+				return Result{Zone: "example.test"}
+			}
+			return Result{}
+		},
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(udpConn, listener) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return listener.Addr().String()
+}
+
+// testResolver 只向 addr 查询的解析器，network 为空时使用解析器自己选择的协议
+func testResolver(addr, network string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, defaultNetwork, _ string) (net.Conn, error) {
+			if network == "" {
+				network = defaultNetwork
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func TestServerResolve(t *testing.T) {
+	addr := startTestServer(t)
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			resolver := testResolver(addr, network)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			addrs, err := resolver.LookupHost(ctx, "www.example.test")
+			if err != nil {
+				t.Fatalf("LookupHost: %v", err)
+			}
+			slices.Sort(addrs)
+			if want := []string{"192.0.2.10", "2001:db8::10"}; !slices.Equal(addrs, want) {
+				t.Errorf("LookupHost = %v, want %v", addrs, want)
+			}
+
+			txt, err := resolver.LookupTXT(ctx, "www.example.test")
+			if err != nil || !slices.Equal(txt, []string{"hello world"}) {
+				t.Errorf("LookupTXT = %v, %v", txt, err)
+			}
+
+			cname, err := resolver.LookupCNAME(ctx, "alias.example.test")
+			if err != nil || cname != "www.example.test." {
+				t.Errorf("LookupCNAME = %q, %v", cname, err)
+			}
+
+			_, err = resolver.LookupHost(ctx, "missing.example.test")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				t.Errorf("LookupHost(missing) error = %v, want not found", err)
+			}
+
+			// 不负责的区域返回 REFUSED
+			if _, err := resolver.LookupHost(ctx, "www.other.test"); err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+				t.Errorf("LookupHost(other zone) error = %v, want refused", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBufferSize 环形缓冲区默认保留的事件数量
+	DefaultBufferSize = 1024
+	// subscriberBuffer 每个订阅者的待发送事件上限，写满说明客户端太慢，断开后由其用 Last-Event-ID 续传
+	subscriberBuffer = 64
+)
+
+// Event 推送给实时客户端的一条事件
+type Event struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+	Time time.Time       `json:"time"`
+}
+
+// Broker 将事件广播给所有订阅者，并在环形缓冲区中保留最近的事件用于断线续传
+type Broker struct {
+	mu          sync.Mutex
+	ring        []Event
+	start       int // ring 中最旧事件的位置
+	count       int
+	nextID      uint64
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription 一个订阅者，事件从 C 读取；C 被关闭表示订阅已结束（客户端过慢或服务关闭）
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	broker *Broker
+}
+
+// NewBroker 创建事件广播器，size <= 0 时使用默认缓冲区大小
+func NewBroker(size int) *Broker {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Broker{
+		ring: make([]Event, size),
+		// 事件 ID 从启动时的毫秒时间戳开始，重启后的 ID 总是大于重启前的 ID
+		nextID:      uint64(time.Now().UnixMilli()),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish 分配 ID、写入缓冲区并广播给所有订阅者，不会阻塞
+func (b *Broker) Publish(eventType string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	event := Event{ID: b.nextID, Type: eventType, Data: raw, Time: time.Now()}
+
+	if b.count < len(b.ring) {
+		b.ring[(b.start+b.count)%len(b.ring)] = event
+		b.count++
+	} else {
+		b.ring[b.start] = event
+		b.start = (b.start + 1) % len(b.ring)
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			// 客户端跟不上，断开让其重连续传
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe 订阅之后的事件；lastID 非 0 时同时返回缓冲区中 lastID 之后的事件
+// complete 为 false 表示 lastID 已不在缓冲区中（过旧或来自其它进程），客户端需要重新拉取全量数据
+func (b *Broker) Subscribe(lastID uint64) (sub *Subscription, backlog []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: ch, ch: ch, broker: b}
+	if b.closed {
+		close(ch)
+		return sub, nil, true
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+
+	complete = lastID == b.nextID
+	for i := 0; i < b.count; i++ {
+		event := b.ring[(b.start+i)%len(b.ring)]
+		if event.ID == lastID {
+			complete = true
+		}
+		if event.ID > lastID {
+			backlog = append(backlog, event)
+		}
+	}
+	// 缓冲区中最旧的事件紧接在 lastID 之后，说明没有遗漏
+	if len(backlog) > 0 && backlog[0].ID == lastID+1 {
+		complete = true
+	}
+	return sub, backlog, complete
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.remove(s)
+}
+
+// remove 移除订阅者并关闭其通道，调用方需持有锁
+func (b *Broker) remove(sub *Subscription) {
+	if _, exists := b.subscribers[sub]; exists {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Close 关闭所有订阅，之后的 Publish 和 Subscribe 不再有效
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
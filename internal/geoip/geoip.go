@@ -0,0 +1,169 @@
+// Package geoip 从本地 MaxMind 格式（.mmdb）数据库查询 IP 的国家和 ASN
+// 数据库整个读入内存，文件被替换后调用 Reload 即可切换，不需要重启
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Info 一个 IP 的查询结果，未知的字段为零值
+type Info struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 两位代码，例如 "CN"
+	ASN     uint32 `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"` // ASN 所属组织
+}
+
+// record 同时包含 Country/City 和 ASN 数据库的字段，任何一种数据库都可以解码到这里
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	ASN uint32 `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// DatabaseInfo 已加载的数据库文件
+type DatabaseInfo struct {
+	Path     string    `json:"path"`
+	Type     string    `json:"type"`      // 例如 "GeoLite2-Country"
+	Built    time.Time `json:"built"`     // 数据库的生成时间
+	LoadedAt time.Time `json:"loaded_at"` // 最近一次加载的时间
+}
+
+type database struct {
+	path     string
+	modTime  time.Time
+	size     int64
+	loadedAt time.Time
+	reader   *maxminddb.Reader
+}
+
+// DB 按顺序查询一组数据库，前面的数据库已经给出的字段不会被后面的覆盖
+type DB struct {
+	mu        sync.RWMutex
+	databases []*database
+}
+
+// Open 加载全部数据库文件，任何一个无法加载时返回错误
+func Open(paths ...string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		database, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		db.databases = append(db.databases, database)
+	}
+	return db, nil
+}
+
+func load(path string) (*database, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %v", err)
+	}
+	reader, err := maxminddb.FromBytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GeoIP database %s: %v", path, err)
+	}
+	return &database{
+		path:     path,
+		modTime:  stat.ModTime(),
+		size:     stat.Size(),
+		loadedAt: time.Now(),
+		reader:   reader,
+	}, nil
+}
+
+// Reload 重新加载修改时间或大小发生变化的文件，force 为 true 时全部重新加载
+// 加载失败的文件继续使用旧数据，返回重新加载的文件数和遇到的错误
+func (db *DB) Reload(force bool) (int, error) {
+	db.mu.RLock()
+	current := append([]*database(nil), db.databases...)
+	db.mu.RUnlock()
+
+	var errs []error
+	reloaded := 0
+	for i, old := range current {
+		stat, err := os.Stat(old.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to check GeoIP database: %v", err))
+			continue
+		}
+		if !force && stat.ModTime().Equal(old.modTime) && stat.Size() == old.size {
+			continue
+		}
+
+		database, err := load(old.path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		db.mu.Lock()
+		db.databases[i] = database
+		db.mu.Unlock()
+		reloaded++
+	}
+	return reloaded, errors.Join(errs...)
+}
+
+// Lookup 查询 IP，数据库中没有记录时返回零值
+func (db *DB) Lookup(addr netip.Addr) Info {
+	var info Info
+	if db == nil || !addr.IsValid() {
+		return info
+	}
+	ip := net.IP(addr.Unmap().AsSlice())
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, database := range db.databases {
+		var rec record
+		if err := database.reader.Lookup(ip, &rec); err != nil {
+			continue
+		}
+		if info.Country == "" {
+			info.Country = rec.Country.ISOCode
+			if info.Country == "" {
+				info.Country = rec.RegisteredCountry.ISOCode
+			}
+		}
+		if info.ASN == 0 {
+			info.ASN, info.Org = rec.ASN, rec.Org
+		}
+	}
+	return info
+}
+
+// Databases 返回已加载的数据库文件
+func (db *DB) Databases() []DatabaseInfo {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	result := make([]DatabaseInfo, 0, len(db.databases))
+	for _, database := range db.databases {
+		result = append(result, DatabaseInfo{
+			Path:     database.path,
+			Type:     database.reader.Metadata.DatabaseType,
+			Built:    time.Unix(int64(database.reader.Metadata.BuildEpoch), 0).UTC(),
+			LoadedAt: database.loadedAt,
+		})
+	}
+	return result
+}
@@ -7,41 +7,178 @@ import (
 type ForwardingEntry struct {
 	Name      string    `json:"name"`
 	Target    string    `json:"target"`
+	Version   int64     `json:"version"`
+	Owner     string    `json:"owner,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// HasUpdateToken 条目是否设置了自己的更新 token（token 本身不会返回）
+	HasUpdateToken bool `json:"has_update_token,omitempty"`
+
+	// Relay 四层转发设置和运行状态，为空表示只提供 HTTP 跳转
+	Relay *ForwardingRelay `json:"relay,omitempty"`
+
+	// Wake 网络唤醒设置，为空表示直接跳转
+	Wake *Wake `json:"wake,omitempty"`
+
+	// Preview 预览页面设置，为空表示直接跳转
+	Preview *Preview `json:"preview,omitempty"`
+
+	// HasPassword 是否需要访问密码（密码本身不会返回）
+	HasPassword bool `json:"has_password,omitempty"`
+	// MaxUses 成功跳转次数上限，0 表示不限制；Uses 为已使用的次数
+	MaxUses int `json:"max_uses,omitempty"`
+	Uses    int `json:"uses,omitempty"`
+
+	// Routes 路由规则，按顺序匹配，都不匹配时使用 Target
+	Routes []RouteRule `json:"routes,omitempty"`
+}
+
+// RouteRule 按访问者 IP 段、国家、ASN、设备、语言、请求头或查询参数选择目标的规则
+type RouteRule struct {
+	CIDRs     []string     `json:"cidrs,omitempty"`
+	Countries []string     `json:"countries,omitempty"`
+	ASNs      []uint32     `json:"asns,omitempty"`
+	Devices   []string     `json:"devices,omitempty"`
+	Languages []string     `json:"languages,omitempty"`
+	Headers   []FieldMatch `json:"headers,omitempty"`
+	Query     []FieldMatch `json:"query,omitempty"`
+	Target    string       `json:"target"`
+}
+
+// FieldMatch 请求头或查询参数条件，Value 为空时只要求存在
+type FieldMatch struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// PathRule 域名映射按访问路径跳转的规则，Type 为 prefix 或 regex
+type PathRule struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Target  string `json:"target"`
+	Status  int    `json:"status,omitempty"`
+}
+
+// Preview 条目的预览页面设置
+type Preview struct {
+	Message   string `json:"message,omitempty"`
+	Countdown int    `json:"countdown,omitempty"` // 秒，0 表示需要点击继续
+}
+
+// ForwardingRelay 路径条目的四层转发；运行状态只在服务端 API 中返回
+type ForwardingRelay struct {
+	Listen         string `json:"listen"`
+	UDP            bool   `json:"udp,omitempty"`
+	MaxConnections int    `json:"max_connections,omitempty"` // 0 表示使用全局设置
+	IdleTimeout    int    `json:"idle_timeout,omitempty"`    // 秒，0 表示使用全局设置
+
+	Listening         bool   `json:"listening"`
+	Error             string `json:"error,omitempty"` // 监听失败的原因
+	ActiveConnections int64  `json:"active_connections"`
+	ActiveSessions    int64  `json:"active_udp_sessions,omitempty"`
+	TotalConnections  int64  `json:"total_connections"`
+	Rejected          int64  `json:"rejected_connections"`
 }
 
 type DomainEntry struct {
 	Domain    string    `json:"domain"`
 	Target    string    `json:"target"`
+	Version   int64     `json:"version"`
+	Owner     string    `json:"owner,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// HasUpdateToken 条目是否设置了自己的更新 token（token 本身不会返回）
+	HasUpdateToken bool `json:"has_update_token,omitempty"`
+
+	// Verification 所有权验证状态，为空表示映射不需要验证
+	Verification *DomainVerification `json:"verification,omitempty"`
+
+	// Wake 网络唤醒设置，为空表示直接跳转
+	Wake *Wake `json:"wake,omitempty"`
+
+	// Routes 路由规则，按顺序匹配，都不匹配时使用 Target
+	Routes []RouteRule `json:"routes,omitempty"`
+
+	// PathRules 路径规则，按顺序匹配，都不匹配时保持原路径跳转到 Target
+	PathRules []PathRule `json:"path_rules,omitempty"`
 }
 
 // DomainEntryPublic 公开的域名信息，不包含敏感token
 type DomainEntryPublic struct {
 	Domain    string    `json:"domain"`
 	Target    string    `json:"target"`
+	Version   int64     `json:"version"`
+	Owner     string    `json:"owner,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// HasUpdateToken 条目是否设置了自己的更新 token（token 本身不会返回）
+	HasUpdateToken bool `json:"has_update_token,omitempty"`
+
+	// Verification 所有权验证状态，为空表示映射不需要验证
+	Verification *DomainVerification `json:"verification,omitempty"`
+
+	// Wake 网络唤醒设置，为空表示直接跳转
+	Wake *Wake `json:"wake,omitempty"`
+
+	// Routes 路由规则，按顺序匹配，都不匹配时使用 Target
+	Routes []RouteRule `json:"routes,omitempty"`
+
+	// PathRules 路径规则，按顺序匹配，都不匹配时保持原路径跳转到 Target
+	PathRules []PathRule `json:"path_rules,omitempty"`
+}
+
+// Wake 条目的网络唤醒设置
+type Wake struct {
+	MAC       string `json:"mac"`
+	Broadcast string `json:"broadcast,omitempty"`
+	Timeout   int    `json:"timeout,omitempty"` // 秒，0 表示默认值
+}
+
+// DomainVerification 域名所有权验证状态；未通过验证前包含需要发布的 challenge
+type DomainVerification struct {
+	Status        string     `json:"status"` // pending、verified 或 failed
+	Method        string     `json:"method,omitempty"`
+	Challenge     string     `json:"challenge,omitempty"`
+	DNSRecord     string     `json:"dns_record,omitempty"` // 值为 challenge 的 TXT 记录名
+	HTTPURL       string     `json:"http_url,omitempty"`   // 内容为 challenge 的文件地址
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	NextCheckAt   *time.Time `json:"next_check_at,omitempty"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
 }
 
 type Response struct {
 	State   string `json:"state"`
 	Message string `json:"message,omitempty"`
+	Version int64  `json:"version,omitempty"` // 写操作成功后条目的版本
+
+	// Verification 域名映射需要验证所有权时返回验证状态和 challenge
+	Verification *DomainVerification `json:"verification,omitempty"`
 }
 
 // BatchUpdateEntry 批量更新的单个条目
 type BatchUpdateEntry struct {
-	Name   string `json:"name,omitempty"`   // 路径重定向的名称
-	Domain string `json:"domain,omitempty"` // 域名重定向的域名
-	Target string `json:"target"`           // 目标地址
+	Op        string `json:"op,omitempty"`         // 操作类型: upsert(默认)、delete、rename
+	Name      string `json:"name,omitempty"`       // 路径重定向的名称
+	Domain    string `json:"domain,omitempty"`     // 域名重定向的域名
+	Target    string `json:"target,omitempty"`     // 目标地址 (delete 不需要)
+	NewName   string `json:"new_name,omitempty"`   // rename 时的新名称
+	NewDomain string `json:"new_domain,omitempty"` // rename 时的新域名
+
+	ExpectedVersion int64 `json:"expected_version,omitempty"` // 非 0 时条目当前版本必须与之一致
 }
 
 // BatchUpdateRequest 批量更新请求 (POST JSON body)
 type BatchUpdateRequest struct {
+	AdminToken    string             `json:"admin_token,omitempty"`    // 删除操作需要的管理员token
 	RedirectToken string             `json:"redirect_token,omitempty"` // 路径重定向的token
 	DomainToken   string             `json:"domain_token,omitempty"`   // 域名重定向的token
+	Atomic        bool               `json:"atomic,omitempty"`         // 全部成功才提交，否则整批回滚
+	DryRun        bool               `json:"dry_run,omitempty"`        // 只返回结果，不保存任何修改
 	Entries       []BatchUpdateEntry `json:"entries"`
 }
 
@@ -49,17 +186,23 @@ type BatchUpdateRequest struct {
 type BatchUpdateResponse struct {
 	State    string                    `json:"state"`
 	Message  string                    `json:"message,omitempty"`
+	Atomic   bool                      `json:"atomic,omitempty"`
+	DryRun   bool                      `json:"dry_run,omitempty"`
 	Results  []BatchUpdateEntryResult  `json:"results,omitempty"`
 	Summary  BatchUpdateSummary        `json:"summary"`
 }
 
 // BatchUpdateEntryResult 单个条目的更新结果
 type BatchUpdateEntryResult struct {
-	Name    string `json:"name,omitempty"`
-	Domain  string `json:"domain,omitempty"`
-	Target  string `json:"target"`
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"`
+	Op        string `json:"op,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Domain    string `json:"domain,omitempty"`
+	Target    string `json:"target,omitempty"`
+	NewName   string `json:"new_name,omitempty"`
+	NewDomain string `json:"new_domain,omitempty"`
+	Version   int64  `json:"version,omitempty"` // 成功后条目的版本
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
 }
 
 // BatchUpdateSummary 批量更新汇总
@@ -0,0 +1,377 @@
+// Package relay 把本地端口上的 TCP 连接和 UDP 数据报转发到路径条目的 host:port 目标，
+// 以及按 TLS SNI 把连接透传到域名映射的后端（见 sni.go）
+// 每个新连接（UDP 为每个新会话）建立时读取一次当前目标，因此通过 API 修改目标后无需重启
+package relay
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxConnections 未配置时每个监听端口的最大并发连接数（UDP 为会话数）
+	DefaultMaxConnections = 256
+	// DefaultIdleTimeout 未配置时 TCP 连接两个方向都没有数据的最长时间
+	DefaultIdleTimeout = 5 * time.Minute
+	// DefaultUDPIdleTimeout 未配置时 UDP 会话的空闲超时
+	DefaultUDPIdleTimeout = time.Minute
+
+	// dialTimeout 连接目标的超时
+	dialTimeout = 10 * time.Second
+	// maxDatagramSize UDP 数据报上限
+	maxDatagramSize = 64 * 1024
+	copyBufferSize  = 32 * 1024
+)
+
+// TargetFunc 返回当前的 host:port 目标，返回错误时拒绝新连接
+type TargetFunc func() (string, error)
+
+// Options 一个转发端口的设置
+type Options struct {
+	Name           string        // 条目名称，只用于日志
+	Listen         string        // 监听地址，例如 ":2222"
+	UDP            bool          // 同时在相同地址上转发 UDP
+	MaxConnections int           // 最大并发 TCP 连接数和 UDP 会话数，0 使用 DefaultMaxConnections
+	IdleTimeout    time.Duration // 空闲超时，0 时 TCP 使用 DefaultIdleTimeout，UDP 使用 DefaultUDPIdleTimeout
+	Target         TargetFunc
+}
+
+// Stats 运行统计
+type Stats struct {
+	ActiveConnections int64 // 当前 TCP 连接数
+	ActiveSessions    int64 // 当前 UDP 会话数
+	TotalConnections  int64 // 累计接受的 TCP 连接和 UDP 会话
+	Rejected          int64 // 因达到上限或目标不可用而拒绝的连接和会话
+}
+
+// Relay 一个监听端口
+type Relay struct {
+	opts Options
+
+	listener   net.Listener
+	packetConn net.PacketConn
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	sessions map[string]*udpSession
+	closed   bool
+
+	activeConns atomic.Int64
+	total       atomic.Int64
+	rejected    atomic.Int64
+}
+
+// udpSession 一个 UDP 客户端地址对应一个连接到目标的 socket
+type udpSession struct {
+	client   net.Addr
+	target   string
+	upstream net.Conn
+	last     atomic.Int64 // 最近一次收发的时间（UnixNano）
+}
+
+// Listen 绑定端口并开始转发，直到 Close 被调用
+func Listen(opts Options) (*Relay, error) {
+	if opts.MaxConnections <= 0 {
+		opts.MaxConnections = DefaultMaxConnections
+	}
+
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return nil, err
+	}
+	r := &Relay{
+		opts:     opts,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+		sessions: make(map[string]*udpSession),
+	}
+
+	if opts.UDP {
+		packetConn, err := net.ListenPacket("udp", opts.Listen)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		r.packetConn = packetConn
+		go r.serveUDP()
+	}
+	go r.serveTCP()
+
+	return r, nil
+}
+
+// Close 停止监听并断开所有连接
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	errs := []error{r.listener.Close()}
+	if r.packetConn != nil {
+		errs = append(errs, r.packetConn.Close())
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	for _, session := range r.sessions {
+		session.upstream.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// Stats 返回当前统计
+func (r *Relay) Stats() Stats {
+	r.mu.Lock()
+	sessions := len(r.sessions)
+	r.mu.Unlock()
+
+	return Stats{
+		ActiveConnections: r.activeConns.Load(),
+		ActiveSessions:    int64(sessions),
+		TotalConnections:  r.total.Load(),
+		Rejected:          r.rejected.Load(),
+	}
+}
+
+func (r *Relay) tcpIdleTimeout() time.Duration {
+	if r.opts.IdleTimeout > 0 {
+		return r.opts.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+func (r *Relay) udpIdleTimeout() time.Duration {
+	if r.opts.IdleTimeout > 0 {
+		return r.opts.IdleTimeout
+	}
+	return DefaultUDPIdleTimeout
+}
+
+// track 登记连接以便 Close 时断开，已关闭时返回 false
+func (r *Relay) track(conns ...net.Conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return false
+	}
+	for _, conn := range conns {
+		r.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (r *Relay) untrack(conns ...net.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, conn := range conns {
+		delete(r.conns, conn)
+	}
+}
+
+func (r *Relay) serveTCP() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return
+		}
+
+		// 计数在 Accept 协程中完成，保证上限严格生效
+		if r.activeConns.Add(1) > int64(r.opts.MaxConnections) {
+			r.activeConns.Add(-1)
+			r.rejected.Add(1)
+			conn.Close()
+			continue
+		}
+		go r.handleTCP(conn)
+	}
+}
+
+func (r *Relay) handleTCP(client net.Conn) {
+	defer r.activeConns.Add(-1)
+	defer client.Close()
+
+	target, err := r.opts.Target()
+	if err != nil {
+		r.rejected.Add(1)
+		log.Printf("[Relay] %s: rejecting connection from %s: %v", r.opts.Name, client.RemoteAddr(), err)
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", target, dialTimeout)
+	if err != nil {
+		r.rejected.Add(1)
+		log.Printf("[Relay] %s: failed to connect to %s: %v", r.opts.Name, target, err)
+		return
+	}
+	defer upstream.Close()
+
+	if !r.track(client, upstream) {
+		return
+	}
+	defer r.untrack(client, upstream)
+	r.total.Add(1)
+
+	pipe(client, upstream, r.tcpIdleTimeout())
+}
+
+// pipe 双向复制直到两个方向都结束；一侧正常关闭时只关闭另一侧的写方向，出错或空闲超时时断开两侧
+func pipe(a, b net.Conn, idle time.Duration) {
+	var last atomic.Int64
+	last.Store(time.Now().UnixNano())
+
+	done := make(chan struct{}, 2)
+	copyHalf := func(dst, src net.Conn) {
+		defer func() { done <- struct{}{} }()
+		if err := copyIdle(dst, src, idle, &last); err != nil {
+			a.Close()
+			b.Close()
+			return
+		}
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go copyHalf(b, a)
+	go copyHalf(a, b)
+	<-done
+	<-done
+}
+
+// copyIdle 复制 src 到 dst，两个方向共享最近活动时间，只有两边都空闲超过 idle 才算超时
+func copyIdle(dst, src net.Conn, idle time.Duration, last *atomic.Int64) error {
+	buf := make([]byte, copyBufferSize)
+	for {
+		src.SetReadDeadline(time.Now().Add(idle))
+		n, err := src.Read(buf)
+		if n > 0 {
+			last.Store(time.Now().UnixNano())
+			dst.SetWriteDeadline(time.Now().Add(idle))
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && time.Since(time.Unix(0, last.Load())) < idle {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+func (r *Relay) serveUDP() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, client, err := r.packetConn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return
+		}
+
+		session := r.udpSession(client)
+		if session == nil {
+			continue
+		}
+		session.last.Store(time.Now().UnixNano())
+		session.upstream.Write(buf[:n])
+	}
+}
+
+// udpSession 返回客户端的会话，不存在或目标已变化时新建；达到上限或目标不可用时返回 nil
+func (r *Relay) udpSession(client net.Addr) *udpSession {
+	key := client.String()
+	target, err := r.opts.Target()
+
+	r.mu.Lock()
+	session := r.sessions[key]
+	if session != nil && (err != nil || session.target != target) {
+		// 目标已修改：丢弃旧会话，后续数据报发往新目标
+		delete(r.sessions, key)
+		session.upstream.Close()
+		session = nil
+	}
+	full := len(r.sessions) >= r.opts.MaxConnections
+	r.mu.Unlock()
+
+	if session != nil {
+		return session
+	}
+	if err != nil || full {
+		r.rejected.Add(1)
+		return nil
+	}
+
+	upstream, err := net.DialTimeout("udp", target, dialTimeout)
+	if err != nil {
+		r.rejected.Add(1)
+		log.Printf("[Relay] %s: failed to connect to %s/udp: %v", r.opts.Name, target, err)
+		return nil
+	}
+	session = &udpSession{client: client, target: target, upstream: upstream}
+	session.last.Store(time.Now().UnixNano())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.sessions[key]; existing != nil || r.closed {
+		upstream.Close()
+		return existing
+	}
+	r.sessions[key] = session
+	r.total.Add(1)
+	go r.serveUDPReplies(key, session)
+	return session
+}
+
+// serveUDPReplies 把目标的回复发回客户端，会话空闲超时后关闭
+func (r *Relay) serveUDPReplies(key string, session *udpSession) {
+	defer func() {
+		r.mu.Lock()
+		if r.sessions[key] == session {
+			delete(r.sessions, key)
+		}
+		r.mu.Unlock()
+		session.upstream.Close()
+	}()
+
+	idle := r.udpIdleTimeout()
+	buf := make([]byte, maxDatagramSize)
+	for {
+		session.upstream.SetReadDeadline(time.Now().Add(idle))
+		n, err := session.upstream.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && time.Since(time.Unix(0, session.last.Load())) < idle {
+				continue
+			}
+			return
+		}
+		session.last.Store(time.Now().UnixNano())
+		if _, err := r.packetConn.WriteTo(buf[:n], session.client); err != nil {
+			return
+		}
+	}
+}
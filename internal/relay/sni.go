@@ -0,0 +1,253 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// helloTimeout 等待客户端发送 ClientHello 的最长时间
+const helloTimeout = 10 * time.Second
+
+// errHelloRead 读取 ClientHello 后中止握手
+var errHelloRead = errors.New("client hello read")
+
+// SNIOptions TLS 透传监听器的设置
+type SNIOptions struct {
+	Listen         string        // 监听地址，例如 ":443"
+	MaxConnections int           // 最大并发连接数，0 使用 DefaultMaxConnections
+	IdleTimeout    time.Duration // 空闲超时，0 使用 DefaultIdleTimeout
+
+	// Route 按小写的 SNI 名称返回透传的 host:port 目标；target 为空且 local 为 true 表示由 Fallback 在本地终止 TLS，
+	// 两者都为空表示未知名称
+	Route func(serverName string) (target string, local bool)
+	// Default 未知名称（包括没有 SNI 的连接）转发到的 host:port，为空时交给 Fallback
+	Default string
+	// Fallback 接收需要在本地终止 TLS 的连接，连接会先重放已读取的 ClientHello；在连接的协程中调用，可以阻塞。
+	// 为空时本地名称改用 Default，仍没有目标的连接直接关闭
+	Fallback func(conn net.Conn)
+}
+
+// SNIProxy 根据 ClientHello 中的 SNI 把原始 TCP 流转发到后端，不终止 TLS，证书由后端提供
+type SNIProxy struct {
+	opts     SNIOptions
+	listener net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+
+	activeConns atomic.Int64
+	total       atomic.Int64
+	rejected    atomic.Int64
+}
+
+// ListenSNI 绑定端口并开始转发，直到 Close 被调用
+func ListenSNI(opts SNIOptions) (*SNIProxy, error) {
+	if opts.MaxConnections <= 0 {
+		opts.MaxConnections = DefaultMaxConnections
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return nil, err
+	}
+	p := &SNIProxy{
+		opts:     opts,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go p.serve()
+	return p, nil
+}
+
+// Addr 返回实际监听的地址
+func (p *SNIProxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Close 停止监听并断开所有正在转发的连接，已交给 Fallback 的连接由其自行管理
+func (p *SNIProxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	err := p.listener.Close()
+	for conn := range p.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// Stats 返回当前统计，ActiveSessions 始终为 0
+func (p *SNIProxy) Stats() Stats {
+	return Stats{
+		ActiveConnections: p.activeConns.Load(),
+		TotalConnections:  p.total.Load(),
+		Rejected:          p.rejected.Load(),
+	}
+}
+
+func (p *SNIProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return
+		}
+
+		if p.activeConns.Add(1) > int64(p.opts.MaxConnections) {
+			p.activeConns.Add(-1)
+			p.rejected.Add(1)
+			conn.Close()
+			continue
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *SNIProxy) handle(client net.Conn) {
+	handedOff := false
+	defer func() {
+		p.activeConns.Add(-1)
+		if !handedOff {
+			client.Close()
+		}
+	}()
+
+	client.SetReadDeadline(time.Now().Add(helloTimeout))
+	serverName, hello, err := readClientHello(client)
+	if err != nil {
+		p.rejected.Add(1)
+		return
+	}
+	client.SetReadDeadline(time.Time{})
+
+	target, local := "", false
+	if serverName != "" {
+		target, local = p.opts.Route(strings.ToLower(serverName))
+	}
+	if target == "" && (!local || p.opts.Fallback == nil) {
+		target = p.opts.Default
+	}
+	if target == "" {
+		if p.opts.Fallback == nil {
+			p.rejected.Add(1)
+			return
+		}
+		// 交给本地 TLS 服务，由它读取已缓存的 ClientHello
+		handedOff = true
+		p.total.Add(1)
+		p.opts.Fallback(&prefixConn{Conn: client, prefix: hello})
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", target, dialTimeout)
+	if err != nil {
+		p.rejected.Add(1)
+		log.Printf("[SNI] %s: failed to connect to %s: %v", serverName, target, err)
+		return
+	}
+	defer upstream.Close()
+
+	if !p.track(client, upstream) {
+		return
+	}
+	defer p.untrack(client, upstream)
+	p.total.Add(1)
+
+	upstream.SetWriteDeadline(time.Now().Add(p.opts.IdleTimeout))
+	if _, err := upstream.Write(hello); err != nil {
+		return
+	}
+	pipe(client, upstream, p.opts.IdleTimeout)
+}
+
+func (p *SNIProxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+	for _, conn := range conns {
+		p.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (p *SNIProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conn := range conns {
+		delete(p.conns, conn)
+	}
+}
+
+// readClientHello 借助 crypto/tls 解析 ClientHello，返回 SNI 和读取过的原始字节
+// 握手在拿到 ClientHello 后中止，不会向客户端写任何数据
+func readClientHello(conn net.Conn) (string, []byte, error) {
+	var hello bytes.Buffer
+	var serverName string
+	var parsed bool
+
+	tlsConn := tls.Server(helloConn{Conn: conn, reader: io.TeeReader(conn, &hello)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName, parsed = info.ServerName, true
+			return nil, errHelloRead
+		},
+	})
+	tlsConn.Handshake()
+
+	if !parsed {
+		return "", nil, errors.New("not a TLS client hello")
+	}
+	return serverName, hello.Bytes(), nil
+}
+
+// helloConn 只读的连接，供解析 ClientHello 使用：读取经过 reader 记录，写入、关闭和超时设置都不会影响原连接
+type helloConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c helloConn) Read(b []byte) (int, error)  { return c.reader.Read(b) }
+func (c helloConn) Write(b []byte) (int, error) { return 0, io.ErrClosedPipe }
+func (c helloConn) Close() error                { return nil }
+func (c helloConn) SetDeadline(time.Time) error { return nil }
+
+func (c helloConn) SetReadDeadline(time.Time) error  { return nil }
+func (c helloConn) SetWriteDeadline(time.Time) error { return nil }
+
+// prefixConn 先返回已读取的字节，再从原连接读取
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(b []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(b, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+// clientHello 用 crypto/tls 客户端发起握手，返回服务端一侧的连接；测试结束时关闭两端
+func clientHello(t *testing.T, serverName string) net.Conn {
+	t.Helper()
+
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	go func() {
+		tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+	}()
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	return server
+}
+
+func TestReadClientHello(t *testing.T) {
+	for _, serverName := range []string{"www.example.com", ""} {
+		t.Run("sni="+serverName, func(t *testing.T) {
+			conn := clientHello(t, serverName)
+
+			got, hello, err := readClientHello(conn)
+			if err != nil {
+				t.Fatalf("readClientHello: %v", err)
+			}
+			if got != serverName {
+				t.Errorf("server name = %q, want %q", got, serverName)
+			}
+			// 读取过的字节是完整的 TLS 握手记录
+			if len(hello) < 5 || hello[0] != 0x16 || len(hello) != 5+int(hello[3])<<8+int(hello[4]) {
+				t.Fatalf("hello bytes are not a single handshake record: % x", hello[:min(len(hello), 5)])
+			}
+
+			// 读取过的字节原样交给后端：重放后可以再次解析出相同的 SNI
+			other, peer := net.Pipe()
+			peer.Close()
+			defer other.Close()
+			replayed, _, err := readClientHello(&prefixConn{Conn: other, prefix: hello})
+			if err != nil || replayed != serverName {
+				t.Errorf("replayed hello = %q, %v; want %q", replayed, err, serverName)
+			}
+		})
+	}
+}
+
+func TestReadClientHelloNotTLS(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		client.Write([]byte("GET / HTTP/1.1\r\nHost: www.example.com\r\n\r\n"))
+		client.Close()
+	}()
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, _, err := readClientHello(server); err == nil {
+		t.Error("readClientHello accepted a plain HTTP request")
+	}
+}
@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"regexp"
+	"strings"
+
+	"redirect_helper/internal/models"
+)
+
+// PathRules 编译后的一组路径规则，可以在多个请求间共享
+type PathRules struct {
+	rules []compiledPathRule
+}
+
+type compiledPathRule struct {
+	re     *regexp.Regexp
+	target string
+	status int
+}
+
+// PathResult 路径规则的匹配结果
+type PathResult struct {
+	Target string
+	Status int
+	// Rule 匹配的规则序号（从 1 开始）
+	Rule int
+}
+
+// CompilePathRules 编译路径规则；前缀规则转换为 ^前缀(.*)$，剩余部分为 $1
+func CompilePathRules(rules []models.PathRule) (*PathRules, error) {
+	compiled := &PathRules{rules: make([]compiledPathRule, 0, len(rules))}
+	for _, rule := range rules {
+		pattern := rule.Pattern
+		if rule.Type == "prefix" {
+			pattern = "^" + regexp.QuoteMeta(rule.Pattern) + "(.*)$"
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled.rules = append(compiled.rules, compiledPathRule{re: re, target: rule.Target, status: rule.Status})
+	}
+	return compiled, nil
+}
+
+// Match 按顺序匹配路径（URL 编码形式），返回第一条匹配规则替换后的目标
+// 原请求的查询参数追加到目标的查询参数后面
+func (p *PathRules) Match(path, rawQuery string) (PathResult, bool) {
+	if p == nil {
+		return PathResult{}, false
+	}
+	for i, rule := range p.rules {
+		match := rule.re.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		target := string(rule.re.ExpandString(nil, rule.target, path, match))
+		return PathResult{Target: appendQuery(target, rawQuery), Status: rule.status, Rule: i + 1}, true
+	}
+	return PathResult{}, false
+}
+
+// appendQuery 把查询参数追加到地址的查询参数后面，保留地址中的 #fragment
+func appendQuery(target, rawQuery string) string {
+	if rawQuery == "" {
+		return target
+	}
+	target, fragment, hasFragment := strings.Cut(target, "#")
+	if strings.Contains(target, "?") {
+		target += "&" + rawQuery
+	} else {
+		target += "?" + rawQuery
+	}
+	if hasFragment {
+		target += "#" + fragment
+	}
+	return target
+}
@@ -0,0 +1,50 @@
+package routing
+
+import (
+	"testing"
+
+	"redirect_helper/internal/models"
+)
+
+func TestPathRulesMatch(t *testing.T) {
+	rules, err := CompilePathRules([]models.PathRule{
+		{Type: "regex", Pattern: `^/blog/(\d+)$`, Target: "https://new.example.com/posts/$1", Status: 301},
+		{Type: "prefix", Pattern: "/docs/", Target: "https://docs.example.com/$1", Status: 302},
+		{Type: "regex", Pattern: `^/u/(?P<user>[a-z]+)`, Target: "https://new.example.com/users/${user}?x=1#top", Status: 308},
+		{Type: "prefix", Pattern: "/a.b/", Target: "https://dots.example.com/${1}_x", Status: 307},
+		{Type: "prefix", Pattern: "/fixed", Target: "https://fixed.example.com/", Status: 302},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path, query string
+		want        string
+		wantStatus  int
+		wantRule    int
+	}{
+		{path: "/blog/42", want: "https://new.example.com/posts/42", wantStatus: 301, wantRule: 1},
+		{path: "/blog/42/comments", want: ""},
+		{path: "/docs/a/b%20c", query: "q=1", want: "https://docs.example.com/a/b%20c?q=1", wantStatus: 302, wantRule: 2},
+		{path: "/docs/", want: "https://docs.example.com/", wantStatus: 302, wantRule: 2},
+		{path: "/u/alice", query: "ref=t", want: "https://new.example.com/users/alice?x=1&ref=t#top", wantStatus: 308, wantRule: 3},
+		// 前缀中的 . 按字面匹配
+		{path: "/a.b/c", want: "https://dots.example.com/c_x", wantStatus: 307, wantRule: 4},
+		{path: "/aXb/c", want: ""},
+		{path: "/fixed/anything", want: "https://fixed.example.com/", wantStatus: 302, wantRule: 5},
+		{path: "/other", want: ""},
+	}
+	for _, tt := range tests {
+		result, ok := rules.Match(tt.path, tt.query)
+		if ok != (tt.want != "") || result.Target != tt.want || result.Status != tt.wantStatus || result.Rule != tt.wantRule {
+			t.Errorf("Match(%q, %q) = %+v, %v; want %q status %d rule %d", tt.path, tt.query, result, ok, tt.want, tt.wantStatus, tt.wantRule)
+		}
+	}
+}
+
+func TestCompilePathRulesInvalidRegex(t *testing.T) {
+	if _, err := CompilePathRules([]models.PathRule{{Type: "regex", Pattern: "(", Target: "https://example.com"}}); err == nil {
+		t.Error("CompilePathRules accepted an invalid regex")
+	}
+}
@@ -0,0 +1,187 @@
+// Package routing 按条目的路由规则为一次访问选择目标
+// 规则按顺序匹配，第一条匹配的规则决定目标，都不匹配时使用条目的默认目标
+package routing
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"redirect_helper/internal/geoip"
+	"redirect_helper/internal/models"
+)
+
+// Request 规则匹配用到的访问者信息
+type Request struct {
+	ClientIP netip.Addr
+	// GeoIP 为 nil 时国家和 ASN 条件都不匹配
+	GeoIP *geoip.DB
+	// Header 请求头，User-Agent 和 Accept-Language 也从这里读取
+	Header http.Header
+	Query  url.Values
+
+	geo       geoip.Info
+	geoLoaded bool
+}
+
+// Device 访问者的设备类型：ios、android 或 desktop，没有 User-Agent 时为空
+func (req *Request) Device() string {
+	return Device(req.Header.Get("User-Agent"))
+}
+
+// Language 访问者的首选语言（小写），没有 Accept-Language 时为空
+func (req *Request) Language() string {
+	return PreferredLanguage(req.Header.Get("Accept-Language"))
+}
+
+// Geo 返回访问者的国家和 ASN，只在第一次用到时查询数据库
+func (req *Request) Geo() geoip.Info {
+	if !req.geoLoaded {
+		req.geo = req.GeoIP.Lookup(req.ClientIP)
+		req.geoLoaded = true
+	}
+	return req.geo
+}
+
+// Result 选择结果
+type Result struct {
+	Target string `json:"target"`
+	// Rule 匹配的规则序号（从 1 开始），0 表示使用默认目标
+	Rule int `json:"rule"`
+}
+
+// Resolve 按顺序匹配规则，返回第一条匹配规则的目标，都不匹配时返回 defaultTarget
+func Resolve(rules []models.RouteRule, defaultTarget string, req *Request) Result {
+	for i, rule := range rules {
+		if Match(rule, req) {
+			return Result{Target: rule.Target, Rule: i + 1}
+		}
+	}
+	return Result{Target: defaultTarget}
+}
+
+// Match 规则中已设置的条件是否全部满足；每个条件的列表中满足任意一项即可
+func Match(rule models.RouteRule, req *Request) bool {
+	if len(rule.CIDRs) > 0 && !matchCIDRs(rule.CIDRs, req.ClientIP) {
+		return false
+	}
+	if len(rule.Countries) > 0 {
+		country := req.Geo().Country
+		if country == "" || !slices.Contains(rule.Countries, country) {
+			return false
+		}
+	}
+	if len(rule.ASNs) > 0 {
+		asn := req.Geo().ASN
+		if asn == 0 || !slices.Contains(rule.ASNs, asn) {
+			return false
+		}
+	}
+	if len(rule.Devices) > 0 {
+		device := req.Device()
+		if device == "" || !slices.Contains(rule.Devices, device) {
+			return false
+		}
+	}
+	if len(rule.Languages) > 0 && !matchLanguages(rule.Languages, req.Language()) {
+		return false
+	}
+	if len(rule.Headers) > 0 && !matchFields(rule.Headers, req.Header.Values) {
+		return false
+	}
+	if len(rule.Query) > 0 && !matchFields(rule.Query, func(name string) []string { return req.Query[name] }) {
+		return false
+	}
+	return true
+}
+
+// Device 按 User-Agent 判断设备类型；iPadOS 默认使用桌面版 Safari 的 User-Agent，会被识别为 desktop
+func Device(userAgent string) string {
+	switch {
+	case userAgent == "":
+		return ""
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return "ios"
+	case strings.Contains(userAgent, "Android"):
+		return "android"
+	default:
+		return "desktop"
+	}
+}
+
+// PreferredLanguage 返回 Accept-Language 中 q 值最高的语言（小写），相同 q 值时取靠前的一项
+func PreferredLanguage(acceptLanguage string) string {
+	type candidate struct {
+		language string
+		q        float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		language, params, _ := strings.Cut(part, ";")
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" || language == "*" {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{language, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].language
+}
+
+// matchLanguages 规则中的语言等于首选语言或是它的前缀，例如 "zh" 匹配 "zh-cn"
+func matchLanguages(languages []string, preferred string) bool {
+	if preferred == "" {
+		return false
+	}
+	for _, language := range languages {
+		if preferred == language || strings.HasPrefix(preferred, language+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFields 任意一个条件满足即可：Value 为空时只要求存在，否则要求某个值完全相同
+func matchFields(fields []models.FieldMatch, values func(name string) []string) bool {
+	for _, field := range fields {
+		actual := values(field.Name)
+		if len(actual) == 0 {
+			continue
+		}
+		if field.Value == "" || slices.Contains(actual, field.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCIDRs(cidrs []string, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, cidr := range cidrs {
+		// 保存时已经校验过格式
+		if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"redirect_helper/internal/models"
+)
+
+func TestDevice(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"", ""},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15", "ios"},
+		{"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15", "ios"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", "android"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", "desktop"},
+		// iPadOS 默认发送桌面版 Safari 的 User-Agent
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15", "desktop"},
+		{"curl/8.5.0", "desktop"},
+	}
+	for _, tt := range tests {
+		if got := Device(tt.userAgent); got != tt.want {
+			t.Errorf("Device(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"zh-CN", "zh-cn"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh-cn"},
+		{"en;q=0.5, zh-TW;q=0.9", "zh-tw"},
+		{"en, zh-CN;q=0.9", "en"},
+		{"fr;q=0.8, de;q=0.8", "fr"}, // 相同 q 值取靠前的一项
+		{"*, en;q=0.5", "en"},
+		{"ja;q=0, en;q=0.1", "en"},
+		{"de;q=abc, it", "it"},
+	}
+	for _, tt := range tests {
+		if got := PreferredLanguage(tt.header); got != tt.want {
+			t.Errorf("PreferredLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	rules := []models.RouteRule{
+		{CIDRs: []string{"10.0.0.0/8"}, Target: "https://lan.example.com"},
+		{Devices: []string{"ios"}, Target: "https://apps.apple.com/app"},
+		{Devices: []string{"android"}, Languages: []string{"zh"}, Target: "https://example.cn/app.apk"},
+		{Devices: []string{"android"}, Target: "https://play.google.com/app"},
+		{Languages: []string{"zh-tw"}, Target: "https://example.com/zh-tw/"},
+		{Headers: []models.FieldMatch{{Name: "X-Beta", Value: "1"}}, Target: "https://beta.example.com"},
+		{Query: []models.FieldMatch{{Name: "preview"}}, Target: "https://preview.example.com"},
+		// GeoIP 未配置时国家条件不匹配
+		{Countries: []string{"CN"}, Target: "https://mirror.example.cn"},
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		header   map[string]string
+		query    string
+		want     string
+		wantRule int
+	}{
+		{name: "default", want: "https://example.com"},
+		{name: "cidr", ip: "10.1.2.3", header: map[string]string{"User-Agent": "Android"}, want: "https://lan.example.com", wantRule: 1},
+		{name: "ipv4-mapped cidr", ip: "::ffff:10.1.2.3", want: "https://lan.example.com", wantRule: 1},
+		{name: "ios", ip: "198.51.100.7", header: map[string]string{"User-Agent": "Mozilla/5.0 (iPhone)"}, want: "https://apps.apple.com/app", wantRule: 2},
+		{name: "android and language", header: map[string]string{"User-Agent": "Android 14", "Accept-Language": "zh-CN"}, want: "https://example.cn/app.apk", wantRule: 3},
+		{name: "android other language", header: map[string]string{"User-Agent": "Android 14", "Accept-Language": "en-US"}, want: "https://play.google.com/app", wantRule: 4},
+		{name: "language subtag", header: map[string]string{"Accept-Language": "zh-TW"}, want: "https://example.com/zh-tw/", wantRule: 5},
+		{name: "language prefix does not match shorter tag", header: map[string]string{"Accept-Language": "zh"}, want: "https://example.com"},
+		{name: "header value", header: map[string]string{"X-Beta": "1"}, want: "https://beta.example.com", wantRule: 6},
+		{name: "header other value", header: map[string]string{"X-Beta": "2"}, want: "https://example.com"},
+		{name: "query presence", query: "preview", want: "https://preview.example.com", wantRule: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Header: http.Header{}}
+			if tt.ip != "" {
+				req.ClientIP = netip.MustParseAddr(tt.ip)
+			}
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			req.Query, _ = url.ParseQuery(tt.query)
+
+			result := Resolve(rules, "https://example.com", req)
+			if result.Target != tt.want || result.Rule != tt.wantRule {
+				t.Errorf("Resolve() = %q (rule %d), want %q (rule %d)", result.Target, result.Rule, tt.want, tt.wantRule)
+			}
+		})
+	}
+}
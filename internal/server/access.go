@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+const (
+	// linkCookiePrefix 访问密码通过后设置的 cookie，名称后接条目名称
+	linkCookiePrefix = "redirect_helper_link_"
+	// linkCookieTTL 输入一次密码后免密访问的时间
+	linkCookieTTL = 30 * time.Minute
+	// maxPasswordForm 密码表单的请求体大小上限
+	maxPasswordForm = 4 << 10
+)
+
+// newLinkKey 生成签名访问 cookie 的密钥，只保存在内存中，服务重启后需要重新输入密码
+func newLinkKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate link cookie key: %v", err))
+	}
+	return key
+}
+
+// linkSignature 签名覆盖条目名称、密码哈希的摘要和过期时间：重新设置密码（包括删除后重新创建条目）后旧 cookie 失效，
+// 修改目标等其它设置不影响已输入密码的访问者
+func (s *Server) linkSignature(name, passwordDigest string, expires int64) string {
+	mac := hmac.New(sha256.New, s.linkKey)
+	fmt.Fprintf(mac, "%s\n%s\n%d", name, passwordDigest, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validLinkCookie 请求是否带有该条目未过期的访问 cookie
+func (s *Server) validLinkCookie(r *http.Request, entry *models.ForwardingEntry) bool {
+	cookie, err := r.Cookie(linkCookiePrefix + entry.Name)
+	if err != nil {
+		return false
+	}
+	expiresValue, signature, found := strings.Cut(cookie.Value, ".")
+	if !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresValue, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	digest := s.configStorage.ForwardingPasswordDigest(entry.Name)
+	if digest == "" {
+		return false
+	}
+	expected := s.linkSignature(entry.Name, digest, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// setLinkCookie 密码通过后设置访问 cookie，只在跳转路径下发送
+func (s *Server) setLinkCookie(w http.ResponseWriter, r *http.Request, entry *models.ForwardingEntry) {
+	expires := time.Now().Add(linkCookieTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkCookiePrefix + entry.Name,
+		Value:    strconv.FormatInt(expires.Unix(), 10) + "." + s.linkSignature(entry.Name, s.configStorage.ForwardingPasswordDigest(entry.Name), expires.Unix()),
+		Path:     s.basePath + "/go/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// guardForwarding 检查路径跳转的访问限制：次数用完返回 410，需要密码时显示密码表单或校验提交的密码
+// 返回 true 表示已经写入响应
+func (s *Server) guardForwarding(w http.ResponseWriter, r *http.Request, name string) bool {
+	entry, err := s.storage.GetForwarding(name)
+	if err != nil {
+		return false
+	}
+
+	if entry.MaxUses > 0 && entry.Uses >= entry.MaxUses {
+		http.Error(w, "This link is no longer available", http.StatusGone)
+		return true
+	}
+	if !entry.HasPassword || s.configStorage == nil || s.validLinkCookie(r, entry) {
+		return false
+	}
+
+	data := map[string]interface{}{"Name": entry.Name}
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, maxPasswordForm)
+		if s.configStorage.ValidateForwardingPassword(name, r.PostFormValue("password")) {
+			s.setLinkCookie(w, r, entry)
+			// 重新以 GET 访问原地址，之后按正常流程跳转
+			http.Redirect(w, r, r.RequestURI, http.StatusSeeOther)
+			return true
+		}
+		log.Printf("[Access] %s: wrong password from %s", name, s.ClientIP(r))
+		data["Error"] = "Incorrect password"
+	}
+
+	s.renderPage(w, http.StatusUnauthorized, "password", data)
+	return true
+}
+
+// consumeForwardingUse 记录一次成功跳转，次数已用完时返回 410
+func (s *Server) consumeForwardingUse(w http.ResponseWriter, name string) bool {
+	if s.configStorage == nil {
+		return false
+	}
+
+	err := s.configStorage.ConsumeForwardingUse(name)
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, config.ErrLinkExhausted):
+		http.Error(w, "This link is no longer available", http.StatusGone)
+	default:
+		log.Printf("[Access] %s: failed to record use: %v", name, err)
+		http.Error(w, "Failed to record link use", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// handleAccess 设置路径条目的访问限制，条目所属用户和管理员可以操作
+// POST /api/access?name=docs，表单字段 password（空字符串取消密码）和 max_uses（0 取消限制），未提交的字段保持不变
+// 两项在一个事务中修改，任何一项无效时都不会生效
+func (s *Server) handleAccess(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	params := map[string]string{"name": name}
+
+	if r.Method != http.MethodPost {
+		s.logAPIRequest(r, "/api/access", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/access", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	// 密码放在请求体中，避免出现在访问日志里
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordForm)
+	err := r.ParseForm()
+	if err == nil && name == "" {
+		err = errors.New("Missing required parameter: name")
+	}
+	var change config.AccessChange
+	if values, ok := r.PostForm["password"]; ok && err == nil {
+		params["password"] = "***"
+		change.Password = &values[0]
+	}
+	if value := r.Form.Get("max_uses"); value != "" && err == nil {
+		params["max_uses"] = value
+		maxUses, parseErr := strconv.Atoi(value)
+		if parseErr != nil {
+			err = errors.New("invalid max_uses, expected a number")
+		}
+		change.MaxUses = &maxUses
+	}
+	if err == nil && change.Password == nil && change.MaxUses == nil {
+		err = errors.New("Nothing to change: provide password and/or max_uses")
+	}
+	if err == nil {
+		err = s.configStorage.SetForwardingAccess(&actor, name, change)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/access", params, "error:"+err.Error(), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var version int64
+	if entry, err := s.configStorage.GetForwarding(name); err == nil {
+		version = entry.Version
+	}
+
+	s.logAPIRequest(r, "/api/access", params, "success", http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:   "success",
+		Version: version,
+	})
+}
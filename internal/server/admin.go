@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+// handleStats 返回条目数量、上限和运行状态，供管理界面显示
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	forwardings, err := s.configStorage.ListForwardings()
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+	domains, err := s.configStorage.ListDomains()
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	unconfigured := 0
+	for _, f := range forwardings {
+		if f.Target == "" {
+			unconfigured++
+		}
+	}
+	for _, d := range domains {
+		if d.Target == "" {
+			unconfigured++
+		}
+	}
+
+	stats := map[string]interface{}{
+		"forwardings":    len(forwardings),
+		"domains":        len(domains),
+		"unconfigured":   unconfigured,
+		"started_at":     s.startedAt,
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+		"health":         s.currentHealth(),
+	}
+	if serverConfig := s.configStorage.GetServerConfig(); serverConfig != nil {
+		stats["max_redirect_count"] = serverConfig.MaxRedirectCount
+		stats["max_domain_count"] = serverConfig.MaxDomainCount
+	}
+	tokenRequests := make(map[string]int64)
+	for _, c := range s.tokenUsage.snapshot() {
+		tokenRequests[c.use.String()] = c.count
+	}
+	stats["token_requests"] = tokenRequests
+	stats["retired_tokens"] = s.configStorage.RetiredTokens()
+	if s.webhooks != nil {
+		stats["webhook_subscriptions"] = len(s.webhooks.Subscriptions())
+		stats["webhook_pending"] = len(s.webhooks.Pending())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state": "success",
+		"stats": stats,
+	})
+}
+
+// handleTokens 返回 redirect token 和 domain token，admin token 不会通过接口返回
+// retired_tokens 列出轮换后仍在宽限期内的旧 token 及其过期时间（不含 token 值）
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":          "success",
+		"redirect_token": s.configStorage.GetRedirectToken(),
+		"domain_token":   s.configStorage.GetDomainToken(),
+		"retired_tokens": s.configStorage.RetiredTokens(),
+	})
+}
+
+// handleResetToken 重新生成指定类型的 token 并返回新值（POST /api/tokens/reset?type=redirect）
+// 旧 token 立即失效；重置 admin token 后需要用新 token 重新登录
+func (s *Server) handleResetToken(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodPost) {
+		return
+	}
+
+	tokenType := r.URL.Query().Get("type")
+	params := map[string]string{"type": tokenType}
+
+	var setToken func(string) error
+	switch tokenType {
+	case "admin":
+		setToken = s.configStorage.SetAdminToken
+	case "redirect":
+		setToken = s.configStorage.SetRedirectToken
+	case "domain":
+		setToken = s.configStorage.SetDomainToken
+	default:
+		s.logAPIRequest(r, "/api/tokens/reset", params, "invalid_type", http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Invalid type. Use admin, redirect or domain",
+		})
+		return
+	}
+
+	token, err := s.generateToken(32)
+	if err == nil {
+		err = setToken(token)
+	}
+	if err != nil {
+		s.logAPIRequest(r, "/api/tokens/reset", params, "error:"+err.Error(), http.StatusInternalServerError)
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.logAPIRequest(r, "/api/tokens/reset", params, "success", http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state": "success",
+		"type":  tokenType,
+		"token": token,
+	})
+}
+
+// handleRotateToken 轮换指定类型的 token（POST /api/tokens/rotate?type=redirect&grace=24h）
+// 新 token 立即生效，旧 token 在宽限期内继续有效，之后自动失效；grace 默认 24h，最长 720h
+func (s *Server) handleRotateToken(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodPost) {
+		return
+	}
+
+	tokenType := r.URL.Query().Get("type")
+	params := map[string]string{
+		"type":  tokenType,
+		"grace": r.URL.Query().Get("grace"),
+	}
+
+	if tokenType != config.TokenAdmin && tokenType != config.TokenRedirect && tokenType != config.TokenDomain {
+		s.logAPIRequest(r, "/api/tokens/rotate", params, "invalid_type", http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Invalid type. Use admin, redirect or domain",
+		})
+		return
+	}
+
+	grace := config.DefaultTokenGracePeriod
+	if value := r.URL.Query().Get("grace"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > config.MaxTokenGracePeriod {
+			s.logAPIRequest(r, "/api/tokens/rotate", params, "invalid_grace", http.StatusBadRequest)
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: "Invalid grace. Use a duration such as 30m or 24h, at most 720h",
+			})
+			return
+		}
+		grace = parsed
+	}
+
+	token, expiresAt, err := s.configStorage.RotateToken(tokenType, grace)
+	if err != nil {
+		s.logAPIRequest(r, "/api/tokens/rotate", params, "error:"+err.Error(), http.StatusInternalServerError)
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.logAPIRequest(r, "/api/tokens/rotate", params, "success", http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	response := map[string]interface{}{
+		"state": "success",
+		"type":  tokenType,
+		"token": token,
+	}
+	// 之前没有设置 token 时没有需要保留的旧 token
+	if !expiresAt.IsZero() {
+		response["previous_expires_at"] = expiresAt
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// authorizeAdminRequest 检查请求方法、admin token 和存储，失败时写入错误响应
+func (s *Server) authorizeAdminRequest(w http.ResponseWriter, r *http.Request, method string) bool {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return false
+	}
+
+	if r.Method != method {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return false
+	}
+
+	// 检查管理员认证
+	if authErr := s.adminAuth(r, false); authErr != nil {
+		s.writeAuthError(w, authErr)
+		return false
+	}
+
+	if s.configStorage == nil {
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: "Storage not available",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// normalizeBasePath 将 base_path 规范为 "/redirect" 形式，根路径返回空字符串
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// withBasePath 让服务挂载在 base_path 下，例如反向代理的 /redirect/ 路径
+// 域名跳转按 Host 匹配，不受 base_path 影响；健康检查保留在根路径便于探针访问
+func (s *Server) withBasePath(mux *http.ServeMux) http.Handler {
+	if s.basePath == "" {
+		return mux
+	}
+
+	stripped := http.StripPrefix(s.basePath, mux)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.checkDomainRedirect(w, r) {
+			return
+		}
+
+		switch {
+		case r.URL.Path == s.basePath:
+			http.Redirect(w, r, s.basePath+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, s.basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz":
+			mux.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+// handleBatchUpdate 处理批量更新请求 (支持 GET 和 POST)
+func (s *Server) handleBatchUpdate(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	var req models.BatchUpdateRequest
+
+	// 根据请求方法解析参数
+	if r.Method == http.MethodGet {
+		// GET 方式: 使用索引后缀 name1=xxx&target1=xxx&domain2=xxx&target2=xxx
+		var err error
+		if req, err = s.parseGetBatchUpdate(r); err != nil {
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: "Invalid query: " + err.Error(),
+			})
+			return
+		}
+	} else if r.Method == http.MethodPost {
+		// POST 方式: 使用 JSON body
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: "Invalid JSON body: " + err.Error(),
+			})
+			return
+		}
+	} else {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed. Use GET or POST",
+		})
+		return
+	}
+
+	entries := req.Entries
+	atomic := req.Atomic
+	dryRun := req.DryRun
+
+	// 验证是否有条目
+	if len(entries) == 0 {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "No entries to update",
+		})
+		return
+	}
+
+	if s.configStorage == nil {
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: "Storage not available",
+		})
+		return
+	}
+
+	// 请求中没有 token 时，用 Bearer（admin token 或用户 API key）或浏览器登录会话识别身份，
+	// 会话认证的写请求必须带 CSRF token；普通用户只能操作自己的条目
+	var actor *config.Actor
+	if req.AdminToken == "" && req.RedirectToken == "" && req.DomainToken == "" {
+		if s.hasCredentials(r) {
+			authenticated, authErr := s.authenticate(r, true)
+			if authErr != nil {
+				s.writeAuthError(w, authErr)
+				return
+			}
+			actor = &authenticated
+		}
+	} else if req.AdminToken == "" {
+		// admin token 也可以通过 Authorization: Bearer 请求头提供
+		req.AdminToken = requestAdminToken(r)
+	}
+
+	// 处理批量更新：所有条目在一个事务中应用，只保存一次
+	ops := make([]config.BatchOperation, len(entries))
+	for i, entry := range entries {
+		ops[i] = config.BatchOperation{
+			Op:        strings.ToLower(entry.Op),
+			Name:      entry.Name,
+			Domain:    entry.Domain,
+			Target:    entry.Target,
+			NewName:   entry.NewName,
+			NewDomain: entry.NewDomain,
+
+			ExpectedVersion: entry.ExpectedVersion,
+		}
+	}
+
+	batchResults, batchErr := s.configStorage.ApplyBatch(ops, config.BatchOptions{
+		AdminToken:    req.AdminToken,
+		RedirectToken: req.RedirectToken,
+		DomainToken:   req.DomainToken,
+		Actor:         actor,
+		Atomic:        atomic,
+		DryRun:        dryRun,
+		SelfHosts:     []string{r.Host},
+	})
+
+	results := make([]models.BatchUpdateEntryResult, 0, len(entries))
+	succeeded := 0
+	failed := 0
+
+	// 结果与请求中的条目顺序一致
+	for i, entry := range entries {
+		result := models.BatchUpdateEntryResult{
+			Op:        entry.Op,
+			Name:      entry.Name,
+			Domain:    entry.Domain,
+			Target:    entry.Target,
+			NewName:   entry.NewName,
+			NewDomain: entry.NewDomain,
+		}
+
+		switch {
+		case batchResults[i].Err != nil:
+			result.Error = batchResults[i].Err.Error()
+		case errors.Is(batchErr, config.ErrBatchRolledBack):
+			result.Error = "Not applied: batch rolled back"
+		case batchErr != nil:
+			result.Error = batchErr.Error()
+		default:
+			result.Success = true
+			result.Version = batchResults[i].Version
+		}
+
+		if result.Success {
+			succeeded++
+		} else {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	// 构建响应
+	response := models.BatchUpdateResponse{
+		State:   "success",
+		Atomic:  atomic,
+		DryRun:  dryRun,
+		Results: results,
+		Summary: models.BatchUpdateSummary{
+			Total:     len(entries),
+			Succeeded: succeeded,
+			Failed:    failed,
+		},
+	}
+
+	// 原子模式因版本冲突回滚时返回 409，其它情况按条目报告结果
+	status := http.StatusOK
+
+	// 如果全部失败，设置状态为 error
+	if errors.Is(batchErr, config.ErrBatchRolledBack) {
+		response.State = "error"
+		response.Message = fmt.Sprintf("Atomic batch rolled back: %d entries failed validation", countFailed(batchResults))
+		if hasVersionConflict(batchResults) {
+			status = http.StatusConflict
+		}
+	} else if failed == len(entries) {
+		response.State = "error"
+		response.Message = "All entries failed to update"
+	} else if failed > 0 {
+		response.State = "partial"
+		response.Message = fmt.Sprintf("%d succeeded, %d failed", succeeded, failed)
+	} else {
+		response.Message = "All entries updated successfully"
+	}
+
+	if dryRun {
+		response.Message = "Dry run, nothing persisted: " + response.Message
+	}
+
+	// 记录日志
+	s.logAPIRequest(r, "/api/batch-update", map[string]string{
+		"method":  r.Method,
+		"total":   fmt.Sprintf("%d", len(entries)),
+		"atomic":  fmt.Sprintf("%t", atomic),
+		"dry_run": fmt.Sprintf("%t", dryRun),
+	}, response.State, status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// countFailed 统计失败的条目数量
+func countFailed(results []config.BatchResult) int {
+	count := 0
+	for _, result := range results {
+		if result.Err != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// hasVersionConflict 检查是否有条目因版本冲突失败
+func hasVersionConflict(results []config.BatchResult) bool {
+	for _, result := range results {
+		if errors.Is(result.Err, config.ErrVersionConflict) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGetBatchUpdate 解析 GET 请求的批量更新参数
+// 格式: name1=xxx&target1=xxx&expected_version1=3&op2=delete&name2=xxx&op3=rename&domain3=xxx&new_domain3=xxx
+// 条目按索引数值升序返回，保证结果顺序与调用方一致
+func (s *Server) parseGetBatchUpdate(r *http.Request) (models.BatchUpdateRequest, error) {
+	query := r.URL.Query()
+
+	req := models.BatchUpdateRequest{
+		AdminToken:    query.Get("admin_token"),
+		RedirectToken: query.Get("redirect_token"),
+		DomainToken:   query.Get("domain_token"),
+		Atomic:        parseBool(query.Get("atomic")),
+		DryRun:        parseBool(query.Get("dry_run")),
+		Entries:       make([]models.BatchUpdateEntry, 0),
+	}
+
+	// 查找所有的索引
+	// new_name/new_domain 需要排在 name/domain 之前匹配
+	prefixes := []string{"new_name", "new_domain", "expected_version", "name", "domain", "target", "op"}
+	// 保留原始的索引字符串：name01 和 name1 是不同的条目，只按数值排序
+	indexMap := make(map[string]bool)
+	for key := range query {
+		for _, prefix := range prefixes {
+			idx, ok := strings.CutPrefix(key, prefix)
+			if !ok {
+				continue
+			}
+			// 验证 idx 是否全为数字
+			if isNumeric(idx) {
+				indexMap[idx] = true
+			}
+			break
+		}
+	}
+
+	indexes := make([]string, 0, len(indexMap))
+	for idx := range indexMap {
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return lessNumeric(indexes[i], indexes[j])
+	})
+
+	// 按索引提取条目
+	for _, idx := range indexes {
+		entry := models.BatchUpdateEntry{
+			Op:        query.Get("op" + idx),
+			Name:      query.Get("name" + idx),
+			Domain:    query.Get("domain" + idx),
+			Target:    query.Get("target" + idx),
+			NewName:   query.Get("new_name" + idx),
+			NewDomain: query.Get("new_domain" + idx),
+		}
+		// 无法解析的版本号直接拒绝，否则条目会变成不带版本检查的写入
+		if value := query.Get("expected_version" + idx); value != "" {
+			version, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return req, fmt.Errorf("expected_version%s is not an integer: %q", idx, value)
+			}
+			entry.ExpectedVersion = version
+		}
+
+		// 只有指定了 name 或 domain 的条目才有效，其余校验交给批量处理
+		if entry.Name != "" || entry.Domain != "" {
+			req.Entries = append(req.Entries, entry)
+		}
+	}
+
+	return req, nil
+}
+
+// isNumeric 检查字符串是否全为数字
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// lessNumeric 按数值比较两个数字字符串，数值相同时（例如 "1" 和 "01"）按字符串比较，不受长度限制
+func lessNumeric(a, b string) bool {
+	trimmedA, trimmedB := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(trimmedA) != len(trimmedB) {
+		return len(trimmedA) < len(trimmedB)
+	}
+	if trimmedA != trimmedB {
+		return trimmedA < trimmedB
+	}
+	return a < b
+}
+
+// parseBool 解析查询参数中的布尔值，无法解析时视为 false
+func parseBool(value string) bool {
+	b, err := strconv.ParseBool(value)
+	return err == nil && b
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseGetBatchUpdateIndexes(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet,
+		"/api/batch-update?name10=j&target10=tj&name2=b&target2=tb&name01=a0&target01=ta0&name1=a&target1=ta&domain3=c.example.com", nil)
+
+	req, err := (&Server{}).parseGetBatchUpdate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ name, domain, target string }{
+		{"a0", "", "ta0"},
+		{"a", "", "ta"},
+		{"b", "", "tb"},
+		{"", "c.example.com", ""},
+		{"j", "", "tj"},
+	}
+	if len(req.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(req.Entries), len(want), req.Entries)
+	}
+	for i, w := range want {
+		entry := req.Entries[i]
+		if entry.Name != w.name || entry.Domain != w.domain || entry.Target != w.target {
+			t.Errorf("entry %d = %+v, want name=%q domain=%q target=%q", i, entry, w.name, w.domain, w.target)
+		}
+	}
+}
+
+func TestParseGetBatchUpdateExpectedVersion(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int64
+		wantErr bool
+	}{
+		{query: "name1=a&target1=ta&expected_version1=3", want: 3},
+		{query: "name1=a&target1=ta&expected_version1=", want: 0},
+		{query: "name1=a&target1=ta", want: 0},
+		{query: "name1=a&target1=ta&expected_version1=abc", wantErr: true},
+		{query: "name1=a&target1=ta&expected_version1=1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/api/batch-update?"+tt.query, nil)
+		req, err := (&Server{}).parseGetBatchUpdate(r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGetBatchUpdate(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (len(req.Entries) != 1 || req.Entries[0].ExpectedVersion != tt.want) {
+			t.Errorf("parseGetBatchUpdate(%q) = %+v, want expected version %d", tt.query, req.Entries, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// 可信代理设置客户端地址的请求头
+const (
+	clientIPHeaderXForwardedFor = "x-forwarded-for"
+	clientIPHeaderForwarded     = "forwarded"
+	clientIPHeaderXRealIP       = "x-real-ip"
+)
+
+// parseClientIPHeader 解析 client_ip_header 配置，默认使用 X-Forwarded-For
+func parseClientIPHeader(value string) string {
+	header := strings.ToLower(strings.TrimSpace(value))
+	switch header {
+	case "":
+		return clientIPHeaderXForwardedFor
+	case clientIPHeaderXForwardedFor, clientIPHeaderForwarded, clientIPHeaderXRealIP:
+		return header
+	}
+	log.Printf("Ignoring invalid client_ip_header %q, using X-Forwarded-For", value)
+	return clientIPHeaderXForwardedFor
+}
+
+// parseTrustedProxies 解析 trusted_proxies 配置，支持 CIDR 和单个 IP
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				log.Printf("Ignoring invalid trusted proxy %q: %v", entry, err)
+				continue
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted proxy %q: %v", entry, err)
+			continue
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes
+}
+
+// isTrustedProxy 检查地址是否属于可信代理
+func (s *Server) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 返回请求的真实客户端 IP
+// 只有当直连地址是可信代理时才会解析 client_ip_header 指定的请求头，其它转发头即使存在也忽略
+// （代理通常原样转发客户端自己伪造的头）；代理链从右向左跳过可信代理，返回第一个不可信的地址
+func (s *Server) ClientIP(r *http.Request) string {
+	remote, ok := parseHostAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+
+	if !s.isTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	if s.clientIPHeader == clientIPHeaderXRealIP {
+		if realIP, ok := parseHostAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ok {
+			return realIP.String()
+		}
+		return remote.String()
+	}
+
+	hops := forwardedHops(r.Header, s.clientIPHeader)
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHostAddr(hops[i])
+		if !ok {
+			// 无法解析的跳点不可信，停止在上一个有效地址
+			break
+		}
+		client = hop
+		if !s.isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// forwardedHops 按顺序返回代理链中的地址，source 为 forwarded 时读取 RFC 7239 Forwarded 头，否则读取 X-Forwarded-For
+func forwardedHops(header http.Header, source string) []string {
+	var hops []string
+
+	if source != clientIPHeaderForwarded {
+		for _, value := range header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found || !strings.EqualFold(key, "for") {
+					continue
+				}
+				hops = append(hops, strings.Trim(strings.TrimSpace(val), `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// parseHostAddr 解析 "ip"、"ip:port"、"[ipv6]" 或 "[ipv6]:port" 形式的地址
+func parseHostAddr(value string) (netip.Addr, bool) {
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if addr, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		return addr.Unmap(), true
+	}
+
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
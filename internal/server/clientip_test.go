@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"})
+
+	tests := []struct {
+		name    string
+		source  string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:   "untrusted peer ignores headers",
+			remote: "203.0.113.9:1234",
+			headers: map[string]string{
+				"X-Forwarded-For": "1.2.3.4",
+				"Forwarded":       "for=1.2.3.4",
+				"X-Real-IP":       "1.2.3.4",
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name:    "trusted peer without header",
+			remote:  "127.0.0.1:1234",
+			want:    "127.0.0.1",
+			headers: map[string]string{},
+		},
+		{
+			name:    "x-forwarded-for skips trusted hops from the right",
+			remote:  "127.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7, 10.0.0.2"},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "spoofed Forwarded is ignored when the proxy sets X-Forwarded-For",
+			remote: "127.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       "for=1.2.3.4",
+				"X-Forwarded-For": "198.51.100.7",
+			},
+			want: "198.51.100.7",
+		},
+		{
+			name:    "spoofed Forwarded without X-Forwarded-For falls back to the peer",
+			remote:  "127.0.0.1:1234",
+			headers: map[string]string{"Forwarded": "for=1.2.3.4"},
+			want:    "127.0.0.1",
+		},
+		{
+			name:   "forwarded source ignores X-Forwarded-For",
+			source: "forwarded",
+			remote: "127.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       `for=198.51.100.7;proto=https, for="[2001:db8::1]:4711"`,
+				"X-Forwarded-For": "1.2.3.4",
+			},
+			want: "2001:db8::1",
+		},
+		{
+			name:    "forwarded source without Forwarded falls back to the peer",
+			source:  "forwarded",
+			remote:  "127.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "127.0.0.1",
+		},
+		{
+			name:   "x-real-ip source ignores the other headers",
+			source: "X-Real-IP",
+			remote: "10.1.2.3:1234",
+			headers: map[string]string{
+				"X-Real-IP":       "198.51.100.7",
+				"X-Forwarded-For": "1.2.3.4",
+			},
+			want: "198.51.100.7",
+		},
+		{
+			name:    "unparsable hop stops the walk",
+			remote:  "127.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, garbage, 10.0.0.2"},
+			want:    "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: trusted, clientIPHeader: parseClientIPHeader(tt.source)}
+			r, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			r.RemoteAddr = tt.remote
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := s.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
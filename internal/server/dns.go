@@ -0,0 +1,160 @@
+package server
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/dnsserver"
+)
+
+// defaultDNSListen 未配置 dns.listen 时的监听地址
+const defaultDNSListen = ":53"
+
+// dnsRecords 一个名称下的静态记录
+type dnsRecords struct {
+	a     []netip.Addr
+	aaaa  []netip.Addr
+	txt   []string
+	cname string
+}
+
+// setupDNS 解析公网地址和静态记录并创建 DNS 服务；域名列表在每次查询时读取，通过 API 增删域名立即生效
+func (s *Server) setupDNS(dnsConfig *config.DNSConfig) error {
+	if len(dnsConfig.PublicIPs) == 0 {
+		return fmt.Errorf("dns.public_ips is required when the DNS server is enabled")
+	}
+	for _, value := range dnsConfig.PublicIPs {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return fmt.Errorf("invalid dns.public_ips entry %q: %v", value, err)
+		}
+		if addr = addr.Unmap(); addr.Is4() {
+			s.dnsPublicV4 = append(s.dnsPublicV4, addr)
+		} else {
+			s.dnsPublicV6 = append(s.dnsPublicV6, addr)
+		}
+	}
+
+	s.dnsRecords = make(map[string]*dnsRecords)
+	for _, record := range dnsConfig.Records {
+		name := dnsName(record.Name)
+		if name == "" {
+			return fmt.Errorf("dns record name is required")
+		}
+		records := s.dnsRecords[name]
+		if records == nil {
+			records = &dnsRecords{}
+			s.dnsRecords[name] = records
+		}
+
+		switch strings.ToUpper(record.Type) {
+		case "A", "AAAA":
+			addr, err := netip.ParseAddr(record.Value)
+			if err != nil {
+				return fmt.Errorf("invalid %s record for %s: %v", record.Type, name, err)
+			}
+			addr = addr.Unmap()
+			if addr.Is4() != strings.EqualFold(record.Type, "A") {
+				return fmt.Errorf("invalid %s record for %s: %s", record.Type, name, record.Value)
+			}
+			if addr.Is4() {
+				records.a = append(records.a, addr)
+			} else {
+				records.aaaa = append(records.aaaa, addr)
+			}
+		case "TXT":
+			records.txt = append(records.txt, record.Value)
+		case "CNAME":
+			if record.Value == "" || records.cname != "" {
+				return fmt.Errorf("%s must have exactly one non-empty CNAME record", name)
+			}
+			records.cname = dnsName(record.Value)
+		default:
+			return fmt.Errorf("unsupported dns record type %q for %s, use A, AAAA, TXT or CNAME", record.Type, name)
+		}
+
+		// CNAME 不能与其它记录共存
+		if records.cname != "" && (len(records.a) > 0 || len(records.aaaa) > 0 || len(records.txt) > 0) {
+			return fmt.Errorf("%s cannot have a CNAME record together with other records", name)
+		}
+	}
+
+	addr := dnsConfig.Listen
+	if addr == "" {
+		addr = defaultDNSListen
+	}
+	ttl := dnsConfig.TTL
+	if ttl <= 0 {
+		ttl = dnsserver.DefaultTTL
+	}
+	s.dnsServer = &dnsserver.Server{
+		Addr:       addr,
+		TTL:        uint32(ttl),
+		Nameserver: dnsConfig.Nameserver,
+		Lookup:     s.lookupDNS,
+	}
+	return nil
+}
+
+// lookupDNS 返回名称的记录：域名映射指向本服务的公网地址，静态记录覆盖同类型的默认值
+// 名称不存在但属于某个已知域名时返回 NXDOMAIN，与本服务无关的名称返回 REFUSED
+func (s *Server) lookupDNS(name string) dnsserver.Result {
+	records := s.dnsRecords[name]
+	isDomain := s.isMappedDomain(name)
+	if !isDomain && records == nil {
+		return dnsserver.Result{Zone: s.dnsZone(name)}
+	}
+
+	result := dnsserver.Result{Zone: s.dnsZone(name), Exists: true}
+	if isDomain {
+		result.A, result.AAAA = s.dnsPublicV4, s.dnsPublicV6
+	}
+	if records != nil {
+		if len(records.a) > 0 {
+			result.A = records.a
+		}
+		if len(records.aaaa) > 0 {
+			result.AAAA = records.aaaa
+		}
+		result.TXT = records.txt
+		if records.cname != "" {
+			result.CNAME, result.A, result.AAAA = records.cname, nil, nil
+		}
+	}
+	return result
+}
+
+// dnsZone 名称所属的区域：自身及上级中最上层的域名映射或静态记录名称，都不是时返回空字符串
+func (s *Server) dnsZone(name string) string {
+	zone := ""
+	for n := name; n != ""; n = parentDomain(n) {
+		if s.isMappedDomain(n) || s.dnsRecords[n] != nil {
+			zone = n
+		}
+	}
+	return zone
+}
+
+// isMappedDomain 名称是否为已生效的域名映射，等待所有权验证的域名不会被解析
+func (s *Server) isMappedDomain(name string) bool {
+	if s.configStorage == nil {
+		return false
+	}
+	entry, err := s.configStorage.GetDomain(name)
+	return err == nil && (entry.Verification == nil || entry.Verification.Status == config.VerificationVerified)
+}
+
+// parentDomain 去掉第一个标签，没有上级时返回空字符串
+func parentDomain(name string) string {
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		return parent
+	}
+	return ""
+}
+
+// dnsName 规范化记录名称：小写并去掉末尾的点
+func dnsName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
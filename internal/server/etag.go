@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+// expectedVersion 写请求携带的版本前提条件
+type expectedVersion struct {
+	version int64 // 0 表示不检查，config.AnyVersion 表示只要求条目存在
+	ifMatch bool  // 来自 If-Match 请求头（冲突返回 412），否则来自 expected_version 参数（冲突返回 409）
+}
+
+// conflictStatus 版本冲突时返回的状态码
+func (e expectedVersion) conflictStatus() int {
+	if e.ifMatch {
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusConflict
+}
+
+// formatETag 将条目版本格式化为强 ETag
+func formatETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseETag 解析单个 ETag，接受 "3" 和 W/"3"
+func parseETag(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "W/")
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ETag %q", value)
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid ETag %q", value)
+	}
+	return version, nil
+}
+
+// parseExpectedVersion 读取 If-Match 请求头或 expected_version 参数，两者都存在时以 If-Match 为准
+func parseExpectedVersion(r *http.Request) (expectedVersion, error) {
+	if ifMatch := strings.TrimSpace(r.Header.Get("If-Match")); ifMatch != "" {
+		if ifMatch == "*" {
+			return expectedVersion{version: config.AnyVersion, ifMatch: true}, nil
+		}
+		// 每个条目只有一个当前版本，多个 ETag 的列表没有意义
+		if strings.Contains(ifMatch, ",") {
+			return expectedVersion{}, errors.New("If-Match must contain a single ETag")
+		}
+		version, err := parseETag(ifMatch)
+		if err != nil {
+			return expectedVersion{}, err
+		}
+		return expectedVersion{version: version, ifMatch: true}, nil
+	}
+
+	if value := r.URL.Query().Get("expected_version"); value != "" {
+		version, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || version <= 0 {
+			return expectedVersion{}, fmt.Errorf("invalid expected_version %q", value)
+		}
+		return expectedVersion{version: version}, nil
+	}
+
+	return expectedVersion{}, nil
+}
+
+// writeStatus 写操作失败时的状态码：版本冲突按前提条件来源返回 409/412，无权修改返回 403，其它错误返回 400
+func (e expectedVersion) writeStatus(err error) int {
+	if errors.Is(err, config.ErrVersionConflict) {
+		return e.conflictStatus()
+	}
+	if errors.Is(err, config.ErrPermissionDenied) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// notModified 检查 If-None-Match，命中时写入 304 并返回 true
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		if v, err := parseETag(tag); err == nil && v == version {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetForwarding 返回单个路径跳转条目，ETag 为当前版本
+func (s *Server) handleGetForwarding(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	// 普通用户只能读取自己的条目
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Missing required parameter: name",
+		})
+		return
+	}
+
+	forwarding, err := s.storage.GetForwarding(name)
+	if err == nil && !actor.CanAccess(forwarding.Owner) {
+		// 不暴露其他用户的条目
+		err = errors.New("forwarding name not found")
+	}
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(forwarding.Version))
+	if notModified(w, r, forwarding.Version) {
+		return
+	}
+	s.fillRelayStatus(forwarding)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":      "success",
+		"forwarding": forwarding,
+	})
+}
+
+// handleGetDomain 返回单个域名映射，ETag 为当前版本
+func (s *Server) handleGetDomain(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	// 普通用户只能读取自己的条目
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	if domain == "" {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Missing required parameter: domain",
+		})
+		return
+	}
+
+	if s.domainStorage == nil {
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: "Domain storage not available",
+		})
+		return
+	}
+
+	domainEntry, err := s.domainStorage.GetDomain(domain)
+	if err == nil && !actor.CanAccess(domainEntry.Owner) {
+		err = errors.New("domain not found")
+	}
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(domainEntry.Version))
+	if notModified(w, r, domainEntry.Version) {
+		return
+	}
+
+	// 隐藏敏感token
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state": "success",
+		"domain": &models.DomainEntryPublic{
+			Domain:         domainEntry.Domain,
+			Target:         domainEntry.Target,
+			Version:        domainEntry.Version,
+			Owner:          domainEntry.Owner,
+			HasUpdateToken: domainEntry.HasUpdateToken,
+			CreatedAt:      domainEntry.CreatedAt,
+			UpdatedAt:      domainEntry.UpdatedAt,
+			Verification:   domainEntry.Verification,
+			Wake:           domainEntry.Wake,
+			Routes:         domainEntry.Routes,
+			PathRules:      domainEntry.PathRules,
+		},
+	})
+}
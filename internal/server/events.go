@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"redirect_helper/internal/models"
+)
+
+const (
+	// healthCheckInterval 健康状态检查间隔，状态变化时推送 health 事件
+	healthCheckInterval = 10 * time.Second
+	// eventsKeepAlive SSE 心跳间隔，避免代理因空闲断开连接
+	eventsKeepAlive = 15 * time.Second
+	// eventsRetry 建议客户端断线后的重连等待时间（毫秒）
+	eventsRetry = 3000
+)
+
+// 健康状态
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+// healthEvent health 事件的内容
+type healthEvent struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// currentHealth 返回当前的健康状态
+func (s *Server) currentHealth() healthEvent {
+	if err := s.checkReadiness(); err != nil {
+		return healthEvent{Status: healthUnavailable, Message: err.Error()}
+	}
+	return healthEvent{Status: healthOK}
+}
+
+// startHealthMonitor 定期检查健康状态，变化时推送 health 事件
+func (s *Server) startHealthMonitor() {
+	stop := make(chan struct{})
+	s.healthStop = stop
+	s.healthStatus = s.currentHealth().Status
+
+	go func() {
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			health := s.currentHealth()
+			if health.Status != s.healthStatus {
+				s.healthStatus = health.Status
+				s.events.Publish("health", health)
+			}
+		}
+	}()
+}
+
+// stopEvents 推送关闭状态并结束所有事件流，否则 http.Server.Shutdown 会一直等待这些长连接
+func (s *Server) stopEvents() {
+	if s.events == nil {
+		return
+	}
+
+	if s.healthStop != nil {
+		close(s.healthStop)
+		s.healthStop = nil
+	}
+	s.events.Publish("health", s.currentHealth())
+	s.events.Close()
+}
+
+// handleEvents 以 Server-Sent Events 推送条目变更和健康状态
+// 断线重连时根据 Last-Event-ID 从环形缓冲区补发错过的事件；无法补全时先发送 resync 事件
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	// EventSource 无法设置请求头，浏览器通过 admin_token 参数认证
+	if authErr := s.adminAuth(r, false); authErr != nil {
+		s.logAPIRequest(r, "/api/v2/events", nil, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	if s.events == nil {
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: "Event stream not available",
+		})
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: "Invalid Last-Event-ID",
+			})
+			return
+		}
+		lastID = id
+	}
+
+	// 事件流是长连接，取消服务器的写超时
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	sub, backlog, complete := s.events.Subscribe(lastID)
+	defer sub.Close()
+
+	s.logAPIRequest(r, "/api/v2/events", map[string]string{
+		"last_event_id": lastEventID,
+		"resumed":       strconv.Itoa(len(backlog)),
+	}, "stream_opened", http.StatusOK)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry)
+	if !complete {
+		// 错过的事件已不在缓冲区中，客户端需要重新拉取 /api/list 和 /api/list-domains
+		writeSSE(w, 0, "resync", map[string]string{"last_event_id": lastEventID})
+	}
+	// 连接时先发送当前健康状态，不带 ID，不影响续传位置
+	writeSSE(w, 0, "health", s.currentHealth())
+	for _, event := range backlog {
+		writeSSE(w, event.ID, event.Type, event.Data)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// 服务关闭或客户端过慢，客户端会自动重连并续传
+				return
+			}
+			writeSSE(w, event.ID, event.Type, event.Data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE 写入一条 SSE 消息，id 为 0 时不设置事件 ID
+func writeSSE(w http.ResponseWriter, id uint64, eventType string, data interface{}) {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return
+		}
+	}
+
+	if id != 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, raw)
+}
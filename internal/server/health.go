@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"redirect_helper/internal/models"
+)
+
+// handleHealthz 存活探针：进程能响应请求即为存活
+// 不做域名跳转检查，探针可能使用任意 Host 头
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State: "ok",
+	})
+}
+
+// handleReadyz 就绪探针：检查存储是否可读写，关闭过程中返回 503
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	if err := s.checkReadiness(); err != nil {
+		s.writeJSONResponse(w, http.StatusServiceUnavailable, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State: "ok",
+	})
+}
+
+// checkReadiness 检查服务是否可以接收流量：未处于关闭过程中且存储可读写
+func (s *Server) checkReadiness() error {
+	if s.shuttingDown.Load() {
+		return errors.New("Server is shutting down")
+	}
+	if s.configStorage == nil {
+		return errors.New("Storage not available")
+	}
+	return s.configStorage.CheckHealth()
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 服务器超时默认值，配置为 0 时使用
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultShutdownTimeout   = 30 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+)
+
+// listen 创建监听器，并按配置包装 PROXY protocol 解析
+// addr 以 "unix:" 开头时监听 Unix socket，例如 "unix:/run/redirect_helper/admin.sock"
+func (s *Server) listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// 清理上次异常退出残留的 socket 文件
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0660); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.proxyProtocol {
+		listener = &proxyProtoListener{Listener: listener, trusted: s.isTrustedProxy}
+	}
+
+	return listener, nil
+}
+
+// newHTTPServer 按配置的超时和请求头大小创建 http.Server，并登记以便优雅关闭
+func (s *Server) newHTTPServer(handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		WriteTimeout:      defaultWriteTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+		MaxHeaderBytes:    defaultMaxHeaderBytes,
+	}
+
+	if s.configStorage != nil {
+		if serverConfig := s.configStorage.GetServerConfig(); serverConfig != nil {
+			if serverConfig.ReadHeaderTimeout > 0 {
+				srv.ReadHeaderTimeout = time.Duration(serverConfig.ReadHeaderTimeout) * time.Second
+			}
+			if serverConfig.ReadTimeout > 0 {
+				srv.ReadTimeout = time.Duration(serverConfig.ReadTimeout) * time.Second
+			}
+			if serverConfig.WriteTimeout > 0 {
+				srv.WriteTimeout = time.Duration(serverConfig.WriteTimeout) * time.Second
+			}
+			if serverConfig.IdleTimeout > 0 {
+				srv.IdleTimeout = time.Duration(serverConfig.IdleTimeout) * time.Second
+			}
+			if serverConfig.MaxHeaderBytes > 0 {
+				srv.MaxHeaderBytes = serverConfig.MaxHeaderBytes
+			}
+		}
+	}
+
+	s.serversMu.Lock()
+	s.httpServers = append(s.httpServers, srv)
+	s.serversMu.Unlock()
+
+	return srv
+}
+
+// ShutdownTimeout 返回优雅关闭时等待连接排空的最长时间
+func (s *Server) ShutdownTimeout() time.Duration {
+	if s.configStorage != nil {
+		if serverConfig := s.configStorage.GetServerConfig(); serverConfig != nil && serverConfig.ShutdownTimeout > 0 {
+			return time.Duration(serverConfig.ShutdownTimeout) * time.Second
+		}
+	}
+	return defaultShutdownTimeout
+}
+
+// Shutdown 停止接受新连接，等待进行中的请求完成，然后落盘配置和 webhook 队列
+// 关闭期间 /readyz 返回 503，便于编排器摘除流量
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+	s.stopEvents()
+	s.stopVerifier()
+	s.stopRelays()
+	s.stopGeoIP()
+
+	s.serversMu.Lock()
+	servers := append([]*http.Server(nil), s.httpServers...)
+	s.serversMu.Unlock()
+
+	var errs []error
+	if s.sniProxy != nil {
+		if err := s.sniProxy.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.dnsServer != nil {
+		if err := s.dnsServer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.configStorage != nil {
+		if err := s.configStorage.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// 未投递完的 webhook 保存到队列文件，下次启动后继续
+	if s.webhooks != nil {
+		if err := s.webhooks.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenUse 请求使用的 token：type 为 admin/redirect/domain/api_key/update_token，
+// slot 对全局 token 为 current 或 previous，对 API key 为用户名
+type tokenUse struct {
+	kind string
+	slot string
+}
+
+func (u tokenUse) String() string {
+	if u.slot == "" {
+		return u.kind
+	}
+	return u.kind + "/" + u.slot
+}
+
+// tokenUsage 按 token 类型统计 API 请求数，用于确认轮换后还有哪些设备在使用旧 token
+type tokenUsage struct {
+	mu     sync.Mutex
+	counts map[tokenUse]int64
+}
+
+func (t *tokenUsage) record(use tokenUse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts == nil {
+		t.counts = make(map[tokenUse]int64)
+	}
+	t.counts[use]++
+}
+
+// snapshot 返回按 "type/slot" 排序的计数
+func (t *tokenUsage) snapshot() []tokenCount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := make([]tokenCount, 0, len(t.counts))
+	for use, count := range t.counts {
+		counts = append(counts, tokenCount{use, count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].use.String() < counts[j].use.String()
+	})
+	return counts
+}
+
+type tokenCount struct {
+	use   tokenUse
+	count int64
+}
+
+// identifyRequestToken 找出请求用于认证的 token 并计数：依次检查日志参数中的 token、Bearer/admin_token、
+// api_key 参数和 HTTP Basic 密码，返回第一个能识别的
+func (s *Server) identifyRequestToken(r *http.Request, params map[string]string) (tokenUse, bool) {
+	if s.configStorage == nil {
+		return tokenUse{}, false
+	}
+
+	candidates := make([]string, 0, 4)
+	for k, v := range params {
+		if strings.Contains(strings.ToLower(k), "token") && v != "" {
+			candidates = append(candidates, v)
+		}
+	}
+	candidates = append(candidates, requestAdminToken(r), r.URL.Query().Get("api_key"))
+	if _, password, ok := r.BasicAuth(); ok {
+		candidates = append(candidates, password)
+	}
+
+	for _, token := range candidates {
+		if kind, slot := s.configStorage.IdentifyToken(token); kind != "" {
+			use := tokenUse{kind: kind, slot: slot}
+			s.tokenUsage.record(use)
+			return use, true
+		}
+	}
+	return tokenUse{}, false
+}
+
+// maskToken 日志中只显示 token 的前 8 个字符，过短的 token 完全隐藏
+func maskToken(token string) string {
+	if len(token) <= 8 {
+		return "***"
+	}
+	return token[:8] + "..."
+}
+
+// handleMetrics 以 Prometheus 文本格式输出运行指标（GET /metrics，需要管理员权限）
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP redirect_helper_uptime_seconds Seconds since the server started.\n")
+	fmt.Fprintf(&b, "# TYPE redirect_helper_uptime_seconds gauge\n")
+	fmt.Fprintf(&b, "redirect_helper_uptime_seconds %d\n", int64(time.Since(s.startedAt).Seconds()))
+
+	if forwardings, err := s.configStorage.ListForwardings(); err == nil {
+		fmt.Fprintf(&b, "# HELP redirect_helper_forwardings Number of path forwardings.\n")
+		fmt.Fprintf(&b, "# TYPE redirect_helper_forwardings gauge\n")
+		fmt.Fprintf(&b, "redirect_helper_forwardings %d\n", len(forwardings))
+	}
+	if domains, err := s.configStorage.ListDomains(); err == nil {
+		fmt.Fprintf(&b, "# HELP redirect_helper_domains Number of domain mappings.\n")
+		fmt.Fprintf(&b, "# TYPE redirect_helper_domains gauge\n")
+		fmt.Fprintf(&b, "redirect_helper_domains %d\n", len(domains))
+	}
+
+	if relays := s.relayStats(); len(relays) > 0 {
+		fmt.Fprintf(&b, "# HELP redirect_helper_relay_active_connections Open TCP connections and UDP sessions per relay port.\n")
+		fmt.Fprintf(&b, "# TYPE redirect_helper_relay_active_connections gauge\n")
+		for _, r := range relays {
+			fmt.Fprintf(&b, "redirect_helper_relay_active_connections{name=%q,protocol=\"tcp\"} %d\n", r.name, r.stats.ActiveConnections)
+			fmt.Fprintf(&b, "redirect_helper_relay_active_connections{name=%q,protocol=\"udp\"} %d\n", r.name, r.stats.ActiveSessions)
+		}
+		fmt.Fprintf(&b, "# HELP redirect_helper_relay_rejected_total Relay connections rejected by the connection limit or an unusable target.\n")
+		fmt.Fprintf(&b, "# TYPE redirect_helper_relay_rejected_total counter\n")
+		for _, r := range relays {
+			fmt.Fprintf(&b, "redirect_helper_relay_rejected_total{name=%q} %d\n", r.name, r.stats.Rejected)
+		}
+	}
+
+	if s.sniProxy != nil {
+		stats := s.sniProxy.Stats()
+		fmt.Fprintf(&b, "# HELP redirect_helper_sni_active_connections Open connections on the SNI passthrough listener.\n")
+		fmt.Fprintf(&b, "# TYPE redirect_helper_sni_active_connections gauge\n")
+		fmt.Fprintf(&b, "redirect_helper_sni_active_connections %d\n", stats.ActiveConnections)
+		fmt.Fprintf(&b, "# HELP redirect_helper_sni_rejected_total SNI connections rejected by the connection limit, a bad ClientHello or an unreachable backend.\n")
+		fmt.Fprintf(&b, "# TYPE redirect_helper_sni_rejected_total counter\n")
+		fmt.Fprintf(&b, "redirect_helper_sni_rejected_total %d\n", stats.Rejected)
+	}
+
+	fmt.Fprintf(&b, "# HELP redirect_helper_token_requests_total API requests by the token they authenticated with.\n")
+	fmt.Fprintf(&b, "# TYPE redirect_helper_token_requests_total counter\n")
+	for _, c := range s.tokenUsage.snapshot() {
+		fmt.Fprintf(&b, "redirect_helper_token_requests_total{type=%q,slot=%q} %d\n", c.use.kind, c.use.slot, c.count)
+	}
+
+	fmt.Fprintf(&b, "# HELP redirect_helper_retired_token_expiry_timestamp_seconds When the previous token of each type stops being accepted.\n")
+	fmt.Fprintf(&b, "# TYPE redirect_helper_retired_token_expiry_timestamp_seconds gauge\n")
+	for _, retired := range s.configStorage.RetiredTokens() {
+		fmt.Fprintf(&b, "redirect_helper_retired_token_expiry_timestamp_seconds{type=%q} %d\n", retired.Kind, retired.ExpiresAt.Unix())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+// listOwner 列表接口的 owner 过滤条件：普通用户总是只看到自己的条目，指定其他用户时返回 false
+// 管理员不指定 owner 时返回全部条目
+func listOwner(r *http.Request, actor config.Actor) (string, bool) {
+	owner := r.URL.Query().Get("owner")
+	if actor.Admin {
+		return owner, true
+	}
+	if owner != "" && owner != actor.User {
+		return "", false
+	}
+	return actor.User, true
+}
+
+// filterForwardings 只保留属于 owner 的条目，owner 为空时不过滤
+func filterForwardings(forwardings []*models.ForwardingEntry, owner string) []*models.ForwardingEntry {
+	if owner == "" {
+		return forwardings
+	}
+	result := make([]*models.ForwardingEntry, 0, len(forwardings))
+	for _, forwarding := range forwardings {
+		if forwarding.Owner == owner {
+			result = append(result, forwarding)
+		}
+	}
+	return result
+}
+
+// filterDomains 只保留属于 owner 的域名映射，owner 为空时不过滤
+func filterDomains(domains []*models.DomainEntry, owner string) []*models.DomainEntry {
+	if owner == "" {
+		return domains
+	}
+	result := make([]*models.DomainEntry, 0, len(domains))
+	for _, domain := range domains {
+		if domain.Owner == owner {
+			result = append(result, domain)
+		}
+	}
+	return result
+}
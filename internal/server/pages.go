@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"redirect_helper/internal/config"
+)
+
+// pageFiles 面向访问者的 HTML 页面（预览页、唤醒等待页），编译进二进制文件
+//
+//go:embed pages
+var pageFiles embed.FS
+
+// builtinPages 内置页面模板，按文件名（不含 .html）索引
+var builtinPages = template.Must(template.ParseFS(pageFiles, "pages/*.html"))
+
+// pageTemplateDir 自定义页面模板所在目录：配置文件目录下的 templates
+func pageTemplateDir() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), "templates")
+}
+
+// loadPageTemplates 读取配置目录中覆盖内置页面的模板，例如 templates/preview.html
+// 模板无法解析时记录日志并继续使用内置模板，修改后重启生效
+func loadPageTemplates(dir string) map[string]*template.Template {
+	pages := make(map[string]*template.Template)
+	for _, builtin := range builtinPages.Templates() {
+		name := builtin.Name()
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Failed to read page template %s: %v", path, err)
+			}
+			continue
+		}
+
+		page, err := template.New(name).Parse(string(content))
+		if err != nil {
+			log.Printf("Invalid page template %s, using built-in page: %v", path, err)
+			continue
+		}
+		pages[name] = page
+		log.Printf("Using custom page template %s", path)
+	}
+	return pages
+}
+
+// renderPage 渲染页面，优先使用配置目录中的自定义模板；页面内容因请求而异，不允许缓存
+func (s *Server) renderPage(w http.ResponseWriter, status int, name string, data interface{}) {
+	page := s.pages[name+".html"]
+	if page == nil {
+		page = builtinPages.Lookup(name + ".html")
+	}
+
+	// 先渲染到缓冲区，自定义模板出错时返回 500 而不是半个页面
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, data); err != nil {
+		log.Printf("Failed to render page %s: %v", name, err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
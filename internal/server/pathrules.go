@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/routing"
+)
+
+// maxCachedPathRules 缓存的域名数量上限，超过时清空重建（域名被删除后留下的缓存也随之清理）
+const maxCachedPathRules = 1024
+
+// pathRuleCache 按域名缓存编译后的路径规则，规则内容变化后重新编译
+// 不按版本号判断：删除后重新创建的域名会再次用到相同的版本号
+type pathRuleCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedPathRules
+}
+
+type cachedPathRules struct {
+	source   []models.PathRule
+	compiled *routing.PathRules
+}
+
+// get 返回域名映射当前规则的编译结果
+func (c *pathRuleCache) get(entry *models.DomainEntry) (*routing.PathRules, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.entries[entry.Domain]; ok && slices.Equal(cached.source, entry.PathRules) {
+		return cached.compiled, nil
+	}
+	rules, err := routing.CompilePathRules(entry.PathRules)
+	if err != nil {
+		return nil, err
+	}
+	if c.entries == nil || len(c.entries) >= maxCachedPathRules {
+		c.entries = make(map[string]cachedPathRules)
+	}
+	c.entries[entry.Domain] = cachedPathRules{source: slices.Clone(entry.PathRules), compiled: rules}
+	return rules, nil
+}
+
+// redirectDomainPath 按域名映射的路径规则跳转，返回 true 表示已经写入响应
+func (s *Server) redirectDomainPath(w http.ResponseWriter, r *http.Request) bool {
+	// 唤醒等待页面轮询的地址不受规则影响
+	if r.URL.Path == wakeStatusPath {
+		return false
+	}
+	entry, err := s.domainStorage.GetDomain(requestHost(r))
+	if err != nil || len(entry.PathRules) == 0 {
+		return false
+	}
+	rules, err := s.pathRules.get(entry)
+	if err != nil {
+		// 保存时已经校验过，这里只可能是手工修改了配置文件
+		log.Printf("[PathRules] %s: %v", entry.Domain, err)
+		return false
+	}
+
+	result, ok := rules.Match(r.URL.EscapedPath(), r.URL.RawQuery)
+	if !ok {
+		return false
+	}
+	http.Redirect(w, r, result.Target, result.Status)
+	return true
+}
+
+// handlePathRules 替换（PUT）或清空（DELETE）域名映射的路径规则，域名所属用户和管理员可以操作
+// PUT /api/path-rules?domain=www.example.com，请求体为规则数组，例如 [{"type":"prefix","pattern":"/docs/","target":"https://docs.example.com/$1"}]
+func (s *Server) handlePathRules(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	params := map[string]string{"domain": domain}
+
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		s.logAPIRequest(r, "/api/path-rules", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/path-rules", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	var rules []config.PathRule
+	var err error
+	if domain == "" {
+		err = errors.New("Missing required parameter: domain")
+	} else if r.Method == http.MethodPut {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRoutesBody))
+		decoder.DisallowUnknownFields()
+		if decodeErr := decoder.Decode(&rules); decodeErr != nil {
+			err = errors.New("Invalid JSON: " + decodeErr.Error())
+		}
+	}
+	if err == nil {
+		// 当前请求的 Host 视为本服务地址，规则目标不能指回自己
+		err = s.configStorage.SetDomainPathRules(&actor, domain, rules, r.Host)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/path-rules", params, "error:"+err.Error(), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var version int64
+	if entry, err := s.configStorage.GetDomain(domain); err == nil {
+		version = entry.Version
+	}
+
+	s.logAPIRequest(r, "/api/path-rules", params, "success", http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:   "success",
+		Version: version,
+	})
+}
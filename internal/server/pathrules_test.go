@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"redirect_helper/internal/models"
+)
+
+// 删除后重新创建的域名会回到相同的版本号，缓存不能沿用旧规则
+func TestPathRuleCacheRecreatedDomain(t *testing.T) {
+	var cache pathRuleCache
+
+	old := &models.DomainEntry{Domain: "old.example.com", Version: 2, PathRules: []models.PathRule{
+		{Type: "regex", Pattern: "^/x/(\\d+)$", Target: "https://old.example.net/$1", Status: 302},
+	}}
+	recreated := &models.DomainEntry{Domain: "old.example.com", Version: 2, PathRules: []models.PathRule{
+		{Type: "regex", Pattern: "^/x/(\\d+)$", Target: "https://new.example.net/$1", Status: 302},
+	}}
+
+	for _, tt := range []struct {
+		entry *models.DomainEntry
+		want  string
+	}{
+		{old, "https://old.example.net/1"},
+		{old, "https://old.example.net/1"},
+		{recreated, "https://new.example.net/1"},
+	} {
+		rules, err := cache.get(tt.entry)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		result, ok := rules.Match("/x/1", "")
+		if !ok || result.Target != tt.want {
+			t.Errorf("Match(/x/1) = %q, %v, want %q", result.Target, ok, tt.want)
+		}
+	}
+}
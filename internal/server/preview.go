@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+// previewForwarding 条目设置了预览或使用 /go/name+ 访问时显示预览页面而不是直接跳转
+func (s *Server) previewForwarding(w http.ResponseWriter, name, target string, forced bool) bool {
+	var preview *models.Preview
+	if entry, err := s.storage.GetForwarding(name); err == nil {
+		preview = entry.Preview
+	}
+	if preview == nil && !forced {
+		return false
+	}
+
+	data := map[string]interface{}{
+		"Name":   name,
+		"Target": target,
+	}
+	if preview != nil {
+		data["Message"] = preview.Message
+		data["Countdown"] = preview.Countdown
+	}
+	s.renderPage(w, http.StatusOK, "preview", data)
+	return true
+}
+
+// handlePreview 设置（POST）或取消（DELETE）路径条目的预览页面，条目所属用户和管理员可以操作
+// POST /api/preview?name=docs&message=External%20site&countdown=5
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	name := query.Get("name")
+	params := map[string]string{
+		"name":      name,
+		"message":   query.Get("message"),
+		"countdown": query.Get("countdown"),
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		s.logAPIRequest(r, "/api/preview", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/preview", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	var err error
+	if name == "" {
+		err = errors.New("Missing required parameter: name")
+	}
+	var preview *config.PreviewConfig
+	if err == nil && r.Method == http.MethodPost {
+		preview = &config.PreviewConfig{Message: query.Get("message")}
+		if value := query.Get("countdown"); value != "" {
+			if preview.Countdown, err = strconv.Atoi(value); err != nil {
+				err = errors.New("invalid countdown, expected seconds")
+			}
+		}
+	}
+	if err == nil {
+		err = s.configStorage.SetForwardingPreview(&actor, name, preview)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/preview", params, "error:"+err.Error(), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var version int64
+	if entry, err := s.configStorage.GetForwarding(name); err == nil {
+		version = entry.Version
+	}
+
+	s.logAPIRequest(r, "/api/preview", params, "success", http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:   "success",
+		Version: version,
+	})
+}
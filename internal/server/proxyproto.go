@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolV2Signature PROXY protocol v2 的固定 12 字节前缀
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyHeaderTimeout 读取 PROXY 头的超时时间
+const proxyHeaderTimeout = 5 * time.Second
+
+// proxyProtoListener 为 TCP 负载均衡器后的监听器解析 PROXY protocol v1/v2 头
+// 只有来自可信代理的连接才会解析头部，其它连接保持原样
+type proxyProtoListener struct {
+	net.Listener
+	trusted func(netip.Addr) bool
+}
+
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, ok := parseHostAddr(conn.RemoteAddr().String())
+	if !ok || !l.trusted(remote) {
+		return conn, nil
+	}
+
+	return &proxyProtoConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtoConn 在首次读取或获取地址时解析 PROXY 头，避免阻塞 Accept
+type proxyProtoConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	headerErr  error
+}
+
+func (c *proxyProtoConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		addr, err := readProxyHeader(c.reader)
+		if err != nil {
+			c.headerErr = err
+			return
+		}
+		c.remoteAddr = addr
+	})
+}
+
+func (c *proxyProtoConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.headerErr != nil {
+		return 0, c.headerErr
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader 读取 PROXY 头并返回其中的源地址
+// 没有 PROXY 头时返回 nil 地址，连接按普通连接处理
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	peek, err := reader.Peek(5)
+	if err != nil {
+		return nil, nil
+	}
+
+	if string(peek) == "PROXY" {
+		return readProxyHeaderV1(reader)
+	}
+
+	if peek[0] == proxyProtocolV2Signature[0] {
+		sig, err := reader.Peek(len(proxyProtocolV2Signature))
+		if err == nil && bytes.Equal(sig, proxyProtocolV2Signature) {
+			return readProxyHeaderV2(reader)
+		}
+	}
+
+	return nil, nil
+}
+
+// readProxyHeaderV1 解析文本格式: "PROXY TCP4 src dst sport dport\r\n"
+func readProxyHeaderV1(reader *bufio.Reader) (net.Addr, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY v1 header: %v", err)
+	}
+	if len(line) > 107 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("invalid PROXY v1 header")
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(line, "\r\n"))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY v1 header")
+	}
+
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY v1 source address: %v", err)
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY v1 source port: %v", err)
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// readProxyHeaderV2 解析二进制格式的 PROXY v2 头
+func readProxyHeaderV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("invalid PROXY v2 header: %v", err)
+	}
+
+	version := header[12] >> 4
+	command := header[12] & 0x0f
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	if version != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("invalid PROXY v2 payload: %v", err)
+	}
+
+	// LOCAL 命令（健康检查等）使用真实连接地址
+	if command == 0x0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 0x1: // AF_INET
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("short PROXY v2 IPv4 payload")
+		}
+		addr := netip.AddrFrom4([4]byte(payload[0:4]))
+		port := binary.BigEndian.Uint16(payload[8:10])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, port)), nil
+	case 0x2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("short PROXY v2 IPv6 payload")
+		}
+		addr := netip.AddrFrom16([16]byte(payload[0:16]))
+		port := binary.BigEndian.Uint16(payload[32:34])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, port)), nil
+	default:
+		// AF_UNSPEC / AF_UNIX 不携带可用的 IP 地址
+		return nil, nil
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// proxyV2Header 构造 PROXY v2 头，command 1 为 PROXY、0 为 LOCAL，family 1 为 IPv4、2 为 IPv6
+func proxyV2Header(command, family byte, payload []byte) []byte {
+	header := append([]byte(nil), proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family<<4|0x1)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv4Payload := []byte{
+		198, 51, 100, 7, // 源地址
+		10, 0, 0, 1, // 目标地址
+		0x30, 0x39, // 源端口 12345
+		0x01, 0xbb, // 目标端口 443
+	}
+	ipv6Payload := make([]byte, 36)
+	copy(ipv6Payload, net.ParseIP("2001:db8::7"))
+	copy(ipv6Payload[16:], net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(ipv6Payload[32:], 4711)
+	binary.BigEndian.PutUint16(ipv6Payload[34:], 443)
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    string // 空字符串表示没有地址
+		wantErr bool
+	}{
+		{name: "v1 tcp4", input: []byte("PROXY TCP4 198.51.100.7 10.0.0.1 12345 443\r\n"), want: "198.51.100.7:12345"},
+		{name: "v1 tcp6", input: []byte("PROXY TCP6 2001:db8::7 2001:db8::1 4711 443\r\n"), want: "[2001:db8::7]:4711"},
+		{name: "v1 unknown", input: []byte("PROXY UNKNOWN\r\n")},
+		{name: "v1 missing CRLF", input: []byte("PROXY TCP4 198.51.100.7 10.0.0.1 12345 443\n"), wantErr: true},
+		{name: "v1 bad address", input: []byte("PROXY TCP4 999.0.0.1 10.0.0.1 12345 443\r\n"), wantErr: true},
+		{name: "v1 bad port", input: []byte("PROXY TCP4 198.51.100.7 10.0.0.1 70000 443\r\n"), wantErr: true},
+		{name: "v1 wrong field count", input: []byte("PROXY TCP4 198.51.100.7 10.0.0.1 12345\r\n"), wantErr: true},
+		{name: "v2 ipv4", input: proxyV2Header(0x1, 0x1, ipv4Payload), want: "198.51.100.7:12345"},
+		{name: "v2 ipv6", input: proxyV2Header(0x1, 0x2, ipv6Payload), want: "[2001:db8::7]:4711"},
+		{name: "v2 local", input: proxyV2Header(0x0, 0x1, ipv4Payload)},
+		{name: "v2 unspec", input: proxyV2Header(0x1, 0x0, nil)},
+		{name: "v2 short ipv4 payload", input: proxyV2Header(0x1, 0x1, ipv4Payload[:8]), wantErr: true},
+		{name: "v2 truncated payload", input: proxyV2Header(0x1, 0x1, ipv4Payload)[:20], wantErr: true},
+		{name: "no header", input: []byte("GET / HTTP/1.1\r\n\r\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 出错的用例不追加数据，否则会被当作截断头的剩余部分读入
+			var body []byte
+			if !tt.wantErr {
+				body = []byte("GET / HTTP/1.1\r\n\r\n")
+			}
+			reader := bufio.NewReader(bytes.NewReader(append(append([]byte(nil), tt.input...), body...)))
+
+			addr, err := readProxyHeader(reader)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readProxyHeader() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("readProxyHeader() = %q, want %q", got, tt.want)
+			}
+
+			// 头之后的数据原样保留给 HTTP 服务
+			rest, _ := io.ReadAll(reader)
+			if tt.name != "no header" && !bytes.Equal(rest, body) {
+				t.Errorf("remaining data = %q, want %q", rest, body)
+			}
+		})
+	}
+}
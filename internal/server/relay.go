@@ -0,0 +1,284 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/relay"
+)
+
+// relayState 一个条目的转发端口，监听失败时 relay 为空并记录错误，下次配置变更时重试
+type relayState struct {
+	config config.RelayConfig
+	relay  *relay.Relay
+	err    error
+}
+
+// startRelays 按配置打开转发端口，之后每次路径条目变更时重新同步
+func (s *Server) startRelays() {
+	s.relays = make(map[string]*relayState)
+	s.relaySync = make(chan struct{}, 1)
+	s.relayStop = make(chan struct{})
+	s.syncRelays()
+
+	// 监听器在配置写锁内执行，不能直接读取配置，只发出信号
+	s.configStorage.OnChange(func(event config.ChangeEvent) {
+		if strings.HasPrefix(event.Type, "forwarding.") {
+			select {
+			case s.relaySync <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	stop, trigger := s.relayStop, s.relaySync
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-trigger:
+				s.syncRelays()
+			}
+		}
+	}()
+}
+
+// stopRelays 关闭所有转发端口和连接
+func (s *Server) stopRelays() {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	if s.relayStop != nil {
+		close(s.relayStop)
+		s.relayStop = nil
+	}
+	for name, state := range s.relays {
+		if state.relay != nil {
+			state.relay.Close()
+		}
+		delete(s.relays, name)
+	}
+}
+
+// syncRelays 关闭已删除或设置已修改的端口，再打开新的端口；目标修改不需要重新监听
+func (s *Server) syncRelays() {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	if s.relayStop == nil {
+		return
+	}
+	settings := s.configStorage.RelaySettings()
+	desired := map[string]config.RelayConfig{}
+	if settings != nil {
+		desired = s.configStorage.Relays()
+	}
+
+	for name, state := range s.relays {
+		if wanted, ok := desired[name]; ok && wanted == state.config && state.relay != nil {
+			continue
+		}
+		if state.relay != nil {
+			state.relay.Close()
+			log.Printf("[Relay] %s: stopped listening on %s", name, state.config.Listen)
+		}
+		delete(s.relays, name)
+	}
+
+	for name, relayConfig := range desired {
+		if _, running := s.relays[name]; running {
+			continue
+		}
+
+		state := &relayState{config: relayConfig}
+		state.relay, state.err = relay.Listen(relayOptions(name, relayConfig, settings, s.configStorage.RelayTarget))
+		if state.err != nil {
+			log.Printf("[Relay] %s: failed to listen on %s: %v", name, relayConfig.Listen, state.err)
+		} else {
+			protocols := "tcp"
+			if relayConfig.UDP {
+				protocols = "tcp/udp"
+			}
+			log.Printf("[Relay] %s: listening on %s (%s)", name, relayConfig.Listen, protocols)
+		}
+		s.relays[name] = state
+	}
+}
+
+// relayOptions 合并条目和全局设置
+func relayOptions(name string, relayConfig config.RelayConfig, settings *config.RelayServerConfig, target func(string) (string, error)) relay.Options {
+	opts := relay.Options{
+		Name:           name,
+		Listen:         relayConfig.Listen,
+		UDP:            relayConfig.UDP,
+		MaxConnections: relayConfig.MaxConnections,
+		IdleTimeout:    time.Duration(relayConfig.IdleTimeout) * time.Second,
+		Target: func() (string, error) {
+			return target(name)
+		},
+	}
+	if opts.MaxConnections == 0 {
+		opts.MaxConnections = settings.MaxConnections
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = time.Duration(settings.IdleTimeout) * time.Second
+	}
+	return opts
+}
+
+// fillRelayStatus 为条目补充转发端口的运行状态
+func (s *Server) fillRelayStatus(forwardings ...*models.ForwardingEntry) {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	for _, forwarding := range forwardings {
+		if forwarding.Relay == nil {
+			continue
+		}
+		state := s.relays[forwarding.Name]
+		if state == nil {
+			continue
+		}
+		if state.err != nil {
+			forwarding.Relay.Error = state.err.Error()
+			continue
+		}
+		stats := state.relay.Stats()
+		forwarding.Relay.Listening = true
+		forwarding.Relay.ActiveConnections = stats.ActiveConnections
+		forwarding.Relay.ActiveSessions = stats.ActiveSessions
+		forwarding.Relay.TotalConnections = stats.TotalConnections
+		forwarding.Relay.Rejected = stats.Rejected
+	}
+}
+
+type relayStat struct {
+	name  string
+	stats relay.Stats
+}
+
+// relayStats 返回正在监听的端口的统计，按名称排序
+func (s *Server) relayStats() []relayStat {
+	s.relayMu.Lock()
+	defer s.relayMu.Unlock()
+
+	stats := make([]relayStat, 0, len(s.relays))
+	for name, state := range s.relays {
+		if state.relay != nil {
+			stats = append(stats, relayStat{name, state.relay.Stats()})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].name < stats[j].name
+	})
+	return stats
+}
+
+// handleRelay 设置（POST）或取消（DELETE）路径条目的四层转发，只有管理员可以操作
+// POST /api/relay?name=ssh&listen=:2222&udp=true&max_connections=50&idle_timeout=600
+func (s *Server) handleRelay(w http.ResponseWriter, r *http.Request) {
+	method := http.MethodPost
+	if r.Method == http.MethodDelete {
+		method = http.MethodDelete
+	}
+	if !s.authorizeAdminRequest(w, r, method) {
+		return
+	}
+
+	query := r.URL.Query()
+	name := query.Get("name")
+	params := map[string]string{
+		"name":            name,
+		"listen":          query.Get("listen"),
+		"udp":             query.Get("udp"),
+		"max_connections": query.Get("max_connections"),
+		"idle_timeout":    query.Get("idle_timeout"),
+	}
+
+	if name == "" || (method == http.MethodPost && query.Get("listen") == "") {
+		s.logAPIRequest(r, "/api/relay", params, "missing_parameters", http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Missing required parameters: name, listen",
+		})
+		return
+	}
+
+	var err error
+	if method == http.MethodDelete {
+		err = s.configStorage.RemoveRelay(name)
+	} else {
+		var relayConfig config.RelayConfig
+		relayConfig, err = parseRelayConfig(query.Get("listen"), query.Get("udp"), query.Get("max_connections"), query.Get("idle_timeout"))
+		if err == nil {
+			err = s.configStorage.SetRelay(name, relayConfig)
+		}
+	}
+	if err != nil {
+		s.logAPIRequest(r, "/api/relay", params, "error:"+err.Error(), http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 立即同步，使监听失败（例如端口被占用）能直接返回给调用方
+	s.syncRelays()
+
+	forwarding, err := s.configStorage.GetForwarding(name)
+	if err != nil {
+		s.logAPIRequest(r, "/api/relay", params, "error:"+err.Error(), http.StatusNotFound)
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+	s.fillRelayStatus(forwarding)
+
+	s.logAPIRequest(r, "/api/relay", params, "success", http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":      "success",
+		"version":    forwarding.Version,
+		"forwarding": forwarding,
+	})
+}
+
+// parseRelayConfig 解析 API 和命令行的转发参数，数值为空时使用全局设置
+func parseRelayConfig(listen, udp, maxConnections, idleTimeout string) (config.RelayConfig, error) {
+	relayConfig := config.RelayConfig{Listen: listen}
+
+	if udp != "" {
+		value, err := strconv.ParseBool(udp)
+		if err != nil {
+			return relayConfig, fmt.Errorf("invalid udp value %q", udp)
+		}
+		relayConfig.UDP = value
+	}
+	if maxConnections != "" {
+		value, err := strconv.Atoi(maxConnections)
+		if err != nil || value < 0 {
+			return relayConfig, fmt.Errorf("invalid max_connections value %q", maxConnections)
+		}
+		relayConfig.MaxConnections = value
+	}
+	if idleTimeout != "" {
+		value, err := strconv.Atoi(idleTimeout)
+		if err != nil || value < 0 {
+			return relayConfig, fmt.Errorf("invalid idle_timeout value %q, expected seconds", idleTimeout)
+		}
+		relayConfig.IdleTimeout = value
+	}
+	return relayConfig, nil
+}
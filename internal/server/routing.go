@@ -0,0 +1,394 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/geoip"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/routing"
+)
+
+const (
+	// defaultGeoIPReloadInterval 未配置时检查 GeoIP 数据库文件是否更新的间隔
+	defaultGeoIPReloadInterval = time.Minute
+	// maxRoutesBody 路由规则和试算请求体大小上限
+	maxRoutesBody = 64 << 10
+)
+
+// resolveRequest /api/v2/resolve 的请求体：用模拟的访问请求试算条目的路由规则
+type resolveRequest struct {
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	// Rules 不为空时试算这些规则（会校验，但不保存），否则使用条目已保存的规则
+	Rules   []config.RouteRule `json:"rules,omitempty"`
+	Request struct {
+		IP             string            `json:"ip,omitempty"`
+		UserAgent      string            `json:"user_agent,omitempty"`
+		AcceptLanguage string            `json:"accept_language,omitempty"`
+		Headers        map[string]string `json:"headers,omitempty"`
+		Query          map[string]string `json:"query,omitempty"`
+	} `json:"request"`
+}
+
+// startGeoIP 加载 GeoIP 数据库，并定期检查文件是否被替换（例如 geoipupdate 更新后）
+func (s *Server) startGeoIP(settings *config.GeoIPConfig) error {
+	var paths []string
+	for _, path := range []string{settings.Country, settings.ASN} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	db, err := geoip.Open(paths...)
+	if err != nil {
+		return err
+	}
+	s.geoip = db
+	for _, database := range db.Databases() {
+		log.Printf("GeoIP database loaded: %s (%s, built %s)", database.Path, database.Type, database.Built.Format("2006-01-02"))
+	}
+
+	if settings.ReloadInterval < 0 {
+		return nil
+	}
+	interval := defaultGeoIPReloadInterval
+	if settings.ReloadInterval > 0 {
+		interval = time.Duration(settings.ReloadInterval) * time.Second
+	}
+
+	stop := make(chan struct{})
+	s.geoipStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.reloadGeoIP(false)
+			}
+		}
+	}()
+	return nil
+}
+
+// stopGeoIP 停止后台检查
+func (s *Server) stopGeoIP() {
+	if s.geoipStop != nil {
+		close(s.geoipStop)
+		s.geoipStop = nil
+	}
+}
+
+// reloadGeoIP 重新加载已更新的数据库文件，失败时继续使用旧数据
+func (s *Server) reloadGeoIP(force bool) (int, error) {
+	reloaded, err := s.geoip.Reload(force)
+	if err != nil {
+		log.Printf("GeoIP reload failed: %v", err)
+	}
+	if reloaded > 0 {
+		log.Printf("GeoIP reloaded %d database(s)", reloaded)
+	}
+	return reloaded, err
+}
+
+// routingRequest 收集规则匹配用到的访问者信息
+func (s *Server) routingRequest(r *http.Request) *routing.Request {
+	clientIP, _ := netip.ParseAddr(s.ClientIP(r))
+	return &routing.Request{ClientIP: clientIP, GeoIP: s.geoip, Header: r.Header, Query: r.URL.Query()}
+}
+
+// routeForwarding 按路径跳转的路由规则选择本次访问的目标
+func (s *Server) routeForwarding(r *http.Request, name, target string) string {
+	entry, err := s.storage.GetForwarding(name)
+	if err != nil || len(entry.Routes) == 0 {
+		return target
+	}
+	return routing.Resolve(entry.Routes, target, s.routingRequest(r)).Target
+}
+
+// routeDomain 按域名映射的路由规则选择本次访问的目标
+func (s *Server) routeDomain(r *http.Request, target string) string {
+	entry, err := s.domainStorage.GetDomain(requestHost(r))
+	if err != nil || len(entry.Routes) == 0 {
+		return target
+	}
+	return routing.Resolve(entry.Routes, target, s.routingRequest(r)).Target
+}
+
+// handleRoutes 替换（PUT）或清空（DELETE）条目的路由规则，条目所属用户和管理员可以操作
+// PUT /api/routes?name=site，请求体为规则数组，例如 [{"countries":["CN"],"target":"https://mirror.example.cn"}]
+func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	name, domain := r.URL.Query().Get("name"), r.URL.Query().Get("domain")
+	params := map[string]string{"name": name, "domain": domain}
+
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		s.logAPIRequest(r, "/api/routes", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/routes", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	var rules []config.RouteRule
+	var err error
+	if (name == "") == (domain == "") {
+		err = errors.New("Use exactly one of the parameters: name, domain")
+	} else if r.Method == http.MethodPut {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRoutesBody))
+		decoder.DisallowUnknownFields()
+		if decodeErr := decoder.Decode(&rules); decodeErr != nil {
+			err = errors.New("Invalid JSON: " + decodeErr.Error())
+		}
+	}
+	if err == nil {
+		// 当前请求的 Host 视为本服务地址，规则目标不能指回自己
+		if name != "" {
+			err = s.configStorage.SetForwardingRoutes(&actor, name, rules, r.Host)
+		} else {
+			err = s.configStorage.SetDomainRoutes(&actor, domain, rules, r.Host)
+		}
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/routes", params, "error:"+err.Error(), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var version int64
+	if name != "" {
+		if entry, err := s.configStorage.GetForwarding(name); err == nil {
+			version = entry.Version
+		}
+	} else if entry, err := s.configStorage.GetDomain(domain); err == nil {
+		version = entry.Version
+	}
+
+	s.logAPIRequest(r, "/api/routes", params, "success", http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:   "success",
+		Version: version,
+	})
+}
+
+// handleGeoIP 返回已加载的 GeoIP 数据库，并查询 ip 参数（默认为调用方自己的 IP）的国家和 ASN
+func (s *Server) handleGeoIP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	if s.geoip == nil {
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: "GeoIP is not configured",
+		})
+		return
+	}
+
+	value := r.URL.Query().Get("ip")
+	if value == "" {
+		value = s.ClientIP(r)
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Invalid IP address",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":     "success",
+		"databases": s.geoip.Databases(),
+		"ip":        addr.String(),
+		"result":    s.geoip.Lookup(addr),
+	})
+}
+
+// handleGeoIPReload 立即重新加载全部 GeoIP 数据库文件
+func (s *Server) handleGeoIPReload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodPost) {
+		return
+	}
+
+	params := map[string]string{}
+	if s.geoip == nil {
+		s.logAPIRequest(r, "/api/geoip/reload", params, "not_configured", http.StatusNotFound)
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: "GeoIP is not configured",
+		})
+		return
+	}
+
+	if _, err := s.reloadGeoIP(true); err != nil {
+		s.logAPIRequest(r, "/api/geoip/reload", params, "error:"+err.Error(), http.StatusInternalServerError)
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.logAPIRequest(r, "/api/geoip/reload", params, "success", http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":     "success",
+		"databases": s.geoip.Databases(),
+	})
+}
+
+// handleResolve 试算路由规则：返回模拟请求会跳转到的目标和匹配的规则序号，条目所属用户和管理员可以操作
+// POST /api/v2/resolve，例如 {"name":"app","request":{"user_agent":"Mozilla/5.0 (iPhone; ...)","accept_language":"zh-CN"}}
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	var body resolveRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRoutesBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+	if (body.Name == "") == (body.Domain == "") {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Use exactly one of the fields: name, domain",
+		})
+		return
+	}
+
+	// 不暴露其他用户的条目
+	var target, owner string
+	var rules []models.RouteRule
+	var err error
+	if body.Name != "" {
+		var entry *models.ForwardingEntry
+		if entry, err = s.storage.GetForwarding(body.Name); err == nil {
+			target, owner, rules = entry.Target, entry.Owner, entry.Routes
+		}
+		if err != nil || !actor.CanAccess(owner) {
+			err = errors.New("forwarding name not found")
+		}
+	} else if s.domainStorage != nil {
+		var entry *models.DomainEntry
+		if entry, err = s.domainStorage.GetDomain(body.Domain); err == nil {
+			target, owner, rules = entry.Target, entry.Owner, entry.Routes
+		}
+		if err != nil || !actor.CanAccess(owner) {
+			err = errors.New("domain not found")
+		}
+	} else {
+		err = errors.New("domain not found")
+	}
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if len(body.Rules) > 0 {
+		if rules, err = s.configStorage.NormalizeRoutes(body.Rules, r.Host); err != nil {
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	req := &routing.Request{GeoIP: s.geoip, Header: http.Header{}, Query: url.Values{}}
+	if body.Request.IP != "" {
+		if req.ClientIP, err = netip.ParseAddr(body.Request.IP); err != nil {
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: "Invalid IP address",
+			})
+			return
+		}
+	}
+	for name, value := range body.Request.Headers {
+		req.Header.Set(name, value)
+	}
+	if body.Request.UserAgent != "" {
+		req.Header.Set("User-Agent", body.Request.UserAgent)
+	}
+	if body.Request.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", body.Request.AcceptLanguage)
+	}
+	for name, value := range body.Request.Query {
+		req.Query.Set(name, value)
+	}
+
+	result := routing.Resolve(rules, target, req)
+	response := map[string]interface{}{
+		"state":  "success",
+		"target": result.Target,
+		"rule":   result.Rule,
+		// 规则匹配时实际使用的访问者信息，便于排查规则为什么没有匹配
+		"visitor": map[string]interface{}{
+			"ip":       body.Request.IP,
+			"geo":      req.Geo(),
+			"device":   req.Device(),
+			"language": req.Language(),
+		},
+	}
+	if result.Rule > 0 {
+		response["matched"] = rules[result.Rule-1]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,16 +1,32 @@
 package server
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/crypto/acme/autocert"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/dnsserver"
+	"redirect_helper/internal/events"
+	"redirect_helper/internal/geoip"
 	"redirect_helper/internal/models"
+	"redirect_helper/internal/relay"
+	"redirect_helper/internal/session"
 	"redirect_helper/internal/storage"
+	"redirect_helper/internal/validation"
+	"redirect_helper/internal/webhook"
 	"redirect_helper/pkg/utils"
 )
 
@@ -19,17 +35,111 @@ type Server struct {
 	domainStorage storage.DomainStorage
 	configStorage *storage.ConfigStorage
 	mux           *http.ServeMux
+	adminMux      *http.ServeMux // 仅在配置了独立管理端口时使用
+	adminAddr     string
+	basePath      string
+
+	trustedProxies []netip.Prefix
+	clientIPHeader string // 可信代理设置客户端地址的请求头，见 parseClientIPHeader
+	proxyProtocol  bool
+
+	fileCert      *tls.Certificate
+	acmeManager   *autocert.Manager
+	httpsRedirect bool
+	httpsPort     string
+
+	serversMu    sync.Mutex
+	httpServers  []*http.Server
+	shuttingDown atomic.Bool
+
+	webhooks  *webhook.Dispatcher
+	startedAt time.Time
+
+	events       *events.Broker
+	healthStop   chan struct{}
+	healthStatus string // 上次推送的健康状态，仅由健康检查协程访问
+
+	sessions *session.Store
+
+	tokenUsage tokenUsage
+
+	dnsServer   *dnsserver.Server
+	dnsPublicV4 []netip.Addr
+	dnsPublicV6 []netip.Addr
+	dnsRecords  map[string]*dnsRecords
+
+	verifyStop chan struct{}
+
+	sniProxy   *relay.SNIProxy
+	tlsHandoff *handoffListener // 内置 HTTPS 与 SNI 透传共用端口时的连接来源
+
+	relayMu   sync.Mutex
+	relays    map[string]*relayState
+	relaySync chan struct{}
+	relayStop chan struct{}
+
+	wakes wakeTracker
+
+	pathRules pathRuleCache // 域名映射编译后的路径规则
+
+	pages map[string]*template.Template // 配置目录中覆盖内置页面的模板
+
+	linkKey []byte // 签名访问密码 cookie 的密钥
+
+	geoip     *geoip.DB // 未配置 GeoIP 时为 nil，国家和 ASN 条件不匹配
+	geoipStop chan struct{}
 }
 
 func NewServer(store interface{}) *Server {
 	s := &Server{
-		mux: http.NewServeMux(),
+		mux:       http.NewServeMux(),
+		startedAt: time.Now(),
+		linkKey:   newLinkKey(),
 	}
 
 	if configStorage, ok := store.(*storage.ConfigStorage); ok {
 		s.configStorage = configStorage
 		s.storage = configStorage
 		s.domainStorage = configStorage
+		s.pages = loadPageTemplates(pageTemplateDir())
+
+		if serverConfig := configStorage.GetServerConfig(); serverConfig != nil {
+			s.trustedProxies = parseTrustedProxies(serverConfig.TrustedProxies)
+			s.clientIPHeader = parseClientIPHeader(serverConfig.ClientIPHeader)
+			s.proxyProtocol = serverConfig.ProxyProtocol
+			s.basePath = normalizeBasePath(serverConfig.BasePath)
+
+			if serverConfig.AdminListen != "" {
+				s.adminMux = http.NewServeMux()
+				s.adminAddr = serverConfig.AdminListen
+			}
+		}
+
+		// 条目变更通过 webhook 通知外部系统
+		s.webhooks = webhook.NewDispatcher(configStorage.GetWebhooksConfig(), true)
+		configStorage.OnChange(s.webhooks.Notify)
+
+		// 同时推送给 /api/v2/events 的实时客户端
+		bufferSize := 0
+		if serverConfig := configStorage.GetServerConfig(); serverConfig != nil {
+			bufferSize = serverConfig.EventBufferSize
+		}
+		s.events = events.NewBroker(bufferSize)
+		configStorage.OnChange(func(event config.ChangeEvent) {
+			s.events.Publish(event.Type, event)
+		})
+
+		// 浏览器登录会话，admin token 重置后全部失效
+		sessionTTL := 0
+		if serverConfig := configStorage.GetServerConfig(); serverConfig != nil {
+			sessionTTL = serverConfig.SessionTTL
+		}
+		s.sessions = session.NewStore(time.Duration(sessionTTL) * time.Second)
+		configStorage.OnChange(func(event config.ChangeEvent) {
+			if event.Type == config.EventTokenReset && event.Name == "admin" {
+				s.sessions.RevokeAll()
+			}
+		})
 	}
 
 	s.setupRoutes()
@@ -37,24 +147,90 @@ func NewServer(store interface{}) *Server {
 }
 
 func (s *Server) setupRoutes() {
+	if s.adminMux != nil {
+		// 独立管理端口：公共端口只处理跳转，API 和管理界面只在管理端口提供
+		s.setupPublicRoutes(s.mux)
+		s.setupHealthRoutes(s.mux)
+		s.setupAPIRoutes(s.adminMux)
+		s.setupHealthRoutes(s.adminMux)
+		s.setupUIRoutes(s.adminMux)
+		s.adminMux.HandleFunc("/", s.handleIndex)
+		return
+	}
+
+	s.setupAPIRoutes(s.mux)
+	s.setupHealthRoutes(s.mux)
+	s.setupUIRoutes(s.mux)
+	s.setupPublicRoutes(s.mux)
+}
+
+func (s *Server) setupAPIRoutes(mux *http.ServeMux) {
 	// API routes - basic operations
-	s.mux.HandleFunc("/api/list", s.handleListForwardings)
-	s.mux.HandleFunc("/api/remove", s.handleRemoveForwarding)
-	s.mux.HandleFunc("/api/update", s.handleUpdateSetTarget)
+	mux.HandleFunc("/api/list", s.handleListForwardings)
+	mux.HandleFunc("/api/remove", s.handleRemoveForwarding)
+	mux.HandleFunc("/api/update", s.handleUpdateSetTarget)
+	mux.HandleFunc("/api/get", s.handleGetForwarding)
+	mux.HandleFunc("/api/relay", s.handleRelay)
+	mux.HandleFunc("/api/wake", s.handleWake)
+	mux.HandleFunc("/api/wake/config", s.handleWakeConfig)
+	mux.HandleFunc("/api/preview", s.handlePreview)
+	mux.HandleFunc("/api/access", s.handleAccess)
+	mux.HandleFunc("/api/routes", s.handleRoutes)
 
 	// API routes - domain operations
-	s.mux.HandleFunc("/api/list-domains", s.handleListDomains)
-	s.mux.HandleFunc("/api/remove-domain", s.handleRemoveDomain)
-	s.mux.HandleFunc("/api/update-domain", s.handleUpdateDomainTarget)
+	mux.HandleFunc("/api/list-domains", s.handleListDomains)
+	mux.HandleFunc("/api/remove-domain", s.handleRemoveDomain)
+	mux.HandleFunc("/api/update-domain", s.handleUpdateDomainTarget)
+	mux.HandleFunc("/api/get-domain", s.handleGetDomain)
+	mux.HandleFunc("/api/path-rules", s.handlePathRules)
+
+	// API routes - per-entry update tokens and DynDNS2-compatible updates
+	mux.HandleFunc("/api/update-token", s.handleUpdateToken)
+	mux.HandleFunc("/nic/update", s.handleDynDNS)
+
+	// API routes - domain ownership verification
+	mux.HandleFunc("/api/verify-domain", s.handleVerifyDomain)
 
 	// API routes - batch operations
-	s.mux.HandleFunc("/api/batch-update", s.handleBatchUpdate)
+	mux.HandleFunc("/api/batch-update", s.handleBatchUpdate)
+
+	// API routes - live events
+	mux.HandleFunc("/api/v2/events", s.handleEvents)
+	mux.HandleFunc("/api/v2/resolve", s.handleResolve)
+
+	// API routes - browser sessions
+	mux.HandleFunc("/api/login", s.handleLogin)
+	mux.HandleFunc("/api/logout", s.handleLogout)
+	mux.HandleFunc("/api/session", s.handleSession)
+
+	// API routes - administration
+	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/tokens", s.handleTokens)
+	mux.HandleFunc("/api/tokens/reset", s.handleResetToken)
+	mux.HandleFunc("/api/tokens/rotate", s.handleRotateToken)
+	mux.HandleFunc("/api/geoip", s.handleGeoIP)
+	mux.HandleFunc("/api/geoip/reload", s.handleGeoIPReload)
+
+	// API routes - webhooks
+	mux.HandleFunc("/api/webhooks", s.handleListWebhooks)
+	mux.HandleFunc("/api/webhooks/deliveries", s.handleWebhookDeliveries)
+}
 
+func (s *Server) setupHealthRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+}
+
+func (s *Server) setupPublicRoutes(mux *http.ServeMux) {
 	// Legacy redirect route
-	s.mux.HandleFunc("/go/", s.handleRedirect)
+	mux.HandleFunc("/go/", s.handleRedirect)
+
+	// 网络唤醒等待页面的状态轮询
+	mux.HandleFunc(wakeStatusPath, s.handleWakeStatus)
 
 	// Catch-all handler for domain proxy (must be last)
-	s.mux.HandleFunc("/", s.handleRequest)
+	mux.HandleFunc("/", s.handleRequest)
 }
 
 func (s *Server) handleUpdateSetTarget(w http.ResponseWriter, r *http.Request) {
@@ -91,18 +267,18 @@ func (s *Server) handleUpdateSetTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !s.isValidTarget(target) {
-		s.logAPIRequest(r, "/api/update", params, "invalid_target_format", http.StatusBadRequest)
+	if err := s.validateTarget(r, target); err != nil {
+		s.logAPIRequest(r, "/api/update", params, "invalid_target", http.StatusBadRequest)
 		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
 			State:   "error",
-			Message: "Invalid target format. Expected host:port",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	err := s.storage.SetTarget(name, token, target)
+	expected, err := parseExpectedVersion(r)
 	if err != nil {
-		s.logAPIRequest(r, "/api/update", params, fmt.Sprintf("error:%s", err.Error()), http.StatusBadRequest)
+		s.logAPIRequest(r, "/api/update", params, "invalid_precondition", http.StatusBadRequest)
 		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
 			State:   "error",
 			Message: err.Error(),
@@ -110,9 +286,30 @@ func (s *Server) handleUpdateSetTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 配置存储支持版本检查，写入后返回新版本作为 ETag
+	var version int64
+	if s.configStorage != nil {
+		version, err = s.configStorage.SetTargetWithVersion(name, token, target, expected.version, r.Host)
+	} else {
+		err = s.storage.SetTarget(name, token, target)
+	}
+	if err != nil {
+		status := expected.writeStatus(err)
+		s.logAPIRequest(r, "/api/update", params, fmt.Sprintf("error:%s", err.Error()), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if version > 0 {
+		w.Header().Set("ETag", formatETag(version))
+	}
 	s.logAPIRequest(r, "/api/update", params, "success", http.StatusOK)
 	s.writeJSONResponse(w, http.StatusOK, models.Response{
-		State: "success",
+		State:   "success",
+		Version: version,
 	})
 }
 
@@ -122,8 +319,8 @@ func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 从URL路径中提取名称，路径格式为 /go/name
-	name := strings.TrimPrefix(r.URL.Path, "/go/")
+	// 从URL路径中提取名称，路径格式为 /go/name；/go/name+ 先显示预览页面
+	name, preview := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/go/"), "+")
 
 	if name == "" {
 		http.Error(w, "No forwarding name specified", http.StatusBadRequest)
@@ -136,205 +333,33 @@ func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 按访问者选择目标，之后的唤醒、预览和跳转都使用选中的目标
+	target = s.routeForwarding(r, name, target)
+
+	// 访问密码和使用次数限制
+	if s.guardForwarding(w, r, name) {
+		return
+	}
+
+	// 目标休眠时先唤醒
+	if s.wakeForwarding(w, r, name, target) {
+		return
+	}
+
 	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
 		target = "http://" + target
 	}
 
-	http.Redirect(w, r, target, http.StatusFound)
-}
+	// 显示预览页面或跳转都算作一次使用
+	if s.consumeForwardingUse(w, name) {
+		return
+	}
 
-func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+	if s.previewForwarding(w, name, target, preview) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	html := `
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>Redirect Helper</title>
-    <style>
-        body { font-family: Arial, sans-serif; max-width: 700px; margin: 0 auto; padding: 20px; }
-        .api-section { margin: 20px 0; padding: 15px; background: #f5f5f5; border-radius: 5px; }
-        .admin-section { margin: 20px 0; padding: 15px; background: #e8f4f8; border-radius: 5px; border-left: 4px solid #0066cc; }
-        code { background: #e8e8e8; padding: 2px 4px; border-radius: 3px; font-size: 12px; }
-        .warning { color: #d63384; font-weight: bold; margin-top: 10px; }
-        .method { color: #0066cc; font-weight: bold; }
-        .form-group { margin: 10px 0; }
-        .form-group label { display: block; margin-bottom: 5px; font-weight: bold; }
-        .form-group input { width: 100%; padding: 8px; border: 1px solid #ddd; border-radius: 4px; font-size: 14px; }
-        .btn { padding: 10px 15px; margin: 5px; border: none; border-radius: 4px; cursor: pointer; font-size: 14px; }
-        .btn-primary { background: #0066cc; color: white; }
-        .btn-primary:hover { background: #0052a3; }
-        .btn-secondary { background: #6c757d; color: white; }
-        .btn-secondary:hover { background: #5a6268; }
-        .result { margin-top: 15px; padding: 10px; border-radius: 4px; display: none; }
-        .result.success { background: #d4edda; border: 1px solid #c3e6cb; color: #155724; }
-        .result.error { background: #f8d7da; border: 1px solid #f5c6cb; color: #721c24; }
-        .entries { margin-top: 10px; }
-        .entry { margin: 5px 0; padding: 8px; background: #f8f9fa; border-radius: 4px; }
-        .entry strong { color: #0066cc; }
-        .entry-time { color: #6c757d; font-size: 12px; }
-    </style>
-</head>
-<body>
-    <h1>🔄 Redirect Helper</h1>
-    <p>Two redirect modes: path-based and domain-based</p>
-
-    <div class="admin-section">
-        <h2>🔑 Admin Management</h2>
-        <div class="form-group">
-            <label for="adminToken">Admin Token:</label>
-            <input type="text" id="adminToken" placeholder="Enter your admin token">
-        </div>
-        <button class="btn btn-primary" onclick="listRedirects()">List Redirects</button>
-        <button class="btn btn-secondary" onclick="listDomains()">List Domains</button>
-        <div id="result" class="result"></div>
-    </div>
-
-    <div class="api-section">
-        <h2>🔗 Path Redirects</h2>
-        <p><strong>Access:</strong> <code>/go/&lt;name&gt;</code></p>
-        <p><span class="method">GET</span> <strong>Update/Create:</strong> <code>/api/update?name=&lt;name&gt;&token=&lt;redirect_token&gt;&target=&lt;target&gt;</code></p>
-        <p><span class="method">GET</span> <strong>List:</strong> <code>/api/list?admin_token=&lt;admin_token&gt;</code></p>
-        <p><span class="method">DELETE</span> <strong>Remove:</strong> <code>/api/remove?name=&lt;name&gt;&admin_token=&lt;admin_token&gt;</code></p>
-    </div>
-
-    <div class="api-section">
-        <h2>🌐 Domain Redirects</h2>
-        <p><strong>Access:</strong> Direct domain access with full URL preservation</p>
-        <p><span class="method">GET</span> <strong>Update/Create:</strong> <code>/api/update-domain?domain=&lt;domain&gt;&token=&lt;domain_token&gt;&target=&lt;target&gt;</code></p>
-        <p><span class="method">GET</span> <strong>List:</strong> <code>/api/list-domains?admin_token=&lt;admin_token&gt;</code></p>
-        <p><span class="method">DELETE</span> <strong>Remove:</strong> <code>/api/remove-domain?domain=&lt;domain&gt;&admin_token=&lt;admin_token&gt;</code></p>
-    </div>
-
-    <div class="api-section">
-        <h2>🔄 Batch Update</h2>
-        <p><strong>Update multiple entries in one request</strong></p>
-
-        <h3>GET Method (Indexed Parameters)</h3>
-        <p><span class="method">GET</span> <code>/api/batch-update?redirect_token=&lt;token&gt;&name1=&lt;name&gt;&target1=&lt;target&gt;&name2=...</code></p>
-        <p><strong>Example - Path redirects:</strong></p>
-        <code style="display:block;margin:5px 0;padding:8px;background:#fff;">
-        /api/batch-update?redirect_token=xxx&name1=test1&target1=google.com:443&name2=test2&target2=baidu.com:443
-        </code>
-
-        <p><strong>Example - Domain redirects:</strong></p>
-        <code style="display:block;margin:5px 0;padding:8px;background:#fff;">
-        /api/batch-update?domain_token=xxx&domain1=d1.example.com&target1=https://google.com&domain2=d2.example.com&target2=https://baidu.com
-        </code>
-
-        <p><strong>Example - Mixed (paths + domains):</strong></p>
-        <code style="display:block;margin:5px 0;padding:8px;background:#fff;">
-        /api/batch-update?redirect_token=xxx&domain_token=yyy&name1=test&target1=google.com:443&domain2=d.example.com&target2=https://github.com
-        </code>
-
-        <h3>POST Method (JSON Body)</h3>
-        <p><span class="method">POST</span> <code>/api/batch-update</code> with JSON body</p>
-        <pre style="background:#fff;padding:8px;border-radius:4px;overflow-x:auto;font-size:12px;">
-{
-  "redirect_token": "your_token",
-  "domain_token": "your_token",
-  "entries": [
-    {"name": "test1", "target": "google.com:443"},
-    {"domain": "d.example.com", "target": "https://github.com"}
-  ]
-}</pre>
-
-        <p><strong>Response includes per-entry status and summary statistics</strong></p>
-    </div>
-
-    <div class="warning">
-        ⚠️ Management operations (list, remove) require admin token. Update operations require specific tokens.
-    </div>
-
-    <script>
-        function showResult(message, isSuccess) {
-            const result = document.getElementById('result');
-            result.className = 'result ' + (isSuccess ? 'success' : 'error');
-            result.innerHTML = message;
-            result.style.display = 'block';
-        }
-
-        function formatDate(dateString) {
-            return new Date(dateString).toLocaleString();
-        }
-
-        function listRedirects() {
-            const adminToken = document.getElementById('adminToken').value;
-            if (!adminToken) {
-                showResult('Please enter admin token', false);
-                return;
-            }
-
-            fetch('/api/list?admin_token=' + encodeURIComponent(adminToken))
-                .then(response => response.json())
-                .then(data => {
-                    if (data.state === 'success') {
-                        let html = '<h3>📋 Path Redirects:</h3>';
-                        if (data.forwardings && data.forwardings.length > 0) {
-                            html += '<div class="entries">';
-                            data.forwardings.forEach(forwarding => {
-                                html += '<div class="entry">';
-                                html += '<strong>' + forwarding.name + '</strong> → ' + forwarding.target;
-                                html += '<div class="entry-time">Created: ' + formatDate(forwarding.created_at) + '</div>';
-                                html += '</div>';
-                            });
-                            html += '</div>';
-                        } else {
-                            html += '<p>No redirects found</p>';
-                        }
-                        showResult(html, true);
-                    } else {
-                        showResult('Error: ' + data.message, false);
-                    }
-                })
-                .catch(error => {
-                    showResult('Request failed: ' + error.message, false);
-                });
-        }
-
-        function listDomains() {
-            const adminToken = document.getElementById('adminToken').value;
-            if (!adminToken) {
-                showResult('Please enter admin token', false);
-                return;
-            }
-
-            fetch('/api/list-domains?admin_token=' + encodeURIComponent(adminToken))
-                .then(response => response.json())
-                .then(data => {
-                    if (data.state === 'success') {
-                        let html = '<h3>🌐 Domain Redirects:</h3>';
-                        if (data.domains && data.domains.length > 0) {
-                            html += '<div class="entries">';
-                            data.domains.forEach(domain => {
-                                html += '<div class="entry">';
-                                html += '<strong>' + domain.domain + '</strong> → ' + domain.target;
-                                html += '<div class="entry-time">Created: ' + formatDate(domain.created_at) + '</div>';
-                                html += '</div>';
-                            });
-                            html += '</div>';
-                        } else {
-                            html += '<p>No domain redirects found</p>';
-                        }
-                        showResult(html, true);
-                    } else {
-                        showResult('Error: ' + data.message, false);
-                    }
-                })
-                .catch(error => {
-                    showResult('Request failed: ' + error.message, false);
-                });
-        }
-    </script>
-</body>
-</html>
-`
-	w.Write([]byte(html))
+	http.Redirect(w, r, target, http.StatusFound)
 }
 
 func (s *Server) writeJSONResponse(w http.ResponseWriter, status int, response models.Response) {
@@ -343,18 +368,12 @@ func (s *Server) writeJSONResponse(w http.ResponseWriter, status int, response m
 	json.NewEncoder(w).Encode(response)
 }
 
-func (s *Server) isValidTarget(target string) bool {
-	if target == "" {
-		return false
-	}
-
-	if strings.Contains(target, "://") {
-		u, err := url.Parse(target)
-		return err == nil && u.Host != ""
+// validateTarget 校验目标地址，当前请求的 Host 视为本服务地址以检测跳转循环
+func (s *Server) validateTarget(r *http.Request, target string) error {
+	if s.configStorage == nil {
+		return validation.ValidateTarget(target, validation.TargetPolicy{})
 	}
-
-	parts := strings.Split(target, ":")
-	return len(parts) >= 2 && parts[0] != "" && parts[1] != ""
+	return s.configStorage.ValidateTarget(target, r.Host)
 }
 
 func (s *Server) handleUpdateDomainTarget(w http.ResponseWriter, r *http.Request) {
@@ -391,11 +410,11 @@ func (s *Server) handleUpdateDomainTarget(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if !s.isValidTarget(target) {
-		s.logAPIRequest(r, "/api/update-domain", params, "invalid_target_format", http.StatusBadRequest)
+	if err := s.validateTarget(r, target); err != nil {
+		s.logAPIRequest(r, "/api/update-domain", params, "invalid_target", http.StatusBadRequest)
 		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
 			State:   "error",
-			Message: "Invalid target format. Expected URL or host:port",
+			Message: err.Error(),
 		})
 		return
 	}
@@ -409,9 +428,9 @@ func (s *Server) handleUpdateDomainTarget(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err := s.domainStorage.SetDomainTarget(domain, token, target)
+	expected, err := parseExpectedVersion(r)
 	if err != nil {
-		s.logAPIRequest(r, "/api/update-domain", params, fmt.Sprintf("error:%s", err.Error()), http.StatusBadRequest)
+		s.logAPIRequest(r, "/api/update-domain", params, "invalid_precondition", http.StatusBadRequest)
 		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
 			State:   "error",
 			Message: err.Error(),
@@ -419,10 +438,44 @@ func (s *Server) handleUpdateDomainTarget(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	var version int64
+	if s.configStorage != nil {
+		version, err = s.configStorage.SetDomainTargetWithVersion(domain, token, target, expected.version, r.Host)
+	} else {
+		err = s.domainStorage.SetDomainTarget(domain, token, target)
+	}
+	if err != nil {
+		status := expected.writeStatus(err)
+		s.logAPIRequest(r, "/api/update-domain", params, fmt.Sprintf("error:%s", err.Error()), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if version > 0 {
+		w.Header().Set("ETag", formatETag(version))
+	}
 	s.logAPIRequest(r, "/api/update-domain", params, "success", http.StatusOK)
-	s.writeJSONResponse(w, http.StatusOK, models.Response{
-		State: "success",
-	})
+	response := models.Response{
+		State:   "success",
+		Version: version,
+	}
+	// 新建的域名在验证通过前不会生效，返回需要发布的 challenge
+	if verification := s.pendingVerification(domain); verification != nil {
+		response.Message = "Domain ownership verification pending"
+		response.Verification = verification
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// requestAdminToken 从 Authorization: Bearer 请求头或 admin_token 参数中读取 admin token
+func requestAdminToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("admin_token")
 }
 
 func (s *Server) validateAdminToken(token string) bool {
@@ -446,13 +499,15 @@ func (s *Server) handleListDomains(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查管理员认证
-	adminToken := r.URL.Query().Get("admin_token")
-	if !s.validateAdminToken(adminToken) {
-		s.writeJSONResponse(w, http.StatusUnauthorized, models.Response{
-			State:   "error",
-			Message: "Unauthorized access. Admin token required.",
-		})
+	// 普通用户只能看到自己的域名映射，管理员可以用 owner 参数按用户过滤
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.writeAuthError(w, authErr)
+		return
+	}
+	owner, ok := listOwner(r, actor)
+	if !ok {
+		s.writeAuthError(w, errNotAdmin)
 		return
 	}
 
@@ -473,14 +528,24 @@ func (s *Server) handleListDomains(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	domains = filterDomains(domains, owner)
+
 	// 转换为公开信息，隐藏敏感token
 	publicDomains := make([]*models.DomainEntryPublic, len(domains))
 	for i, domain := range domains {
 		publicDomains[i] = &models.DomainEntryPublic{
 			Domain:    domain.Domain,
 			Target:    domain.Target,
+			Version:   domain.Version,
+			Owner:     domain.Owner,
+
+			HasUpdateToken: domain.HasUpdateToken,
 			CreatedAt: domain.CreatedAt,
 			UpdatedAt: domain.UpdatedAt,
+			Verification: domain.Verification,
+			Wake: domain.Wake,
+			Routes: domain.Routes,
+			PathRules: domain.PathRules,
 		}
 	}
 
@@ -508,7 +573,8 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If no domain match, handle as normal request
-	if r.URL.Path == "/" {
+	// 配置了独立管理端口时，公共端口不提供管理界面
+	if r.URL.Path == "/" && s.adminMux == nil {
 		s.handleIndex(w, r)
 	} else {
 		http.NotFound(w, r)
@@ -516,6 +582,19 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleDomainProxy(w http.ResponseWriter, r *http.Request, targetURL string) {
+	// 路径规则匹配时跳转到规则的地址
+	if s.redirectDomainPath(w, r) {
+		return
+	}
+
+	// 按访问者选择目标
+	targetURL = s.routeDomain(r, targetURL)
+
+	// 目标休眠时先唤醒
+	if s.wakeDomain(w, r, targetURL) {
+		return
+	}
+
 	target, err := url.Parse(targetURL)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Invalid target URL: %v", err), http.StatusInternalServerError)
@@ -556,15 +635,103 @@ func (s *Server) checkDomainRedirect(w http.ResponseWriter, r *http.Request) boo
 }
 
 func (s *Server) Start(addr string) error {
-	return http.ListenAndServe(addr, s.mux)
+	errCh := make(chan error, 4)
+
+	if s.webhooks != nil {
+		if err := s.webhooks.Start(); err != nil {
+			return err
+		}
+	}
+	if s.events != nil {
+		s.startHealthMonitor()
+	}
+	if s.configStorage != nil && s.configStorage.VerificationConfig() != nil {
+		s.startVerifier()
+	}
+	if s.configStorage != nil && s.configStorage.RelaySettings() != nil {
+		s.startRelays()
+	}
+	if s.configStorage != nil {
+		if settings := s.configStorage.GeoIPSettings(); settings != nil {
+			if err := s.startGeoIP(settings); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.configStorage != nil {
+		if serverConfig := s.configStorage.GetServerConfig(); serverConfig != nil && serverConfig.SNI != nil && serverConfig.SNI.Enabled {
+			if err := s.startSNI(serverConfig.SNI, serverConfig.TLS); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.configStorage != nil {
+		if serverConfig := s.configStorage.GetServerConfig(); serverConfig != nil && serverConfig.TLS != nil && serverConfig.TLS.Enabled {
+			if err := s.setupTLS(serverConfig.TLS); err != nil {
+				return err
+			}
+
+			port := serverConfig.TLS.Port
+			if port == "" {
+				port = "443"
+				s.httpsPort = port
+			}
+
+			tlsServer := s.newHTTPServer(s.withBasePath(s.mux))
+			tlsServer.TLSConfig = s.tlsConfig()
+			go func() {
+				errCh <- s.startTLS(tlsServer, ":"+port)
+			}()
+		}
+	}
+
+	if s.configStorage != nil {
+		if serverConfig := s.configStorage.GetServerConfig(); serverConfig != nil && serverConfig.DNS != nil && serverConfig.DNS.Enabled {
+			if err := s.setupDNS(serverConfig.DNS); err != nil {
+				return err
+			}
+			log.Printf("DNS server started on %s (udp/tcp)", s.dnsServer.Addr)
+			go func() {
+				errCh <- s.dnsServer.ListenAndServe()
+			}()
+		}
+	}
+
+	listener, err := s.listen(addr)
+	if err != nil {
+		return err
+	}
+
+	httpServer := s.newHTTPServer(s.httpHandler(s.withBasePath(s.mux)))
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+
+	if s.adminMux != nil {
+		adminListener, err := s.listen(s.adminAddr)
+		if err != nil {
+			return fmt.Errorf("failed to start admin listener: %v", err)
+		}
+
+		adminServer := s.newHTTPServer(s.withBasePath(s.adminMux))
+		log.Printf("Admin listener started on %s", s.adminAddr)
+		go func() {
+			errCh <- adminServer.Serve(adminListener)
+		}()
+	}
+
+	err = <-errCh
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 // logAPIRequest logs API requests with relevant information
 func (s *Server) logAPIRequest(r *http.Request, endpoint string, params map[string]string, result string, status int) {
-	clientIP := r.RemoteAddr
-	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-		clientIP = forwardedFor
-	}
+	clientIP := s.ClientIP(r)
 	
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	
@@ -572,14 +739,20 @@ func (s *Server) logAPIRequest(r *http.Request, endpoint string, params map[stri
 	logParams := make(map[string]string)
 	for k, v := range params {
 		if strings.Contains(strings.ToLower(k), "token") && v != "" {
-			logParams[k] = v[:8] + "..."  // Show only first 8 chars
+			logParams[k] = maskToken(v)
 		} else {
 			logParams[k] = v
 		}
 	}
+
+	// 记录请求使用的是哪个 token（例如 redirect/previous），便于在宽限期内找出尚未更新的设备
+	tokenInfo := ""
+	if use, ok := s.identifyRequestToken(r, params); ok {
+		tokenInfo = " | Token: " + use.String()
+	}
 	
-	log.Printf("[API] %s | %s %s | %s | Status: %d | Params: %v | Result: %s", 
-		timestamp, r.Method, endpoint, clientIP, status, logParams, result)
+	log.Printf("[API] %s | %s %s | %s | Status: %d | Params: %v%s | Result: %s", 
+		timestamp, r.Method, endpoint, clientIP, status, logParams, tokenInfo, result)
 }
 
 // API handlers for forwarding management
@@ -589,7 +762,7 @@ func (s *Server) handleListForwardings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminToken := r.URL.Query().Get("admin_token")
+	adminToken := requestAdminToken(r)
 	params := map[string]string{
 		"admin_token": adminToken,
 	}
@@ -603,13 +776,17 @@ func (s *Server) handleListForwardings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查管理员认证
-	if !s.validateAdminToken(adminToken) {
-		s.logAPIRequest(r, "/api/list", params, "unauthorized", http.StatusUnauthorized)
-		s.writeJSONResponse(w, http.StatusUnauthorized, models.Response{
-			State:   "error",
-			Message: "Unauthorized access. Admin token required.",
-		})
+	// 普通用户只能看到自己的条目，管理员可以用 owner 参数按用户过滤
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/list", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+	owner, ok := listOwner(r, actor)
+	if !ok {
+		s.logAPIRequest(r, "/api/list", params, "forbidden_owner", http.StatusForbidden)
+		s.writeAuthError(w, errNotAdmin)
 		return
 	}
 
@@ -632,6 +809,9 @@ func (s *Server) handleListForwardings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	forwardings = filterForwardings(forwardings, owner)
+	s.fillRelayStatus(forwardings...)
+
 	s.logAPIRequest(r, "/api/list", params, fmt.Sprintf("success:%d_items", len(forwardings)), http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -646,7 +826,7 @@ func (s *Server) handleRemoveForwarding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	adminToken := r.URL.Query().Get("admin_token")
+	adminToken := requestAdminToken(r)
 	name := r.URL.Query().Get("name")
 	params := map[string]string{
 		"admin_token": adminToken,
@@ -662,13 +842,11 @@ func (s *Server) handleRemoveForwarding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 检查管理员认证
-	if !s.validateAdminToken(adminToken) {
-		s.logAPIRequest(r, "/api/remove", params, "unauthorized", http.StatusUnauthorized)
-		s.writeJSONResponse(w, http.StatusUnauthorized, models.Response{
-			State:   "error",
-			Message: "Unauthorized access. Admin token required.",
-		})
+	// 普通用户只能删除自己的条目
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/remove", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
 		return
 	}
 
@@ -681,9 +859,9 @@ func (s *Server) handleRemoveForwarding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := s.configStorage.RemoveForwarding(name)
+	expected, err := parseExpectedVersion(r)
 	if err != nil {
-		s.logAPIRequest(r, "/api/remove", params, fmt.Sprintf("error:%s", err.Error()), http.StatusBadRequest)
+		s.logAPIRequest(r, "/api/remove", params, "invalid_precondition", http.StatusBadRequest)
 		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
 			State:   "error",
 			Message: err.Error(),
@@ -691,6 +869,17 @@ func (s *Server) handleRemoveForwarding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	err = s.configStorage.RemoveForwardingWithVersion(&actor, name, expected.version)
+	if err != nil {
+		status := expected.writeStatus(err)
+		s.logAPIRequest(r, "/api/remove", params, fmt.Sprintf("error:%s", err.Error()), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	s.logAPIRequest(r, "/api/remove", params, "success", http.StatusOK)
 	s.writeJSONResponse(w, http.StatusOK, models.Response{
 		State: "success",
@@ -711,13 +900,10 @@ func (s *Server) handleRemoveDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查管理员认证
-	adminToken := r.URL.Query().Get("admin_token")
-	if !s.validateAdminToken(adminToken) {
-		s.writeJSONResponse(w, http.StatusUnauthorized, models.Response{
-			State:   "error",
-			Message: "Unauthorized access. Admin token required.",
-		})
+	// 普通用户只能删除自己的域名映射
+	actor, authErr := s.authenticate(r, false)
+	if authErr != nil {
+		s.writeAuthError(w, authErr)
 		return
 	}
 
@@ -738,7 +924,7 @@ func (s *Server) handleRemoveDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.configStorage.RemoveDomain(domain)
+	expected, err := parseExpectedVersion(r)
 	if err != nil {
 		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
 			State:   "error",
@@ -747,6 +933,15 @@ func (s *Server) handleRemoveDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = s.configStorage.RemoveDomainWithVersion(&actor, domain, expected.version)
+	if err != nil {
+		s.writeJSONResponse(w, expected.writeStatus(err), models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	s.writeJSONResponse(w, http.StatusOK, models.Response{
 		State: "success",
 	})
@@ -756,239 +951,3 @@ func (s *Server) handleRemoveDomain(w http.ResponseWriter, r *http.Request) {
 func (s *Server) generateToken(length int) (string, error) {
 	return utils.GenerateToken(length)
 }
-
-// handleBatchUpdate 处理批量更新请求 (支持 GET 和 POST)
-func (s *Server) handleBatchUpdate(w http.ResponseWriter, r *http.Request) {
-	// 先检查是否为域名跳转
-	if s.checkDomainRedirect(w, r) {
-		return
-	}
-
-	var entries []models.BatchUpdateEntry
-	var redirectToken, domainToken string
-
-	// 根据请求方法解析参数
-	if r.Method == http.MethodGet {
-		// GET 方式: 使用索引后缀 name1=xxx&target1=xxx&domain2=xxx&target2=xxx
-		entries, redirectToken, domainToken = s.parseGetBatchUpdate(r)
-	} else if r.Method == http.MethodPost {
-		// POST 方式: 使用 JSON body
-		var req models.BatchUpdateRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
-				State:   "error",
-				Message: "Invalid JSON body: " + err.Error(),
-			})
-			return
-		}
-		entries = req.Entries
-		redirectToken = req.RedirectToken
-		domainToken = req.DomainToken
-	} else {
-		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
-			State:   "error",
-			Message: "Method not allowed. Use GET or POST",
-		})
-		return
-	}
-
-	// 验证是否有条目
-	if len(entries) == 0 {
-		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
-			State:   "error",
-			Message: "No entries to update",
-		})
-		return
-	}
-
-	// 处理批量更新
-	results := make([]models.BatchUpdateEntryResult, 0, len(entries))
-	succeeded := 0
-	failed := 0
-
-	for _, entry := range entries {
-		result := models.BatchUpdateEntryResult{
-			Name:   entry.Name,
-			Domain: entry.Domain,
-			Target: entry.Target,
-		}
-
-		// 验证条目
-		if entry.Target == "" {
-			result.Success = false
-			result.Error = "Missing target"
-			failed++
-			results = append(results, result)
-			continue
-		}
-
-		// 验证 target 格式
-		if !s.isValidTarget(entry.Target) {
-			result.Success = false
-			result.Error = "Invalid target format"
-			failed++
-			results = append(results, result)
-			continue
-		}
-
-		// 判断是路径重定向还是域名重定向
-		if entry.Name != "" && entry.Domain == "" {
-			// 路径重定向
-			if redirectToken == "" {
-				result.Success = false
-				result.Error = "Missing redirect_token"
-				failed++
-				results = append(results, result)
-				continue
-			}
-
-			err := s.storage.SetTarget(entry.Name, redirectToken, entry.Target)
-			if err != nil {
-				result.Success = false
-				result.Error = err.Error()
-				failed++
-			} else {
-				result.Success = true
-				succeeded++
-			}
-			results = append(results, result)
-		} else if entry.Domain != "" && entry.Name == "" {
-			// 域名重定向
-			if domainToken == "" {
-				result.Success = false
-				result.Error = "Missing domain_token"
-				failed++
-				results = append(results, result)
-				continue
-			}
-
-			if s.domainStorage == nil {
-				result.Success = false
-				result.Error = "Domain storage not available"
-				failed++
-				results = append(results, result)
-				continue
-			}
-
-			err := s.domainStorage.SetDomainTarget(entry.Domain, domainToken, entry.Target)
-			if err != nil {
-				result.Success = false
-				result.Error = err.Error()
-				failed++
-			} else {
-				result.Success = true
-				succeeded++
-			}
-			results = append(results, result)
-		} else {
-			// 同时指定了 name 和 domain，或者都没指定
-			result.Success = false
-			result.Error = "Must specify either name or domain, not both or neither"
-			failed++
-			results = append(results, result)
-		}
-	}
-
-	// 构建响应
-	response := models.BatchUpdateResponse{
-		State:   "success",
-		Results: results,
-		Summary: models.BatchUpdateSummary{
-			Total:     len(entries),
-			Succeeded: succeeded,
-			Failed:    failed,
-		},
-	}
-
-	// 如果全部失败，设置状态为 error
-	if failed == len(entries) {
-		response.State = "error"
-		response.Message = "All entries failed to update"
-	} else if failed > 0 {
-		response.State = "partial"
-		response.Message = fmt.Sprintf("%d succeeded, %d failed", succeeded, failed)
-	} else {
-		response.Message = "All entries updated successfully"
-	}
-
-	// 记录日志
-	s.logAPIRequest(r, "/api/batch-update", map[string]string{
-		"method": r.Method,
-		"total":  fmt.Sprintf("%d", len(entries)),
-	}, response.State, http.StatusOK)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
-// parseGetBatchUpdate 解析 GET 请求的批量更新参数
-// 格式: name1=xxx&target1=xxx&name2=xxx&target2=xxx&domain3=xxx&target3=xxx
-// 返回: entries, redirectToken, domainToken
-func (s *Server) parseGetBatchUpdate(r *http.Request) ([]models.BatchUpdateEntry, string, string) {
-	query := r.URL.Query()
-	entries := make([]models.BatchUpdateEntry, 0)
-
-	// 获取 token
-	redirectToken := query.Get("redirect_token")
-	domainToken := query.Get("domain_token")
-
-	// 查找所有的索引
-	indexMap := make(map[string]bool)
-	for key := range query {
-		// 提取数字后缀
-		if len(key) > 0 {
-			var idx string
-			var prefix string
-
-			// 检查是否匹配 nameXXX 或 domainXXX 或 targetXXX
-			if strings.HasPrefix(key, "name") && len(key) > 4 {
-				idx = key[4:]
-				prefix = "name"
-			} else if strings.HasPrefix(key, "domain") && len(key) > 6 {
-				idx = key[6:]
-				prefix = "domain"
-			} else if strings.HasPrefix(key, "target") && len(key) > 6 {
-				idx = key[6:]
-				prefix = "target"
-			}
-
-			// 验证 idx 是否全为数字
-			if idx != "" && isNumeric(idx) {
-				indexMap[idx] = true
-			}
-			_ = prefix // 避免未使用警告
-		}
-	}
-
-	// 按索引提取条目
-	for idx := range indexMap {
-		name := query.Get("name" + idx)
-		domain := query.Get("domain" + idx)
-		target := query.Get("target" + idx)
-
-		// 只有当 target 存在时才添加条目
-		if target != "" && (name != "" || domain != "") {
-			entries = append(entries, models.BatchUpdateEntry{
-				Name:   name,
-				Domain: domain,
-				Target: target,
-			})
-		}
-	}
-
-	return entries, redirectToken, domainToken
-}
-
-// isNumeric 检查字符串是否全为数字
-func isNumeric(s string) bool {
-	if s == "" {
-		return false
-	}
-	for _, c := range s {
-		if c < '0' || c > '9' {
-			return false
-		}
-	}
-	return true
-}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/session"
+)
+
+const (
+	sessionCookieName = "redirect_helper_session"
+	// csrfHeader 通过会话认证的写请求必须携带登录时返回的 CSRF token
+	csrfHeader = "X-CSRF-Token"
+	// maxLoginBody 登录请求体大小上限
+	maxLoginBody = 64 << 10
+)
+
+// loginRequest 登录请求：admin token 或用户名密码二选一
+type loginRequest struct {
+	AdminToken string `json:"admin_token,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+}
+
+// sessionResponse 登录和查询会话的响应
+type sessionResponse struct {
+	State     string    `json:"state"`
+	Subject   string    `json:"subject"`
+	Admin     bool      `json:"admin"`
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleLogin 用 admin token 或用户名密码换取 HttpOnly 会话 cookie
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	var req loginRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLoginBody)).Decode(&req); err != nil {
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Invalid JSON body: " + err.Error(),
+		})
+		return
+	}
+
+	params := map[string]string{"method": "token"}
+	subject := ""
+	var actor config.Actor
+	switch {
+	case req.AdminToken != "":
+		if s.validateAdminToken(req.AdminToken) {
+			subject, actor = "admin", config.Actor{Admin: true}
+		}
+	case req.Username != "":
+		params = map[string]string{"method": "password", "username": req.Username}
+		if s.configStorage != nil {
+			if authenticated, ok := s.configStorage.AuthenticatePassword(req.Username, req.Password); ok {
+				subject, actor = req.Username, authenticated
+			}
+		}
+	}
+
+	if subject == "" {
+		s.logAPIRequest(r, "/api/login", params, "invalid_credentials", http.StatusUnauthorized)
+		s.writeJSONResponse(w, http.StatusUnauthorized, models.Response{
+			State:   "error",
+			Message: "Invalid credentials",
+		})
+		return
+	}
+
+	sess, err := s.sessions.Create(subject, actor.User)
+	if err != nil {
+		s.logAPIRequest(r, "/api/login", params, "error:"+err.Error(), http.StatusInternalServerError)
+		s.writeJSONResponse(w, http.StatusInternalServerError, models.Response{
+			State:   "error",
+			Message: "Failed to create session",
+		})
+		return
+	}
+
+	s.setSessionCookie(w, r, sess.ID, sess.ExpiresAt)
+	s.logAPIRequest(r, "/api/login", params, "success", http.StatusOK)
+	writeSessionResponse(w, sess, actor)
+}
+
+// handleSession 返回当前会话的信息和 CSRF token，页面刷新后用于恢复登录状态
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	sess, ok := s.sessionFromRequest(r)
+	var actor config.Actor
+	if ok {
+		actor, ok = s.sessionActor(sess)
+	}
+	if !ok {
+		s.writeJSONResponse(w, http.StatusUnauthorized, models.Response{
+			State:   "error",
+			Message: "Not logged in",
+		})
+		return
+	}
+
+	writeSessionResponse(w, sess, actor)
+}
+
+// handleLogout 撤销当前会话并清除 cookie
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	if sess, ok := s.sessionFromRequest(r); ok {
+		// 防止第三方页面强制用户退出
+		if !sess.ValidCSRF(r.Header.Get(csrfHeader)) {
+			s.writeAuthError(w, errInvalidCSRF)
+			return
+		}
+		s.sessions.Revoke(sess.ID)
+		s.logAPIRequest(r, "/api/logout", map[string]string{"subject": sess.Subject}, "success", http.StatusOK)
+	}
+
+	s.setSessionCookie(w, r, "", time.Unix(0, 0))
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State: "success",
+	})
+}
+
+func writeSessionResponse(w http.ResponseWriter, sess *session.Session, actor config.Actor) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(sessionResponse{
+		State:     "success",
+		Subject:   sess.Subject,
+		Admin:     actor.Admin,
+		CSRFToken: sess.CSRFToken,
+		ExpiresAt: sess.ExpiresAt,
+	})
+}
+
+// setSessionCookie 设置会话 cookie；value 为空时删除 cookie
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     s.basePath + "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// isSecureRequest 请求是否经由 HTTPS 到达（直接 TLS 或可信代理声明的 X-Forwarded-Proto）
+func (s *Server) isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	remote, ok := parseHostAddr(r.RemoteAddr)
+	return ok && s.isTrustedProxy(remote) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// sessionFromRequest 根据 cookie 查找有效会话
+func (s *Server) sessionFromRequest(r *http.Request) (*session.Session, bool) {
+	if s.sessions == nil {
+		return nil, false
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, false
+	}
+	return s.sessions.Get(cookie.Value)
+}
+
+// authError 认证失败的状态码和提示
+type authError struct {
+	status  int
+	message string
+}
+
+var (
+	errUnauthorized = &authError{http.StatusUnauthorized, "Unauthorized access. Admin token required."}
+	errInvalidCSRF  = &authError{http.StatusForbidden, "Invalid or missing CSRF token"}
+	errNotAdmin     = &authError{http.StatusForbidden, "Admin privileges required"}
+)
+
+// authenticate 识别请求者：admin token 或用户 API key（admin_token/api_key 参数或 Bearer），否则使用会话 cookie
+// 通过会话认证的写请求（write 为 true 或非 GET/HEAD 请求）还必须携带正确的 X-CSRF-Token
+func (s *Server) authenticate(r *http.Request, write bool) (config.Actor, *authError) {
+	token := requestAdminToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("api_key")
+	}
+	if token != "" {
+		if s.validateAdminToken(token) {
+			return config.Actor{Admin: true}, nil
+		}
+		if s.configStorage != nil {
+			if actor, ok := s.configStorage.AuthenticateAPIKey(token); ok {
+				return actor, nil
+			}
+		}
+		return config.Actor{}, errUnauthorized
+	}
+
+	sess, ok := s.sessionFromRequest(r)
+	if !ok {
+		return config.Actor{}, errUnauthorized
+	}
+	actor, ok := s.sessionActor(sess)
+	if !ok {
+		return config.Actor{}, errUnauthorized
+	}
+	if write || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		if !sess.ValidCSRF(r.Header.Get(csrfHeader)) {
+			return config.Actor{}, errInvalidCSRF
+		}
+	}
+	return actor, nil
+}
+
+// adminAuth 与 authenticate 相同，但只允许管理员
+func (s *Server) adminAuth(r *http.Request, write bool) *authError {
+	actor, authErr := s.authenticate(r, write)
+	if authErr != nil {
+		return authErr
+	}
+	if !actor.Admin {
+		return errNotAdmin
+	}
+	return nil
+}
+
+// sessionActor 会话对应的当前身份：用户被删除或禁用后会话随之失效
+func (s *Server) sessionActor(sess *session.Session) (config.Actor, bool) {
+	if sess.User == "" {
+		return config.Actor{Admin: true}, true
+	}
+	if s.configStorage == nil {
+		return config.Actor{}, false
+	}
+	return s.configStorage.LookupActor(sess.User)
+}
+
+// hasCredentials 请求是否携带了任何认证信息
+func (s *Server) hasCredentials(r *http.Request) bool {
+	if requestAdminToken(r) != "" || r.URL.Query().Get("api_key") != "" {
+		return true
+	}
+	_, ok := s.sessionFromRequest(r)
+	return ok
+}
+
+// writeAuthError 写入认证失败的响应
+func (s *Server) writeAuthError(w http.ResponseWriter, authErr *authError) {
+	s.writeJSONResponse(w, authErr.status, models.Response{
+		State:   "error",
+		Message: authErr.message,
+	})
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/relay"
+)
+
+// defaultSNIListen 未配置 sni.listen 时的监听地址
+const defaultSNIListen = ":443"
+
+// startSNI 打开 TLS 透传监听器；与内置 HTTPS 使用同一端口时，HTTPS 服务改为从透传监听器接收本地终止的连接
+func (s *Server) startSNI(sniConfig *config.SNIConfig, tlsConfig *config.TLSConfig) error {
+	listen := sniConfig.Listen
+	if listen == "" {
+		listen = defaultSNIListen
+	}
+	if sniConfig.Default != "" {
+		if _, _, err := net.SplitHostPort(sniConfig.Default); err != nil {
+			return fmt.Errorf("invalid sni.default %q, expected host:port", sniConfig.Default)
+		}
+	}
+
+	opts := relay.SNIOptions{
+		Listen:         listen,
+		MaxConnections: sniConfig.MaxConnections,
+		IdleTimeout:    time.Duration(sniConfig.IdleTimeout) * time.Second,
+		Route:          s.sniRoute,
+		Default:        sniConfig.Default,
+	}
+
+	if tlsConfig != nil && tlsConfig.Enabled && samePort(listen, tlsListenPort(tlsConfig)) {
+		s.tlsHandoff = newHandoffListener()
+		opts.Fallback = s.tlsHandoff.deliver
+	}
+
+	proxy, err := relay.ListenSNI(opts)
+	if err != nil {
+		return fmt.Errorf("failed to start SNI listener: %v", err)
+	}
+	if s.tlsHandoff != nil {
+		s.tlsHandoff.addr = proxy.Addr()
+	}
+	s.sniProxy = proxy
+	log.Printf("SNI passthrough listener started on %s", listen)
+	return nil
+}
+
+// sniRoute 目标为 host:port 且已生效的域名映射透传到该目标；其它已生效的域名映射由本服务终止 TLS 后跳转
+func (s *Server) sniRoute(serverName string) (string, bool) {
+	if s.configStorage == nil {
+		return "", false
+	}
+	entry, err := s.configStorage.GetDomain(serverName)
+	if err != nil || (entry.Verification != nil && entry.Verification.Status != config.VerificationVerified) {
+		return "", false
+	}
+	if !strings.Contains(entry.Target, "://") {
+		if _, _, err := net.SplitHostPort(entry.Target); err == nil {
+			return entry.Target, false
+		}
+	}
+	return "", true
+}
+
+// tlsListenPort 内置 HTTPS 的端口，默认 443
+func tlsListenPort(tlsConfig *config.TLSConfig) string {
+	if tlsConfig.Port == "" {
+		return "443"
+	}
+	return tlsConfig.Port
+}
+
+// samePort listen 地址的端口是否为 port
+func samePort(listen, port string) bool {
+	_, listenPort, err := net.SplitHostPort(listen)
+	return err == nil && listenPort == port
+}
+
+// handoffListener 把透传监听器交出的连接提供给内置 HTTPS 服务
+type handoffListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newHandoffListener() *handoffListener {
+	return &handoffListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// deliver 等待 HTTPS 服务接收连接，监听器已关闭时关闭连接
+func (l *handoffListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *handoffListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *handoffListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *handoffListener) Addr() net.Addr {
+	return l.addr
+}
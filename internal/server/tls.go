@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"redirect_helper/internal/config"
+)
+
+// setupTLS 根据配置加载证书文件并初始化 ACME 管理器
+func (s *Server) setupTLS(tlsConfig *config.TLSConfig) error {
+	if tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		s.fileCert = &cert
+	}
+
+	if tlsConfig.ACME {
+		client := &acme.Client{DirectoryURL: tlsConfig.ACMEDirectoryURL}
+		if client.DirectoryURL == "" {
+			client.DirectoryURL = autocert.DefaultACMEDirectory
+		}
+
+		// 测试环境（如 Pebble）的 ACME 服务使用自签名证书
+		if tlsConfig.ACMECARoot != "" {
+			pem, err := os.ReadFile(tlsConfig.ACMECARoot)
+			if err != nil {
+				return fmt.Errorf("failed to read ACME CA root: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in ACME CA root %s", tlsConfig.ACMECARoot)
+			}
+			client.HTTPClient = &http.Client{
+				Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+			}
+		}
+
+		cacheDir := tlsConfig.ACMECacheDir
+		if cacheDir == "" {
+			// 默认与配置文件放在同一目录，便于 Docker 卷持久化
+			cacheDir = filepath.Join(filepath.Dir(config.GetConfigPath()), "certs")
+		}
+
+		s.acmeManager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cacheDir),
+			HostPolicy: s.acmeHostPolicy,
+			Client:     client,
+			Email:      tlsConfig.ACMEEmail,
+		}
+	}
+
+	if s.fileCert == nil && s.acmeManager == nil {
+		return fmt.Errorf("TLS enabled but neither certificate files nor ACME configured")
+	}
+
+	s.httpsRedirect = tlsConfig.RedirectHTTP
+	s.httpsPort = tlsConfig.Port
+	return nil
+}
+
+// acmeHostPolicy 只为 Config.Domains 中已配置且通过所有权验证的域名申请证书
+func (s *Server) acmeHostPolicy(_ context.Context, host string) error {
+	if s.domainStorage == nil {
+		return fmt.Errorf("acme: domain storage not available")
+	}
+	entry, err := s.domainStorage.GetDomain(host)
+	if err != nil {
+		return fmt.Errorf("acme: host %q is not a configured domain", host)
+	}
+	if entry.Verification != nil && entry.Verification.Status != config.VerificationVerified {
+		return fmt.Errorf("acme: host %q has not been verified", host)
+	}
+	return nil
+}
+
+// getCertificate 为已配置的域名使用 ACME 证书，其它请求使用证书文件
+func (s *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if s.acmeManager != nil && hello.ServerName != "" {
+		if err := s.acmeHostPolicy(hello.Context(), hello.ServerName); err == nil {
+			return s.acmeManager.GetCertificate(hello)
+		}
+	}
+
+	if s.fileCert != nil {
+		return s.fileCert, nil
+	}
+
+	return nil, fmt.Errorf("no certificate available for %q", hello.ServerName)
+}
+
+// tlsConfig 构建 HTTPS 监听器使用的 tls.Config
+func (s *Server) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: s.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// httpHandler 包装明文 HTTP 监听器的处理器：处理 ACME HTTP-01 验证并按需升级到 HTTPS
+func (s *Server) httpHandler(next http.Handler) http.Handler {
+	handler := next
+	if s.httpsRedirect {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+
+			// IP 访问没有可用证书，保持明文
+			if net.ParseIP(strings.Trim(host, "[]")) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if s.httpsPort != "" && s.httpsPort != "443" {
+				host = net.JoinHostPort(host, s.httpsPort)
+			}
+
+			target := "https://" + host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		})
+	}
+
+	if s.acmeManager != nil {
+		// HTTPHandler 只拦截 /.well-known/acme-challenge/ 路径
+		return s.acmeManager.HTTPHandler(handler)
+	}
+	return handler
+}
+
+// startTLS 启动 HTTPS 监听器（阻塞运行）；与 SNI 透传共用端口时从透传监听器接收连接
+func (s *Server) startTLS(srv *http.Server, addr string) error {
+	if s.tlsHandoff != nil {
+		log.Printf("HTTPS listener sharing the SNI passthrough port %s", addr)
+		return srv.ServeTLS(s.tlsHandoff, "", "")
+	}
+
+	listener, err := s.listen(addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("HTTPS listener started on %s", addr)
+	return srv.ServeTLS(listener, "", "")
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles 管理界面的静态资源，编译进二进制文件
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// contentSecurityPolicy 管理界面只加载本站的脚本和样式，不允许内联脚本
+const contentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self' data:; " +
+	"connect-src 'self'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+
+// setUISecurityHeaders 为管理界面的响应设置 CSP 等安全头
+func setUISecurityHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+}
+
+// setupUIRoutes 注册管理界面的静态资源
+func (s *Server) setupUIRoutes(mux *http.ServeMux) {
+	assets, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.StripPrefix("/assets/", http.FileServer(http.FS(assets)))
+
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		// 先检查是否为域名跳转
+		if s.checkDomainRedirect(w, r) {
+			return
+		}
+
+		setUISecurityHeaders(w)
+		w.Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// handleIndex 返回管理界面页面
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	page, err := uiFiles.ReadFile("ui/index.html")
+	if err != nil {
+		http.Error(w, "Admin UI not available", http.StatusInternalServerError)
+		return
+	}
+
+	setUISecurityHeaders(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(page)
+}
@@ -0,0 +1,528 @@
+'use strict';
+
+// Redirect Helper admin UI
+// All user-controlled values are rendered with textContent, never as HTML.
+(function () {
+    const kinds = {
+        forwardings: {
+            key: 'name',
+            label: 'Name',
+            title: 'path redirect',
+            list: 'api/list',
+            remove: 'api/remove',
+            renameField: 'new_name',
+        },
+        domains: {
+            key: 'domain',
+            label: 'Domain',
+            title: 'domain redirect',
+            list: 'api/list-domains',
+            remove: 'api/remove-domain',
+            renameField: 'new_domain',
+        },
+    };
+
+    const state = {
+        // The session itself lives in an HttpOnly cookie; only the CSRF token is visible to scripts.
+        csrf: '',
+        // Regular users only manage their own entries; stats and tokens are admin-only.
+        admin: false,
+        tab: 'forwardings',
+        data: { forwardings: [], domains: [] },
+        sort: {
+            forwardings: { key: 'name', asc: true },
+            domains: { key: 'domain', asc: true },
+        },
+        editing: null,
+    };
+
+    function $(id) {
+        return document.getElementById(id);
+    }
+
+    // el creates an element; text is always assigned through textContent.
+    function el(tag, props, children) {
+        const node = document.createElement(tag);
+        Object.entries(props || {}).forEach(([name, value]) => {
+            if (name === 'text') {
+                node.textContent = value;
+            } else if (name === 'class') {
+                node.className = value;
+            } else if (name.startsWith('on')) {
+                node.addEventListener(name.slice(2), value);
+            } else {
+                node.setAttribute(name, value);
+            }
+        });
+        (children || []).forEach((child) => node.append(child));
+        return node;
+    }
+
+    async function api(path, options) {
+        options = options || {};
+        const method = options.method || 'GET';
+        const headers = {};
+        if (method !== 'GET' && state.csrf) {
+            headers['X-CSRF-Token'] = state.csrf;
+        }
+        let body;
+        if (options.json !== undefined) {
+            headers['Content-Type'] = 'application/json';
+            body = JSON.stringify(options.json);
+        }
+
+        const resp = await fetch(path, {
+            method: method,
+            headers: headers,
+            body: body,
+            cache: 'no-store',
+            credentials: 'same-origin',
+        });
+        let data = {};
+        try {
+            data = await resp.json();
+        } catch (e) {
+            // non-JSON response, fall through to the status check
+        }
+
+        if (resp.status === 401 && !options.anonymous) {
+            showLogin('Session expired, please log in again');
+            throw new Error(data.message || 'Unauthorized');
+        }
+        if (!resp.ok || data.state === 'error') {
+            const result = data.results && data.results.find((r) => r.error);
+            throw new Error((result && result.error) || data.message || 'HTTP ' + resp.status);
+        }
+        return data;
+    }
+
+    function showMessage(text, kind) {
+        const box = $('message');
+        box.textContent = text;
+        box.className = 'message ' + (kind || 'success');
+        box.hidden = false;
+    }
+
+    function clearMessage() {
+        $('message').hidden = true;
+    }
+
+    // Login / logout
+
+    async function login(username, secret) {
+        const credentials = username ? { username: username, password: secret } : { admin_token: secret };
+        const data = await api('api/login', { method: 'POST', json: credentials, anonymous: true });
+        await enter(data);
+    }
+
+    // resume picks up an existing session cookie after a page reload.
+    async function resume() {
+        try {
+            await enter(await api('api/session', { anonymous: true }));
+        } catch (e) {
+            showLogin();
+        }
+    }
+
+    async function enter(session) {
+        state.csrf = session.csrf_token;
+        state.admin = session.admin;
+        $('stats').hidden = !state.admin;
+        document.querySelectorAll('.admin-only').forEach((node) => {
+            node.hidden = !state.admin;
+        });
+        $('login-view').hidden = true;
+        $('app-view').hidden = false;
+        $('logout').hidden = false;
+        await refresh();
+    }
+
+    async function logout() {
+        try {
+            await api('api/logout', { method: 'POST', anonymous: true });
+        } catch (e) {
+            // the session is gone either way
+        }
+        showLogin();
+    }
+
+    function showLogin(reason) {
+        state.csrf = '';
+        $('app-view').hidden = true;
+        $('logout').hidden = true;
+        $('login-view').hidden = false;
+        $('login-token').value = '';
+        const error = $('login-error');
+        error.textContent = reason || '';
+        error.hidden = !reason;
+    }
+
+    // Data loading and rendering
+
+    async function refresh() {
+        await Promise.all([loadStats(), loadEntries('forwardings'), loadEntries('domains')]);
+        if (state.tab === 'tokens') {
+            await loadTokens();
+        }
+    }
+
+    async function loadStats() {
+        if (!state.admin) {
+            return;
+        }
+        const stats = (await api('api/stats')).stats;
+        const items = [
+            ['Path redirects', stats.forwardings + ' / ' + stats.max_redirect_count],
+            ['Domain redirects', stats.domains + ' / ' + stats.max_domain_count],
+            ['Without target', String(stats.unconfigured), stats.unconfigured > 0],
+            ['Uptime', formatDuration(stats.uptime_seconds)],
+            ['Health', stats.health.status, stats.health.status !== 'ok'],
+        ];
+        if (stats.webhook_subscriptions) {
+            items.push(['Webhooks pending', String(stats.webhook_pending), stats.webhook_pending > 0]);
+        }
+
+        $('stats').replaceChildren(...items.map(([label, value, bad]) => el('div', { class: 'stat' }, [
+            el('div', { class: 'stat-label', text: label }),
+            el('div', { class: 'stat-value' + (bad ? ' bad' : ''), text: value }),
+        ])));
+    }
+
+    async function loadEntries(kind) {
+        const data = await api(kinds[kind].list);
+        state.data[kind] = data[kind] || [];
+        renderEntries(kind);
+    }
+
+    function renderEntries(kind) {
+        const spec = kinds[kind];
+        const sort = state.sort[kind];
+        const query = $(kind + '-search').value.trim().toLowerCase();
+
+        const entries = state.data[kind]
+            .filter((entry) => !query ||
+                entry[spec.key].toLowerCase().includes(query) ||
+                (entry.target || '').toLowerCase().includes(query))
+            .sort((a, b) => {
+                const x = a[sort.key];
+                const y = b[sort.key];
+                const order = typeof x === 'number' ? x - y : String(x || '').localeCompare(String(y || ''));
+                return sort.asc ? order : -order;
+            });
+
+        document.querySelectorAll('#tab-' + kind + ' th[data-sort]').forEach((th) => {
+            th.classList.toggle('sorted-asc', th.dataset.sort === sort.key && sort.asc);
+            th.classList.toggle('sorted-desc', th.dataset.sort === sort.key && !sort.asc);
+        });
+
+        const rows = entries.map((entry) => el('tr', {}, [
+            nameCell(entry, spec),
+            entry.target
+                ? el('td', { class: 'target', text: entry.target })
+                : el('td', { class: 'target muted', text: 'not configured' }),
+            el('td', { text: entry.owner || '' }),
+            el('td', { text: String(entry.version) }),
+            el('td', { text: new Date(entry.updated_at).toLocaleString() }),
+            el('td', { class: 'actions' }, [
+                unverified(entry)
+                    ? el('button', { class: 'btn btn-secondary btn-small', type: 'button', text: 'Verify', onclick: () => verifyDomain(entry) })
+                    : null,
+                entry.wake
+                    ? el('button', { class: 'btn btn-secondary btn-small', type: 'button', text: 'Wake', onclick: () => wakeEntry(kind, entry) })
+                    : null,
+                el('button', { class: 'btn btn-secondary btn-small', type: 'button', text: 'Edit', onclick: () => openEditor(kind, entry) }),
+                el('button', { class: 'btn btn-danger btn-small', type: 'button', text: 'Delete', onclick: () => removeEntry(kind, entry) }),
+            ].filter(Boolean)),
+        ]));
+        if (rows.length === 0) {
+            rows.push(el('tr', {}, [el('td', { class: 'muted', colspan: '6', text: query ? 'No matches' : 'No entries' })]));
+        }
+        $(kind + '-body').replaceChildren(...rows);
+    }
+
+    // Create / edit / delete
+
+    function openEditor(kind, entry) {
+        const spec = kinds[kind];
+        state.editing = { kind: kind, entry: entry };
+        $('edit-title').textContent = (entry ? 'Edit ' : 'New ') + spec.title;
+        $('edit-key-label').textContent = spec.label;
+        $('edit-key').value = entry ? entry[spec.key] : '';
+        $('edit-target').value = entry ? entry.target : '';
+        $('edit-error').hidden = true;
+        $('edit-dialog').showModal();
+    }
+
+    async function saveEditor(event) {
+        event.preventDefault();
+        const kind = state.editing.kind;
+        const entry = state.editing.entry;
+        const spec = kinds[kind];
+        const key = $('edit-key').value.trim();
+        const target = $('edit-target').value.trim();
+
+        const op = { target: target };
+        if (entry) {
+            // expected_version rejects the write if someone else changed the entry meanwhile
+            op[spec.key] = entry[spec.key];
+            op.expected_version = entry.version;
+            if (key !== entry[spec.key]) {
+                op.op = 'rename';
+                op[spec.renameField] = key;
+            }
+        } else {
+            op[spec.key] = key;
+        }
+
+        try {
+            await api('api/batch-update', { method: 'POST', json: { atomic: true, entries: [op] } });
+        } catch (e) {
+            const error = $('edit-error');
+            error.textContent = e.message;
+            error.hidden = false;
+            return;
+        }
+
+        $('edit-dialog').close();
+        showMessage((entry ? 'Updated ' : 'Created ') + key);
+        await Promise.all([loadStats(), loadEntries(kind)]);
+    }
+
+    async function removeEntry(kind, entry) {
+        const spec = kinds[kind];
+        const key = entry[spec.key];
+        if (!window.confirm('Delete ' + key + '?')) {
+            return;
+        }
+
+        const params = new URLSearchParams();
+        params.set(spec.key, key);
+        params.set('expected_version', String(entry.version));
+        try {
+            await api(spec.remove + '?' + params.toString(), { method: 'DELETE' });
+            showMessage('Deleted ' + key);
+        } catch (e) {
+            showMessage(e.message, 'error');
+        }
+        await Promise.all([loadStats(), loadEntries(kind)]);
+    }
+
+    function unverified(entry) {
+        return entry.verification && entry.verification.status !== 'verified';
+    }
+
+    // Domains waiting for ownership verification show the challenge to publish;
+    // forwardings with a TCP/UDP relay show the port and open connections;
+    // entries with Wake-on-LAN show the MAC address that gets woken, and
+    // forwardings with a preview page, a password or a use limit say so, and
+    // entries with routing rules show how many there are.
+    function nameCell(entry, spec) {
+        const cell = el('td', { text: entry[spec.key] });
+        if (unverified(entry)) {
+            const v = entry.verification;
+            let text = 'Verification ' + v.status + ': TXT ' + v.dns_record + ' = ' + v.challenge;
+            if (v.last_error) {
+                text += ' (' + v.attempts + ' attempts, last error: ' + v.last_error + ')';
+            }
+            cell.append(el('div', { class: 'muted', text: text }));
+        }
+        if (entry.relay) {
+            const r = entry.relay;
+            let text = 'Relay ' + r.listen + (r.udp ? ' (tcp/udp)' : ' (tcp)');
+            text += r.listening ? ', ' + r.active_connections + ' open' : ', ' + (r.error || 'not listening');
+            cell.append(el('div', { class: 'muted', text: text }));
+        }
+        if (entry.wake) {
+            cell.append(el('div', { class: 'muted', text: 'Wake-on-LAN ' + entry.wake.mac }));
+        }
+        if (entry.preview) {
+            const p = entry.preview;
+            cell.append(el('div', { class: 'muted', text: 'Preview page' + (p.countdown ? ', continues after ' + p.countdown + 's' : '') }));
+        }
+        const access = [];
+        if (entry.has_password) {
+            access.push('Password protected');
+        }
+        if (entry.max_uses) {
+            access.push((entry.uses || 0) + '/' + entry.max_uses + ' uses');
+        }
+        if (access.length) {
+            cell.append(el('div', { class: 'muted', text: access.join(', ') }));
+        }
+        if (entry.routes && entry.routes.length) {
+            const n = entry.routes.length;
+            cell.append(el('div', { class: 'muted', text: n + (n === 1 ? ' routing rule' : ' routing rules') }));
+        }
+        if (entry.path_rules && entry.path_rules.length) {
+            const n = entry.path_rules.length;
+            cell.append(el('div', { class: 'muted', text: n + (n === 1 ? ' path rule' : ' path rules') }));
+        }
+        return cell;
+    }
+
+    async function wakeEntry(kind, entry) {
+        const spec = kinds[kind];
+        const key = entry[spec.key];
+        try {
+            await api('api/wake?' + spec.key + '=' + encodeURIComponent(key), { method: 'POST' });
+            showMessage('Magic packet sent to ' + key);
+        } catch (e) {
+            showMessage(e.message, 'error');
+        }
+    }
+
+    async function verifyDomain(entry) {
+        try {
+            const data = await api('api/verify-domain?domain=' + encodeURIComponent(entry.domain), { method: 'POST' });
+            const v = data.verification;
+            showMessage(v.status === 'verified'
+                ? entry.domain + ' verified'
+                : entry.domain + ' not verified yet: ' + (v.last_error || v.status), v.status === 'verified' ? undefined : 'error');
+            await loadEntries('domains');
+        } catch (e) {
+            showMessage(e.message, 'error');
+        }
+    }
+
+    // Tokens
+
+    async function loadTokens() {
+        const data = await api('api/tokens');
+        const retired = {};
+        (data.retired_tokens || []).forEach((t) => { retired[t.type] = t.expires_at; });
+        const rows = [
+            ['Redirect token', 'redirect', data.redirect_token],
+            ['Domain token', 'domain', data.domain_token],
+            ['Admin token', 'admin', null],
+        ].map(([label, type, value]) => {
+            const valueCell = el('td');
+            if (value === null) {
+                valueCell.append(el('span', { class: 'muted', text: 'hidden' }));
+            } else {
+                const code = el('code', { text: '••••••••' });
+                let visible = false;
+                valueCell.append(code, el('button', {
+                    class: 'btn btn-secondary btn-small',
+                    type: 'button',
+                    text: 'Show',
+                    onclick: (e) => {
+                        visible = !visible;
+                        code.textContent = visible ? value : '••••••••';
+                        e.target.textContent = visible ? 'Hide' : 'Show';
+                    },
+                }));
+            }
+            if (retired[type]) {
+                valueCell.append(el('div', { class: 'muted', text: 'Previous token valid until ' + new Date(retired[type]).toLocaleString() }));
+            }
+            return el('tr', {}, [
+                el('td', { text: label }),
+                valueCell,
+                el('td', { class: 'actions' }, [
+                    el('button', { class: 'btn btn-secondary btn-small', type: 'button', text: 'Rotate', onclick: () => rotateToken(type) }),
+                    el('button', { class: 'btn btn-danger btn-small', type: 'button', text: 'Reset', onclick: () => resetToken(type) }),
+                ]),
+            ]);
+        });
+        $('tokens-body').replaceChildren(...rows);
+    }
+
+    async function resetToken(type) {
+        if (!window.confirm('Reset the ' + type + ' token? The old token stops working immediately.')) {
+            return;
+        }
+
+        try {
+            const data = await api('api/tokens/reset?type=' + encodeURIComponent(type), { method: 'POST' });
+            if (type === 'admin') {
+                // resetting the admin token revokes every session, including this one
+                showLogin('Admin token reset, all sessions were logged out. New admin token: ' + data.token);
+                return;
+            }
+            showMessage('New ' + type + ' token: ' + data.token + ' (save it now)');
+            await loadTokens();
+        } catch (e) {
+            showMessage(e.message, 'error');
+        }
+    }
+
+    async function rotateToken(type) {
+        const grace = window.prompt('Rotate the ' + type + ' token. How long should the old token stay valid? (e.g. 30m, 24h, 168h)', '24h');
+        if (grace === null) {
+            return;
+        }
+
+        try {
+            const data = await api('api/tokens/rotate?type=' + encodeURIComponent(type) + '&grace=' + encodeURIComponent(grace.trim()), { method: 'POST' });
+            let message = 'New ' + type + ' token: ' + data.token + ' (save it now)';
+            if (data.previous_expires_at) {
+                message += '. The old token stays valid until ' + new Date(data.previous_expires_at).toLocaleString();
+            }
+            showMessage(message);
+            await loadTokens();
+        } catch (e) {
+            showMessage(e.message, 'error');
+        }
+    }
+
+    // Helpers
+
+    function formatDuration(seconds) {
+        const days = Math.floor(seconds / 86400);
+        const hours = Math.floor((seconds % 86400) / 3600);
+        const minutes = Math.floor((seconds % 3600) / 60);
+        if (days > 0) {
+            return days + 'd ' + hours + 'h';
+        }
+        if (hours > 0) {
+            return hours + 'h ' + minutes + 'm';
+        }
+        return minutes + 'm';
+    }
+
+    function switchTab(tab) {
+        state.tab = tab;
+        clearMessage();
+        document.querySelectorAll('.tab').forEach((button) => {
+            button.classList.toggle('active', button.dataset.tab === tab);
+        });
+        ['forwardings', 'domains', 'tokens'].forEach((name) => {
+            $('tab-' + name).hidden = name !== tab;
+        });
+        if (tab === 'tokens') {
+            loadTokens().catch((e) => showMessage(e.message, 'error'));
+        }
+    }
+
+    // Wiring
+
+    document.addEventListener('DOMContentLoaded', () => {
+        $('login-form').addEventListener('submit', (event) => {
+            event.preventDefault();
+            login($('login-username').value.trim(), $('login-token').value.trim()).catch((e) => showLogin(e.message));
+        });
+        $('logout').addEventListener('click', () => logout());
+
+        document.querySelectorAll('.tab').forEach((button) => {
+            button.addEventListener('click', () => switchTab(button.dataset.tab));
+        });
+
+        Object.keys(kinds).forEach((kind) => {
+            $(kind + '-search').addEventListener('input', () => renderEntries(kind));
+            $(kind + '-new').addEventListener('click', () => openEditor(kind, null));
+            document.querySelectorAll('#tab-' + kind + ' th[data-sort]').forEach((th) => {
+                th.addEventListener('click', () => {
+                    const sort = state.sort[kind];
+                    sort.asc = sort.key === th.dataset.sort ? !sort.asc : true;
+                    sort.key = th.dataset.sort;
+                    renderEntries(kind);
+                });
+            });
+        });
+
+        $('edit-form').addEventListener('submit', saveEditor);
+        $('edit-cancel').addEventListener('click', () => $('edit-dialog').close());
+
+        resume();
+    });
+})();
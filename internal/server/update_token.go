@@ -0,0 +1,243 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+// handleUpdateToken 生成（POST）或删除（DELETE）条目自己的更新 token，只有条目所属用户和管理员可以操作
+// 参数 name 或 domain 二选一；生成的 token 只在响应中出现一次
+func (s *Server) handleUpdateToken(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	domain := r.URL.Query().Get("domain")
+	params := map[string]string{
+		"name":   name,
+		"domain": domain,
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		s.logAPIRequest(r, "/api/update-token", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed. Use POST to rotate or DELETE to revoke",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/update-token", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	if (name == "") == (domain == "") {
+		s.logAPIRequest(r, "/api/update-token", params, "missing_parameters", http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Must specify either name or domain",
+		})
+		return
+	}
+
+	var token string
+	var err error
+	switch {
+	case r.Method == http.MethodPost && name != "":
+		token, err = s.configStorage.RotateForwardingUpdateToken(&actor, name)
+	case r.Method == http.MethodPost:
+		token, err = s.configStorage.RotateDomainUpdateToken(&actor, domain)
+	case name != "":
+		err = s.configStorage.RevokeForwardingUpdateToken(&actor, name)
+	default:
+		err = s.configStorage.RevokeDomainUpdateToken(&actor, domain)
+	}
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/update-token", params, fmt.Sprintf("error:%s", err.Error()), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.logAPIRequest(r, "/api/update-token", params, "success", http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if token == "" {
+		json.NewEncoder(w).Encode(models.Response{State: "success"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"state": "success",
+		"token": token,
+	})
+}
+
+// handleDynDNS 兼容 DynDNS2 协议的更新接口，供路由器和 ddclient 等动态 IP 客户端使用
+// GET /nic/update?hostname=<name 或 domain>[,<...>]&myip=<ip>，token 通过 HTTP Basic 密码或 token 参数提供
+// 新 IP 替换目标地址中的主机部分，保留协议、端口和路径；未提供 myip 时使用客户端 IP
+func (s *Server) handleDynDNS(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	token := query.Get("token")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	hostnames := query.Get("hostname")
+	myIP := query.Get("myip")
+	params := map[string]string{
+		"hostname": hostnames,
+		"myip":     myIP,
+		"token":    token,
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method != http.MethodGet {
+		s.logAPIRequest(r, "/nic/update", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		fmt.Fprintln(w, "badagent")
+		return
+	}
+
+	if token == "" || s.configStorage == nil {
+		s.logAPIRequest(r, "/nic/update", params, "badauth", http.StatusUnauthorized)
+		w.Header().Set("WWW-Authenticate", `Basic realm="redirect_helper"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, "badauth")
+		return
+	}
+	if hostnames == "" {
+		s.logAPIRequest(r, "/nic/update", params, "notfqdn", http.StatusBadRequest)
+		fmt.Fprintln(w, "notfqdn")
+		return
+	}
+
+	if myIP == "" {
+		myIP = s.ClientIP(r)
+	}
+	addr, err := netip.ParseAddr(myIP)
+	if err != nil {
+		s.logAPIRequest(r, "/nic/update", params, "invalid_ip", http.StatusBadRequest)
+		fmt.Fprintln(w, "dnserr")
+		return
+	}
+	addr = addr.Unmap()
+
+	// 每个主机名一行结果，与 DynDNS2 一致
+	results := make([]string, 0)
+	for _, hostname := range strings.Split(hostnames, ",") {
+		results = append(results, s.updateDynDNSHost(r, strings.TrimSpace(hostname), token, addr))
+	}
+
+	s.logAPIRequest(r, "/nic/update", params, strings.Join(results, ";"), http.StatusOK)
+	for _, result := range results {
+		fmt.Fprintln(w, result)
+	}
+}
+
+// updateDynDNSHost 更新单个主机名，返回 DynDNS2 结果码
+// 新目标未通过校验（例如 IP 被目标策略拒绝）时返回 abuse，客户端不会像 911 那样反复重试
+func (s *Server) updateDynDNSHost(r *http.Request, hostname, token string, addr netip.Addr) string {
+	if hostname == "" {
+		return "notfqdn"
+	}
+
+	// 优先匹配域名映射，其次匹配路径名称
+	if domainEntry, err := s.configStorage.GetDomain(hostname); err == nil {
+		if !s.configStorage.CanUpdateDomain(hostname, token) {
+			return "badauth"
+		}
+		target := replaceTargetHost(domainEntry.Target, addr)
+		if target == domainEntry.Target {
+			return "nochg " + addr.String()
+		}
+		if err := s.validateTarget(r, target); err != nil {
+			return "abuse"
+		}
+		if _, err := s.configStorage.SetDomainTargetWithVersion(hostname, token, target, domainEntry.Version, r.Host); err != nil {
+			return "911"
+		}
+		return "good " + addr.String()
+	}
+
+	forwarding, err := s.configStorage.GetForwarding(hostname)
+	if err != nil {
+		return "nohost"
+	}
+	if !s.configStorage.CanUpdateForwarding(hostname, token) {
+		return "badauth"
+	}
+	target := replaceTargetHost(forwarding.Target, addr)
+	if target == forwarding.Target {
+		return "nochg " + addr.String()
+	}
+	if err := s.validateTarget(r, target); err != nil {
+		return "abuse"
+	}
+	if _, err := s.configStorage.SetTargetWithVersion(hostname, token, target, forwarding.Version, r.Host); err != nil {
+		return "911"
+	}
+	return "good " + addr.String()
+}
+
+// replaceTargetHost 用 addr 替换目标地址中的主机部分，保留协议、端口、路径和查询参数
+// 支持 "https://host:port/path" 和 "host:port/path" 两种形式；目标为空时返回 "http://IP"
+func replaceTargetHost(target string, addr netip.Addr) string {
+	host := addr.String()
+	if target == "" {
+		return "http://" + joinHostPort(host, "", addr.Is6())
+	}
+
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err == nil && u.Host != "" {
+			u.Host = joinHostPort(host, u.Port(), addr.Is6())
+			return u.String()
+		}
+	}
+
+	hostPort, rest := target, ""
+	if i := strings.IndexAny(target, "/?#"); i >= 0 {
+		hostPort, rest = target[:i], target[i:]
+	}
+	port := ""
+	if _, p, err := net.SplitHostPort(hostPort); err == nil {
+		port = p
+	}
+	return joinHostPort(host, port, addr.Is6()) + rest
+}
+
+func joinHostPort(host, port string, ipv6 bool) string {
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if ipv6 {
+		return "[" + host + "]"
+	}
+	return host
+}
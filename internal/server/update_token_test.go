@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestReplaceTargetHost(t *testing.T) {
+	tests := []struct {
+		target string
+		addr   string
+		want   string
+	}{
+		{"", "203.0.113.8", "http://203.0.113.8"},
+		{"", "2001:db8::8", "http://[2001:db8::8]"},
+		{"https://old.example.com:8443/path?q=1", "203.0.113.8", "https://203.0.113.8:8443/path?q=1"},
+		{"http://198.51.100.1/", "2001:db8::8", "http://[2001:db8::8]/"},
+		{"198.51.100.1:8080/app", "203.0.113.8", "203.0.113.8:8080/app"},
+		{"198.51.100.1", "2001:db8::8", "[2001:db8::8]"},
+	}
+	for _, tt := range tests {
+		if got := replaceTargetHost(tt.target, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("replaceTargetHost(%q, %s) = %q, want %q", tt.target, tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+)
+
+const (
+	// verificationPollInterval 检查 pending 域名的频率，每个域名的重试间隔由配置决定
+	verificationPollInterval = 10 * time.Second
+	// verificationCheckTimeout 单个域名 DNS 和 HTTP 检查的总超时
+	verificationCheckTimeout = 15 * time.Second
+	// maxChallengeBody HTTP 验证文件的读取上限
+	maxChallengeBody = 1024
+)
+
+// startVerifier 定期检查等待验证的域名，结果写回配置
+func (s *Server) startVerifier() {
+	stop := make(chan struct{})
+	s.verifyStop = stop
+
+	go func() {
+		ticker := time.NewTicker(verificationPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.runVerifications()
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stopVerifier 停止后台检查
+func (s *Server) stopVerifier() {
+	if s.verifyStop != nil {
+		close(s.verifyStop)
+		s.verifyStop = nil
+	}
+}
+
+// runVerifications 检查所有已到时间的 pending 域名
+func (s *Server) runVerifications() {
+	verificationConfig := s.configStorage.VerificationConfig()
+	if verificationConfig == nil {
+		return
+	}
+
+	for _, pending := range s.configStorage.DueVerifications(time.Now()) {
+		s.verifyDomain(verificationConfig, pending)
+	}
+}
+
+// verifyDomain 检查一个域名并记录结果
+func (s *Server) verifyDomain(verificationConfig *config.DomainVerificationConfig, pending config.PendingVerification) {
+	ctx, cancel := context.WithTimeout(context.Background(), verificationCheckTimeout)
+	defer cancel()
+
+	method, checkErr := checkDomainOwnership(ctx, verificationConfig, pending.Domain, pending.Challenge)
+	if checkErr == nil {
+		log.Printf("Domain %s verified via %s", pending.Domain, method)
+	}
+	if err := s.configStorage.RecordVerification(pending.Domain, pending.Challenge, method, checkErr); err != nil {
+		log.Printf("Failed to record verification result for %s: %v", pending.Domain, err)
+	}
+}
+
+// checkDomainOwnership 依次检查 DNS TXT 记录和 HTTP well-known 文件，任一包含 challenge 即通过
+func checkDomainOwnership(ctx context.Context, verificationConfig *config.DomainVerificationConfig, domain, challenge string) (string, error) {
+	dnsErr := checkDNSChallenge(ctx, verificationConfig.Resolver, domain, challenge)
+	if dnsErr == nil {
+		return config.VerificationMethodDNS, nil
+	}
+	httpErr := checkHTTPChallenge(ctx, domain, challenge)
+	if httpErr == nil {
+		return config.VerificationMethodHTTP, nil
+	}
+	// HTTP 检查会跟随跳转到任意地址，具体的状态码和错误只写日志，不返回给调用方，
+	// 以免 last_error 被用来探测内网地址
+	log.Printf("Domain %s HTTP challenge check failed: %v", domain, httpErr)
+	return "", fmt.Errorf("dns: %v; http: challenge file not found at http://%s%s%s", dnsErr, domain, config.ChallengeHTTPPath, challenge)
+}
+
+// checkDNSChallenge 通过配置的解析器查询 _redirect-helper-challenge.<域名> 的 TXT 记录
+func checkDNSChallenge(ctx context.Context, resolverAddr, domain, challenge string) error {
+	resolver := net.DefaultResolver
+	if resolverAddr != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, resolverAddr)
+			},
+		}
+	}
+
+	name := config.ChallengeDNSPrefix + domain
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return fmt.Errorf("no TXT record at %s", name)
+		}
+		return err
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == challenge {
+			return nil
+		}
+	}
+	return fmt.Errorf("TXT record at %s does not contain the challenge", name)
+}
+
+// checkHTTPChallenge 请求 http://<域名>/.well-known/redirect-helper-challenge/<challenge>，内容必须为 challenge
+func checkHTTPChallenge(ctx context.Context, domain, challenge string) error {
+	url := "http://" + domain + config.ChallengeHTTPPath + challenge
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		// 与 ACME HTTP-01 一样允许跳转到 HTTPS
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChallengeBody))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != challenge {
+		return fmt.Errorf("%s does not contain the challenge", url)
+	}
+	return nil
+}
+
+// handleVerifyDomain 立即重新检查域名所有权（POST /api/verify-domain?domain=example.com）
+// 条目所属用户和管理员可以触发检查；管理员加 approve=true 可以跳过检查直接通过
+func (s *Server) handleVerifyDomain(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	approve := r.URL.Query().Get("approve") == "true"
+	params := map[string]string{
+		"domain":  domain,
+		"approve": fmt.Sprintf("%t", approve),
+	}
+
+	if r.Method != http.MethodPost {
+		s.logAPIRequest(r, "/api/verify-domain", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/verify-domain", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	if domain == "" {
+		s.logAPIRequest(r, "/api/verify-domain", params, "missing_parameters", http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: "Missing required parameter: domain",
+		})
+		return
+	}
+
+	verificationConfig := s.configStorage.VerificationConfig()
+	var err error
+	switch {
+	case approve && !actor.Admin:
+		s.logAPIRequest(r, "/api/verify-domain", params, "forbidden", http.StatusForbidden)
+		s.writeAuthError(w, errNotAdmin)
+		return
+	case approve:
+		err = s.configStorage.ApproveDomain(domain)
+	case verificationConfig == nil:
+		err = fmt.Errorf("domain verification is not enabled")
+	default:
+		err = s.configStorage.RetryVerification(&actor, domain)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/verify-domain", params, "error:"+err.Error(), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	entry, err := s.configStorage.GetDomain(domain)
+	if err == nil && !approve {
+		// 立即检查一次，结果同时写入配置供列表显示
+		if v := entry.Verification; v != nil && v.Status == config.VerificationPending {
+			s.verifyDomain(verificationConfig, config.PendingVerification{Domain: entry.Domain, Challenge: v.Challenge})
+			entry, err = s.configStorage.GetDomain(domain)
+		}
+	}
+	if err != nil {
+		s.logAPIRequest(r, "/api/verify-domain", params, "error:"+err.Error(), http.StatusNotFound)
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result := config.VerificationVerified
+	if entry.Verification != nil {
+		result = entry.Verification.Status
+	}
+	s.logAPIRequest(r, "/api/verify-domain", params, result, http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:        "success",
+		Version:      entry.Version,
+		Verification: entry.Verification,
+	})
+}
+
+// pendingVerification 域名映射尚未通过验证时返回验证信息，否则返回 nil
+func (s *Server) pendingVerification(domain string) *models.DomainVerification {
+	if s.domainStorage == nil {
+		return nil
+	}
+	entry, err := s.domainStorage.GetDomain(domain)
+	if err != nil || entry.Verification == nil || entry.Verification.Status == config.VerificationVerified {
+		return nil
+	}
+	return entry.Verification
+}
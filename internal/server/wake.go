@@ -0,0 +1,429 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/wol"
+)
+
+const (
+	// wakeStatusPath 等待页面轮询目标状态的地址；路径跳转带 name 参数，域名跳转按 Host 匹配
+	wakeStatusPath = "/.well-known/redirect-helper-wake"
+	// wakeQuickProbe 访问时检查目标端口的超时，超过后视为休眠
+	wakeQuickProbe = 500 * time.Millisecond
+	// wakeStatusProbe 轮询时检查目标端口的超时
+	wakeStatusProbe = 2 * time.Second
+	// wakeResendInterval 等待期间重发魔术包的间隔，部分网卡会错过第一个包
+	wakeResendInterval = 15 * time.Second
+)
+
+// wakeAttempt 一次唤醒过程
+type wakeAttempt struct {
+	started  time.Time
+	lastSent time.Time
+	timeout  time.Duration
+}
+
+func (a *wakeAttempt) expired(now time.Time) bool {
+	return now.Sub(a.started) > a.timeout
+}
+
+// wakeTracker 记录正在唤醒的条目，避免每次访问和轮询都发送魔术包
+type wakeTracker struct {
+	mu       sync.Mutex
+	attempts map[string]*wakeAttempt
+}
+
+// wake 开始或继续唤醒：没有进行中的唤醒或上次已超时时重新计时，距上次发送超过重发间隔时发送魔术包
+func (t *wakeTracker) wake(key string, wake *models.Wake) (*wakeAttempt, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.attempts == nil {
+		t.attempts = make(map[string]*wakeAttempt)
+	}
+	attempt := t.attempts[key]
+	if attempt == nil || attempt.expired(now) {
+		attempt = &wakeAttempt{started: now, timeout: wakeTimeout(wake)}
+		t.attempts[key] = attempt
+	}
+	if now.Sub(attempt.lastSent) < wakeResendInterval {
+		copied := *attempt
+		return &copied, nil
+	}
+
+	if err := wol.Send(wake.MAC, wake.Broadcast); err != nil {
+		return nil, err
+	}
+	attempt.lastSent = now
+	copied := *attempt
+	return &copied, nil
+}
+
+// current 返回进行中的唤醒，没有时返回 nil
+func (t *wakeTracker) current(key string) *wakeAttempt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if attempt := t.attempts[key]; attempt != nil {
+		copied := *attempt
+		return &copied
+	}
+	return nil
+}
+
+// finish 目标已可连接，结束唤醒
+func (t *wakeTracker) finish(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, key)
+}
+
+func wakeTimeout(wake *models.Wake) time.Duration {
+	if wake.Timeout > 0 {
+		return time.Duration(wake.Timeout) * time.Second
+	}
+	return config.DefaultWakeTimeout * time.Second
+}
+
+// wakeProbeAddr 目标的 TCP 地址：URL 目标使用其端口，未写端口时按协议取 80 或 443
+func wakeProbeAddr(target string) (string, error) {
+	if !strings.Contains(target, "://") {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return "", err
+		}
+		return target, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if strings.EqualFold(u.Scheme, "https") {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// targetReachable 目标端口能否在 timeout 内建立 TCP 连接
+func targetReachable(addr string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// wakeBeforeRedirect 目标可以连接时返回 false 继续跳转；否则发送魔术包并显示等待页面
+func (s *Server) wakeBeforeRedirect(w http.ResponseWriter, r *http.Request, key string, wake *models.Wake, target, statusURL string) bool {
+	addr, err := wakeProbeAddr(target)
+	if err != nil {
+		return false
+	}
+	if targetReachable(addr, wakeQuickProbe) {
+		s.wakes.finish(key)
+		return false
+	}
+
+	attempt, err := s.wakes.wake(key, wake)
+	if err != nil {
+		log.Printf("[Wake] %s: %v", key, err)
+		http.Error(w, "Failed to wake target: "+err.Error(), http.StatusBadGateway)
+		return true
+	}
+	log.Printf("[Wake] %s: target %s is not answering, waiting up to %s", key, addr, attempt.timeout)
+
+	// 页面轮询状态，目标可以连接后重新加载原地址完成跳转
+	w.Header().Set("Retry-After", "5")
+	s.renderPage(w, http.StatusServiceUnavailable, "wake", map[string]interface{}{
+		"Name":      strings.TrimPrefix(strings.TrimPrefix(key, "forwarding/"), "domain/"),
+		"StatusURL": statusURL,
+		"Timeout":   int(attempt.timeout.Seconds()),
+	})
+	return true
+}
+
+// wakeForwarding 路径跳转设置了网络唤醒时先检查目标
+func (s *Server) wakeForwarding(w http.ResponseWriter, r *http.Request, name, target string) bool {
+	entry, err := s.storage.GetForwarding(name)
+	if err != nil || entry.Wake == nil {
+		return false
+	}
+	statusURL := s.basePath + wakeStatusPath + "?name=" + url.QueryEscape(name)
+	return s.wakeBeforeRedirect(w, r, "forwarding/"+name, entry.Wake, target, statusURL)
+}
+
+// wakeDomain 域名映射设置了网络唤醒时先检查目标；等待页面的轮询请求也通过域名到达这里
+func (s *Server) wakeDomain(w http.ResponseWriter, r *http.Request, target string) bool {
+	if r.URL.Path == wakeStatusPath {
+		s.handleWakeStatus(w, r)
+		return true
+	}
+
+	entry, err := s.domainStorage.GetDomain(requestHost(r))
+	if err != nil || entry.Wake == nil {
+		return false
+	}
+	return s.wakeBeforeRedirect(w, r, "domain/"+entry.Domain, entry.Wake, target, wakeStatusPath)
+}
+
+// requestHost 去掉端口的 Host
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// wakeEntry 按 name 参数或 Host 查找设置了网络唤醒的条目，返回唤醒记录的键、设置和目标
+func (s *Server) wakeEntry(r *http.Request) (string, *models.Wake, string, error) {
+	if name := r.URL.Query().Get("name"); name != "" {
+		entry, err := s.storage.GetForwarding(name)
+		if err != nil {
+			return "", nil, "", err
+		}
+		return "forwarding/" + entry.Name, entry.Wake, entry.Target, nil
+	}
+
+	if s.domainStorage == nil {
+		return "", nil, "", errors.New("domain not found")
+	}
+	// 只处理已生效的映射，未通过验证的域名不暴露状态
+	host := requestHost(r)
+	target, err := s.domainStorage.GetDomainTarget(host)
+	if err != nil || target == "" {
+		return "", nil, "", errors.New("domain not found")
+	}
+	entry, err := s.domainStorage.GetDomain(host)
+	if err != nil {
+		return "", nil, "", err
+	}
+	return "domain/" + entry.Domain, entry.Wake, target, nil
+}
+
+// handleWakeStatus 等待页面轮询目标状态，不需要认证，只返回 waking、ready 或 timeout
+func (s *Server) handleWakeStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	key, wake, target, err := s.wakeEntry(r)
+	if err == nil && wake == nil {
+		err = errors.New("entry has no wake configuration")
+	}
+	var addr string
+	if err == nil {
+		addr, err = wakeProbeAddr(target)
+	}
+	if err != nil {
+		s.writeJSONResponse(w, http.StatusNotFound, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	status := map[string]interface{}{"state": "ready"}
+	if targetReachable(addr, wakeStatusProbe) {
+		s.wakes.finish(key)
+	} else if attempt := s.wakes.current(key); attempt == nil || attempt.expired(time.Now()) {
+		status["state"] = "timeout"
+	} else {
+		// 等待期间定期重发
+		s.wakes.wake(key, wake)
+		status["state"] = "waking"
+		status["elapsed"] = int(time.Since(attempt.started).Seconds())
+		status["timeout"] = int(attempt.timeout.Seconds())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleWake 立即发送魔术包（POST /api/wake?name=nas 或 ?domain=nas.example.com）
+func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	name, domain := r.URL.Query().Get("name"), r.URL.Query().Get("domain")
+	params := map[string]string{"name": name, "domain": domain}
+
+	if r.Method != http.MethodPost {
+		s.logAPIRequest(r, "/api/wake", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/wake", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	key, owner, wake, err := s.lookupWakeEntry(name, domain)
+	if err == nil && !actor.CanAccess(owner) {
+		// 不暴露其他用户的条目
+		err = errors.New("entry not found")
+	}
+	if err == nil && wake == nil {
+		err = errors.New("entry has no wake configuration")
+	}
+	if err == nil {
+		s.wakes.finish(key)
+		_, err = s.wakes.wake(key, wake)
+	}
+	if err != nil {
+		s.logAPIRequest(r, "/api/wake", params, "error:"+err.Error(), http.StatusBadRequest)
+		s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.logAPIRequest(r, "/api/wake", params, "success", http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:   "success",
+		Message: "Magic packet sent",
+	})
+}
+
+// lookupWakeEntry 按名称或域名查找条目的所有者和网络唤醒设置
+func (s *Server) lookupWakeEntry(name, domain string) (string, string, *models.Wake, error) {
+	switch {
+	case name != "" && domain != "":
+		return "", "", nil, errors.New("use either name or domain")
+	case name != "":
+		entry, err := s.configStorage.GetForwarding(name)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return "forwarding/" + entry.Name, entry.Owner, entry.Wake, nil
+	case domain != "":
+		entry, err := s.configStorage.GetDomain(domain)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return "domain/" + entry.Domain, entry.Owner, entry.Wake, nil
+	default:
+		return "", "", nil, errors.New("Missing required parameter: name or domain")
+	}
+}
+
+// handleWakeConfig 设置（POST）或取消（DELETE）条目的网络唤醒，条目所属用户和管理员可以操作
+// POST /api/wake/config?name=nas&mac=00:11:22:33:44:55&broadcast=192.168.1.255&timeout=180
+func (s *Server) handleWakeConfig(w http.ResponseWriter, r *http.Request) {
+	// 先检查是否为域名跳转
+	if s.checkDomainRedirect(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	name, domain := query.Get("name"), query.Get("domain")
+	params := map[string]string{
+		"name":      name,
+		"domain":    domain,
+		"mac":       query.Get("mac"),
+		"broadcast": query.Get("broadcast"),
+		"timeout":   query.Get("timeout"),
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		s.logAPIRequest(r, "/api/wake/config", params, "method_not_allowed", http.StatusMethodNotAllowed)
+		s.writeJSONResponse(w, http.StatusMethodNotAllowed, models.Response{
+			State:   "error",
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	actor, authErr := s.authenticate(r, true)
+	if authErr != nil {
+		s.logAPIRequest(r, "/api/wake/config", params, "unauthorized", authErr.status)
+		s.writeAuthError(w, authErr)
+		return
+	}
+
+	var wake *config.WakeConfig
+	var err error
+	if r.Method == http.MethodPost {
+		wake, err = parseWakeConfig(query.Get("mac"), query.Get("broadcast"), query.Get("timeout"))
+	}
+	if err == nil {
+		switch {
+		case (name == "") == (domain == ""):
+			err = errors.New("Use exactly one of the parameters: name, domain")
+		case name != "":
+			err = s.configStorage.SetForwardingWake(&actor, name, wake)
+		default:
+			err = s.configStorage.SetDomainWake(&actor, domain, wake)
+		}
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, config.ErrPermissionDenied) {
+			status = http.StatusForbidden
+		}
+		s.logAPIRequest(r, "/api/wake/config", params, "error:"+err.Error(), status)
+		s.writeJSONResponse(w, status, models.Response{
+			State:   "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var version int64
+	if name != "" {
+		if entry, err := s.configStorage.GetForwarding(name); err == nil {
+			version = entry.Version
+		}
+	} else if entry, err := s.configStorage.GetDomain(domain); err == nil {
+		version = entry.Version
+	}
+
+	s.logAPIRequest(r, "/api/wake/config", params, "success", http.StatusOK)
+	s.writeJSONResponse(w, http.StatusOK, models.Response{
+		State:   "success",
+		Version: version,
+	})
+}
+
+// parseWakeConfig 解析 API 的网络唤醒参数，格式由配置层校验
+func parseWakeConfig(mac, broadcast, timeout string) (*config.WakeConfig, error) {
+	if mac == "" {
+		return nil, errors.New("Missing required parameter: mac")
+	}
+	wake := &config.WakeConfig{MAC: mac, Broadcast: broadcast}
+	if timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid timeout value %q, expected seconds", timeout)
+		}
+		wake.Timeout = seconds
+	}
+	return wake, nil
+}
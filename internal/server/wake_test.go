@@ -0,0 +1,28 @@
+package server
+
+import "testing"
+
+func TestParseWakeConfigTimeout(t *testing.T) {
+	tests := []struct {
+		timeout string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"300", 300, false},
+		{"5m", 0, true},
+		{"1.5", 0, true},
+		{"-1", 0, true},
+	}
+	for _, tt := range tests {
+		wake, err := parseWakeConfig("00:11:22:33:44:55", "", tt.timeout)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWakeConfig(timeout=%q) error = %v, want error %v", tt.timeout, err, tt.wantErr)
+			continue
+		}
+		if err == nil && wake.Timeout != tt.want {
+			t.Errorf("parseWakeConfig(timeout=%q) = %d, want %d", tt.timeout, wake.Timeout, tt.want)
+		}
+	}
+}
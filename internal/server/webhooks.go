@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"redirect_helper/internal/models"
+	"redirect_helper/internal/webhook"
+)
+
+// handleListWebhooks 返回 webhook 订阅（不含签名密钥）和待投递队列
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	subscriptions := []webhook.SubscriptionInfo{}
+	pending := []webhook.Delivery{}
+	if s.webhooks != nil {
+		subscriptions = s.webhooks.Subscriptions()
+		pending = s.webhooks.Pending()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":         "success",
+		"subscriptions": subscriptions,
+		"pending":       pending,
+	})
+}
+
+// handleWebhookDeliveries 返回最近的投递日志，最新的在前，limit 默认 50
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdminRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			s.writeJSONResponse(w, http.StatusBadRequest, models.Response{
+				State:   "error",
+				Message: "Invalid limit parameter",
+			})
+			return
+		}
+		limit = n
+	}
+
+	deliveries := []webhook.LogEntry{}
+	if s.webhooks != nil {
+		deliveries = s.webhooks.Logs(limit)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":      "success",
+		"deliveries": deliveries,
+	})
+}
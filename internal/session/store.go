@@ -0,0 +1,123 @@
+package session
+
+import (
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"redirect_helper/pkg/utils"
+)
+
+// DefaultTTL 会话默认有效期
+const DefaultTTL = 12 * time.Hour
+
+// Session 服务端保存的登录会话
+type Session struct {
+	ID        string
+	CSRFToken string
+	Subject   string // 登录身份，admin token 登录时为 "admin"，密码登录时为用户名
+	User      string // 用户账户名；使用 admin token 或 server.admin_username 登录时为空
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ValidCSRF 以常量时间比较 CSRF token
+func (s *Session) ValidCSRF(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken)) == 1
+}
+
+// Store 内存中的会话存储，进程重启后所有会话失效
+type Store struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	ttl      time.Duration
+}
+
+// NewStore 创建会话存储，ttl <= 0 时使用默认有效期
+func NewStore(ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{
+		sessions: make(map[string]*Session),
+		ttl:      ttl,
+	}
+}
+
+// TTL 返回会话有效期
+func (st *Store) TTL() time.Duration {
+	return st.ttl
+}
+
+// Create 为 subject 创建新会话，同时清理已过期的会话；user 为登录的用户账户名
+func (st *Store) Create(subject, user string) (*Session, error) {
+	id, err := utils.GenerateToken(64)
+	if err != nil {
+		return nil, err
+	}
+	csrfToken, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:        id,
+		CSRFToken: csrfToken,
+		Subject:   subject,
+		User:      user,
+		CreatedAt: now,
+		ExpiresAt: now.Add(st.ttl),
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for existingID, existing := range st.sessions {
+		if now.After(existing.ExpiresAt) {
+			delete(st.sessions, existingID)
+		}
+	}
+	st.sessions[id] = session
+
+	copied := *session
+	return &copied, nil
+}
+
+// Get 返回未过期的会话
+func (st *Store) Get(id string) (*Session, bool) {
+	if id == "" {
+		return nil, false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	session, exists := st.sessions[id]
+	if !exists {
+		return nil, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(st.sessions, id)
+		return nil, false
+	}
+
+	copied := *session
+	return &copied, true
+}
+
+// Revoke 使会话立即失效
+func (st *Store) Revoke(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.sessions, id)
+}
+
+// RevokeAll 使所有会话失效，例如重置 admin token 之后
+func (st *Store) RevokeAll() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.sessions = make(map[string]*Session)
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"redirect_helper/internal/config"
 	"redirect_helper/internal/models"
 )
@@ -15,7 +17,6 @@ func NewConfigStorage(cfg *config.Config) *ConfigStorage {
 	}
 }
 
-
 func (s *ConfigStorage) SetTarget(name, token, target string) error {
 	return s.config.SetTarget(name, token, target)
 }
@@ -31,10 +32,20 @@ func (s *ConfigStorage) GetForwarding(name string) (*models.ForwardingEntry, err
 	}
 
 	return &models.ForwardingEntry{
-		Name:      forwarding.Name,
-		Target:    forwarding.Target,
-		CreatedAt: forwarding.CreatedAt,
-		UpdatedAt: forwarding.UpdatedAt,
+		Name:           forwarding.Name,
+		Target:         forwarding.Target,
+		Version:        forwarding.Version,
+		Owner:          forwarding.Owner,
+		HasUpdateToken: forwarding.UpdateTokenHash != "",
+		Relay:          forwardingRelay(forwarding.Relay),
+		Wake:           entryWake(forwarding.Wake),
+		Preview:        forwardingPreview(forwarding.Preview),
+		HasPassword:    forwarding.PasswordHash != "",
+		MaxUses:        forwarding.MaxUses,
+		Uses:           forwarding.Uses,
+		Routes:         entryRoutes(forwarding.Routes),
+		CreatedAt:      forwarding.CreatedAt,
+		UpdatedAt:      forwarding.UpdatedAt,
 	}, nil
 }
 
@@ -44,16 +55,188 @@ func (s *ConfigStorage) ListForwardings() ([]*models.ForwardingEntry, error) {
 
 	for _, f := range forwardings {
 		result = append(result, &models.ForwardingEntry{
-			Name:      f.Name,
-			Target:    f.Target,
-			CreatedAt: f.CreatedAt,
-			UpdatedAt: f.UpdatedAt,
+			Name:           f.Name,
+			Target:         f.Target,
+			Version:        f.Version,
+			Owner:          f.Owner,
+			HasUpdateToken: f.UpdateTokenHash != "",
+			Relay:          forwardingRelay(f.Relay),
+			Wake:           entryWake(f.Wake),
+			Preview:        forwardingPreview(f.Preview),
+			HasPassword:    f.PasswordHash != "",
+			MaxUses:        f.MaxUses,
+			Uses:           f.Uses,
+			Routes:         entryRoutes(f.Routes),
+			CreatedAt:      f.CreatedAt,
+			UpdatedAt:      f.UpdatedAt,
 		})
 	}
 
 	return result, nil
 }
 
+// forwardingRelay 转换四层转发设置，运行状态由服务端填充
+func forwardingRelay(relay *config.RelayConfig) *models.ForwardingRelay {
+	if relay == nil {
+		return nil
+	}
+	return &models.ForwardingRelay{
+		Listen:         relay.Listen,
+		UDP:            relay.UDP,
+		MaxConnections: relay.MaxConnections,
+		IdleTimeout:    relay.IdleTimeout,
+	}
+}
+
+// entryWake 转换网络唤醒设置
+func entryWake(wake *config.WakeConfig) *models.Wake {
+	if wake == nil {
+		return nil
+	}
+	return &models.Wake{MAC: wake.MAC, Broadcast: wake.Broadcast, Timeout: wake.Timeout}
+}
+
+// entryRoutes 转换路由规则
+func entryRoutes(rules []config.RouteRule) []models.RouteRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	result := make([]models.RouteRule, len(rules))
+	for i, rule := range rules {
+		result[i] = models.RouteRule{
+			CIDRs:     append([]string(nil), rule.CIDRs...),
+			Countries: append([]string(nil), rule.Countries...),
+			ASNs:      append([]uint32(nil), rule.ASNs...),
+			Devices:   append([]string(nil), rule.Devices...),
+			Languages: append([]string(nil), rule.Languages...),
+			Headers:   entryFieldMatches(rule.Headers),
+			Query:     entryFieldMatches(rule.Query),
+			Target:    rule.Target,
+		}
+	}
+	return result
+}
+
+func entryFieldMatches(fields []config.FieldMatch) []models.FieldMatch {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make([]models.FieldMatch, len(fields))
+	for i, field := range fields {
+		result[i] = models.FieldMatch{Name: field.Name, Value: field.Value}
+	}
+	return result
+}
+
+// NormalizeRoutes 校验规则但不保存
+func (s *ConfigStorage) NormalizeRoutes(rules []config.RouteRule, extraSelfHosts ...string) ([]models.RouteRule, error) {
+	normalized, err := s.config.NormalizeRoutes(rules, extraSelfHosts...)
+	if err != nil {
+		return nil, err
+	}
+	return entryRoutes(normalized), nil
+}
+
+// SetForwardingRoutes 替换路径跳转的路由规则
+func (s *ConfigStorage) SetForwardingRoutes(actor *config.Actor, name string, rules []config.RouteRule, extraSelfHosts ...string) error {
+	return s.config.SetForwardingRoutes(actor, name, rules, extraSelfHosts...)
+}
+
+// SetDomainRoutes 替换域名映射的路由规则
+func (s *ConfigStorage) SetDomainRoutes(actor *config.Actor, domain string, rules []config.RouteRule, extraSelfHosts ...string) error {
+	return s.config.SetDomainRoutes(actor, domain, rules, extraSelfHosts...)
+}
+
+// entryPathRules 转换路径规则
+func entryPathRules(rules []config.PathRule) []models.PathRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	result := make([]models.PathRule, len(rules))
+	for i, rule := range rules {
+		result[i] = models.PathRule{Type: rule.Type, Pattern: rule.Pattern, Target: rule.Target, Status: rule.Status}
+	}
+	return result
+}
+
+// SetDomainPathRules 替换域名映射的路径规则
+func (s *ConfigStorage) SetDomainPathRules(actor *config.Actor, domain string, rules []config.PathRule, extraSelfHosts ...string) error {
+	return s.config.SetDomainPathRules(actor, domain, rules, extraSelfHosts...)
+}
+
+// GeoIPSettings 返回 GeoIP 数据库设置，未配置时返回 nil
+func (s *ConfigStorage) GeoIPSettings() *config.GeoIPConfig {
+	return s.config.GeoIPSettings()
+}
+
+// SetForwardingWake 设置或取消路径跳转的网络唤醒
+func (s *ConfigStorage) SetForwardingWake(actor *config.Actor, name string, wake *config.WakeConfig) error {
+	return s.config.SetForwardingWake(actor, name, wake)
+}
+
+// forwardingPreview 转换预览页面设置
+func forwardingPreview(preview *config.PreviewConfig) *models.Preview {
+	if preview == nil {
+		return nil
+	}
+	return &models.Preview{Message: preview.Message, Countdown: preview.Countdown}
+}
+
+// SetForwardingPreview 设置或取消路径跳转的预览页面
+func (s *ConfigStorage) SetForwardingPreview(actor *config.Actor, name string, preview *config.PreviewConfig) error {
+	return s.config.SetForwardingPreview(actor, name, preview)
+}
+
+// SetForwardingAccess 修改路径跳转的访问密码和使用次数上限
+func (s *ConfigStorage) SetForwardingAccess(actor *config.Actor, name string, change config.AccessChange) error {
+	return s.config.SetForwardingAccess(actor, name, change)
+}
+
+// ValidateForwardingPassword 校验路径跳转的访问密码
+func (s *ConfigStorage) ValidateForwardingPassword(name, password string) bool {
+	return s.config.ValidateForwardingPassword(name, password)
+}
+
+// ForwardingPasswordDigest 返回访问密码哈希的摘要，未设置密码时返回空字符串
+func (s *ConfigStorage) ForwardingPasswordDigest(name string) string {
+	return s.config.ForwardingPasswordDigest(name)
+}
+
+// ConsumeForwardingUse 记录一次成功跳转，次数已用完时返回 config.ErrLinkExhausted
+func (s *ConfigStorage) ConsumeForwardingUse(name string) error {
+	return s.config.ConsumeForwardingUse(name)
+}
+
+// SetDomainWake 设置或取消域名映射的网络唤醒
+func (s *ConfigStorage) SetDomainWake(actor *config.Actor, domain string, wake *config.WakeConfig) error {
+	return s.config.SetDomainWake(actor, domain, wake)
+}
+
+// RelaySettings 返回四层转发全局设置，未启用时返回 nil
+func (s *ConfigStorage) RelaySettings() *config.RelayServerConfig {
+	return s.config.RelaySettings()
+}
+
+// Relays 返回设置了四层转发的条目
+func (s *ConfigStorage) Relays() map[string]config.RelayConfig {
+	return s.config.Relays()
+}
+
+// RelayTarget 返回转发连接使用的当前 host:port 目标
+func (s *ConfigStorage) RelayTarget(name string) (string, error) {
+	return s.config.RelayTarget(name)
+}
+
+// SetRelay 为路径条目设置四层转发
+func (s *ConfigStorage) SetRelay(name string, relay config.RelayConfig) error {
+	return s.config.SetRelay(name, relay)
+}
+
+// RemoveRelay 取消路径条目的四层转发
+func (s *ConfigStorage) RemoveRelay(name string) error {
+	return s.config.RemoveRelay(name)
+}
+
 func (s *ConfigStorage) RemoveForwarding(name string) error {
 	return s.config.RemoveForwarding(name)
 }
@@ -79,10 +262,17 @@ func (s *ConfigStorage) GetDomain(domain string) (*models.DomainEntry, error) {
 	}
 
 	return &models.DomainEntry{
-		Domain:    domainConfig.Domain,
-		Target:    domainConfig.Target,
-		CreatedAt: domainConfig.CreatedAt,
-		UpdatedAt: domainConfig.UpdatedAt,
+		Domain:         domainConfig.Domain,
+		Target:         domainConfig.Target,
+		Version:        domainConfig.Version,
+		Owner:          domainConfig.Owner,
+		HasUpdateToken: domainConfig.UpdateTokenHash != "",
+		CreatedAt:      domainConfig.CreatedAt,
+		UpdatedAt:      domainConfig.UpdatedAt,
+		Verification:   domainVerification(domainConfig),
+		Wake:           entryWake(domainConfig.Wake),
+		Routes:         entryRoutes(domainConfig.Routes),
+		PathRules:      entryPathRules(domainConfig.PathRules),
 	}, nil
 }
 
@@ -92,16 +282,72 @@ func (s *ConfigStorage) ListDomains() ([]*models.DomainEntry, error) {
 
 	for _, d := range domains {
 		result = append(result, &models.DomainEntry{
-			Domain:    d.Domain,
-			Target:    d.Target,
-			CreatedAt: d.CreatedAt,
-			UpdatedAt: d.UpdatedAt,
+			Domain:         d.Domain,
+			Target:         d.Target,
+			Version:        d.Version,
+			Owner:          d.Owner,
+			HasUpdateToken: d.UpdateTokenHash != "",
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+			Verification:   domainVerification(d),
+			Wake:           entryWake(d.Wake),
+			Routes:         entryRoutes(d.Routes),
+			PathRules:      entryPathRules(d.PathRules),
 		})
 	}
 
 	return result, nil
 }
 
+// domainVerification 转换验证状态，通过验证后不再返回 challenge
+func domainVerification(d *config.DomainConfig) *models.DomainVerification {
+	v := d.Verification
+	if v == nil {
+		return nil
+	}
+
+	result := &models.DomainVerification{
+		Status:        v.Status,
+		Method:        v.Method,
+		Attempts:      v.Attempts,
+		LastError:     v.LastError,
+		LastCheckedAt: v.LastCheckedAt,
+		NextCheckAt:   v.NextCheckAt,
+		VerifiedAt:    v.VerifiedAt,
+	}
+	if v.Status != config.VerificationVerified {
+		result.Challenge = v.Challenge
+		result.DNSRecord = config.ChallengeDNSPrefix + d.Domain
+		result.HTTPURL = "http://" + d.Domain + config.ChallengeHTTPPath + v.Challenge
+	}
+	return result
+}
+
+// VerificationConfig 返回域名验证配置，未启用时返回 nil
+func (s *ConfigStorage) VerificationConfig() *config.DomainVerificationConfig {
+	return s.config.VerificationConfig()
+}
+
+// DueVerifications 返回已到检查时间的 pending 域名
+func (s *ConfigStorage) DueVerifications(now time.Time) []config.PendingVerification {
+	return s.config.DueVerifications(now)
+}
+
+// RecordVerification 记录一次验证检查的结果
+func (s *ConfigStorage) RecordVerification(domain, challenge, method string, checkErr error) error {
+	return s.config.RecordVerification(domain, challenge, method, checkErr)
+}
+
+// RetryVerification 让验证失败或等待中的域名立即重新检查
+func (s *ConfigStorage) RetryVerification(actor *config.Actor, domain string) error {
+	return s.config.RetryVerification(actor, domain)
+}
+
+// ApproveDomain 跳过检查直接标记为已验证
+func (s *ConfigStorage) ApproveDomain(domain string) error {
+	return s.config.ApproveDomain(domain)
+}
+
 func (s *ConfigStorage) RemoveDomain(domain string) error {
 	return s.config.RemoveDomain(domain)
 }
@@ -146,4 +392,182 @@ func (s *ConfigStorage) ValidateRedirectToken(token string) bool {
 
 func (s *ConfigStorage) ValidateDomainToken(token string) bool {
 	return s.config.ValidateDomainToken(token)
-}
\ No newline at end of file
+}
+
+// RotateToken 生成新的全局 token，旧 token 在宽限期内继续有效
+func (s *ConfigStorage) RotateToken(kind string, grace time.Duration) (string, time.Time, error) {
+	return s.config.RotateToken(kind, grace)
+}
+
+// RetiredTokens 返回仍在宽限期内的旧 token 状态
+func (s *ConfigStorage) RetiredTokens() []config.RetiredTokenInfo {
+	return s.config.RetiredTokens()
+}
+
+// IdentifyToken 识别 token 的类型和代次，用于日志和统计
+func (s *ConfigStorage) IdentifyToken(token string) (string, string) {
+	return s.config.IdentifyToken(token)
+}
+
+// Flush 将当前配置写入磁盘
+func (s *ConfigStorage) Flush() error {
+	return s.config.Save()
+}
+
+// CheckHealth 检查底层存储是否可读写
+func (s *ConfigStorage) CheckHealth() error {
+	return s.config.CheckStorage()
+}
+
+// ValidateTarget 按服务器配置校验目标地址，extraSelfHosts 用于检测跳转循环
+func (s *ConfigStorage) ValidateTarget(target string, extraSelfHosts ...string) error {
+	return s.config.ValidateTarget(target, extraSelfHosts...)
+}
+
+// SetTargetWithVersion 设置目标并返回新版本，expectedVersion 非 0 时检查当前版本
+func (s *ConfigStorage) SetTargetWithVersion(name, token, target string, expectedVersion int64, extraSelfHosts ...string) (int64, error) {
+	return s.config.SetTargetWithVersion(name, token, target, expectedVersion, extraSelfHosts...)
+}
+
+// SetDomainTargetWithVersion 设置域名目标并返回新版本，expectedVersion 非 0 时检查当前版本
+func (s *ConfigStorage) SetDomainTargetWithVersion(domain, token, target string, expectedVersion int64, extraSelfHosts ...string) (int64, error) {
+	return s.config.SetDomainTargetWithVersion(domain, token, target, expectedVersion, extraSelfHosts...)
+}
+
+// RemoveForwardingWithVersion 以 actor 的身份删除条目，expectedVersion 非 0 时检查当前版本
+func (s *ConfigStorage) RemoveForwardingWithVersion(actor *config.Actor, name string, expectedVersion int64) error {
+	return s.config.RemoveForwardingWithVersion(actor, name, expectedVersion)
+}
+
+// RemoveDomainWithVersion 以 actor 的身份删除域名映射，expectedVersion 非 0 时检查当前版本
+func (s *ConfigStorage) RemoveDomainWithVersion(actor *config.Actor, domain string, expectedVersion int64) error {
+	return s.config.RemoveDomainWithVersion(actor, domain, expectedVersion)
+}
+
+// AuthenticateAPIKey 根据 API key 查找用户
+func (s *ConfigStorage) AuthenticateAPIKey(apiKey string) (config.Actor, bool) {
+	return s.config.AuthenticateAPIKey(apiKey)
+}
+
+// AuthenticatePassword 校验管理员或用户的用户名和密码
+func (s *ConfigStorage) AuthenticatePassword(username, password string) (config.Actor, bool) {
+	return s.config.AuthenticatePassword(username, password)
+}
+
+// LookupActor 返回用户当前的身份，用户不存在或已禁用时返回 false
+func (s *ConfigStorage) LookupActor(username string) (config.Actor, bool) {
+	return s.config.LookupActor(username)
+}
+
+// RotateForwardingUpdateToken 为路径跳转生成新的更新 token
+func (s *ConfigStorage) RotateForwardingUpdateToken(actor *config.Actor, name string) (string, error) {
+	return s.config.RotateForwardingUpdateToken(actor, name)
+}
+
+// RevokeForwardingUpdateToken 删除路径跳转的更新 token
+func (s *ConfigStorage) RevokeForwardingUpdateToken(actor *config.Actor, name string) error {
+	return s.config.RevokeForwardingUpdateToken(actor, name)
+}
+
+// RotateDomainUpdateToken 为域名映射生成新的更新 token
+func (s *ConfigStorage) RotateDomainUpdateToken(actor *config.Actor, domain string) (string, error) {
+	return s.config.RotateDomainUpdateToken(actor, domain)
+}
+
+// RevokeDomainUpdateToken 删除域名映射的更新 token
+func (s *ConfigStorage) RevokeDomainUpdateToken(actor *config.Actor, domain string) error {
+	return s.config.RevokeDomainUpdateToken(actor, domain)
+}
+
+// CanUpdateForwarding 检查 token 能否修改该路径跳转
+func (s *ConfigStorage) CanUpdateForwarding(name, token string) bool {
+	return s.config.CanUpdateForwarding(name, token)
+}
+
+// CanUpdateDomain 检查 token 能否修改该域名映射
+func (s *ConfigStorage) CanUpdateDomain(domain, token string) bool {
+	return s.config.CanUpdateDomain(domain, token)
+}
+
+// HasUser 用户是否存在
+func (s *ConfigStorage) HasUser(username string) bool {
+	return s.config.HasUser(username)
+}
+
+// AddUser 创建用户并返回其 API key
+func (s *ConfigStorage) AddUser(username string, admin bool, maxRedirectCount, maxDomainCount int) (string, error) {
+	return s.config.AddUser(username, admin, maxRedirectCount, maxDomainCount)
+}
+
+// RemoveUser 删除用户
+func (s *ConfigStorage) RemoveUser(username string) error {
+	return s.config.RemoveUser(username)
+}
+
+// ListUsers 返回用户列表
+func (s *ConfigStorage) ListUsers() []config.UserInfo {
+	return s.config.ListUsers()
+}
+
+// SetUserPassword 设置用户的登录密码
+func (s *ConfigStorage) SetUserPassword(username, password string) error {
+	return s.config.SetUserPassword(username, password)
+}
+
+// ResetUserAPIKey 重新生成用户的 API key
+func (s *ConfigStorage) ResetUserAPIKey(username string) (string, error) {
+	return s.config.ResetUserAPIKey(username)
+}
+
+// SetUserQuota 设置用户的条目数量上限
+func (s *ConfigStorage) SetUserQuota(username string, maxRedirectCount, maxDomainCount int) error {
+	return s.config.SetUserQuota(username, maxRedirectCount, maxDomainCount)
+}
+
+// SetUserDisabled 禁用或启用用户
+func (s *ConfigStorage) SetUserDisabled(username string, disabled bool) error {
+	return s.config.SetUserDisabled(username, disabled)
+}
+
+// SetForwardingOwner 修改路径跳转的所属用户
+func (s *ConfigStorage) SetForwardingOwner(name, owner string) error {
+	return s.config.SetForwardingOwner(name, owner)
+}
+
+// SetDomainOwner 修改域名映射的所属用户
+func (s *ConfigStorage) SetDomainOwner(domain, owner string) error {
+	return s.config.SetDomainOwner(domain, owner)
+}
+
+// ApplyBatch 在一个事务中应用批量更新
+func (s *ConfigStorage) ApplyBatch(ops []config.BatchOperation, opts config.BatchOptions) ([]config.BatchResult, error) {
+	return s.config.ApplyBatch(ops, opts)
+}
+
+// GetServerConfig 返回服务器配置，未配置时返回 nil
+func (s *ConfigStorage) GetServerConfig() *config.ServerConfig {
+	return s.config.Server
+}
+
+// GetWebhooksConfig 返回 webhook 配置，未配置时返回 nil
+func (s *ConfigStorage) GetWebhooksConfig() *config.WebhooksConfig {
+	if s.config.Server == nil {
+		return nil
+	}
+	return s.config.Server.Webhooks
+}
+
+// OnChange 注册配置变更监听器
+func (s *ConfigStorage) OnChange(fn func(config.ChangeEvent)) {
+	s.config.OnChange(fn)
+}
+
+// SetAdminPassword 设置管理界面的登录用户名和密码
+func (s *ConfigStorage) SetAdminPassword(username, password string) error {
+	return s.config.SetAdminPassword(username, password)
+}
+
+// ValidateAdminPassword 校验管理界面的用户名和密码
+func (s *ConfigStorage) ValidateAdminPassword(username, password string) bool {
+	return s.config.ValidateAdminPassword(username, password)
+}
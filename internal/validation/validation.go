@@ -0,0 +1,344 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxNameLength   = 64
+	maxDomainLength = 253
+	maxLabelLength  = 63
+	maxTargetLength = 2048
+)
+
+// defaultReservedNames 与路由或管理功能冲突的条目名称
+var defaultReservedNames = []string{
+	"api", "go", "admin", "healthz", "readyz", "static", "assets",
+	"login", "logout", "events", "metrics", "well-known",
+}
+
+// defaultAllowedSchemes 目标地址默认允许的协议
+var defaultAllowedSchemes = []string{"http", "https"}
+
+// TargetPolicy 目标地址校验策略
+type TargetPolicy struct {
+	AllowedSchemes []string // 允许的协议，为空时只允许 http/https
+	AllowHosts     []string // 目标主机白名单，为空时不限制；支持 "*.example.com" 和 CIDR
+	DenyHosts      []string // 目标主机黑名单，优先于白名单
+	SelfHosts      []string // 本服务自身的主机名，指向它们的目标会造成跳转循环
+}
+
+// ValidateName 校验路径跳转的条目名称
+// 只允许字母、数字、'-'、'_'、'.'，必须以字母或数字开头，且不能是保留名称
+func ValidateName(name string, reserved []string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(name) > maxNameLength {
+		return fmt.Errorf("name too long (max %d characters)", maxNameLength)
+	}
+	if !isAlnum(name[0]) {
+		return fmt.Errorf("name must start with a letter or digit")
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isAlnum(c) && c != '-' && c != '_' && c != '.' {
+			return fmt.Errorf("name contains invalid character %q", c)
+		}
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("name must not contain '..'")
+	}
+
+	for _, word := range append(defaultReservedNames, reserved...) {
+		if strings.EqualFold(name, word) {
+			return fmt.Errorf("name %q is reserved", name)
+		}
+	}
+
+	return nil
+}
+
+// NormalizeDomain 规范化并校验域名：转小写、去掉末尾的点、IDN 转 punycode，
+// 然后按 RFC 1123 检查每个 label
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return "", fmt.Errorf("domain is required")
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %v", domain, err)
+	}
+	ascii = strings.ToLower(ascii)
+
+	if err := checkHostname(ascii); err != nil {
+		return "", fmt.Errorf("invalid domain %q: %v", domain, err)
+	}
+	if _, err := netip.ParseAddr(ascii); err == nil {
+		return "", fmt.Errorf("invalid domain %q: IP addresses are not allowed", domain)
+	}
+
+	return ascii, nil
+}
+
+// ValidateTarget 校验跳转目标，支持完整 URL 或 host:port 形式
+func ValidateTarget(target string, policy TargetPolicy) error {
+	if target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if len(target) > maxTargetLength {
+		return fmt.Errorf("target too long (max %d characters)", maxTargetLength)
+	}
+	for _, r := range target {
+		if r <= ' ' || r == 0x7f {
+			return fmt.Errorf("target must not contain whitespace or control characters")
+		}
+	}
+
+	var u *url.URL
+	var err error
+	if strings.Contains(target, "://") {
+		u, err = url.Parse(target)
+		if err != nil {
+			return fmt.Errorf("invalid target URL: %v", err)
+		}
+
+		allowed := policy.AllowedSchemes
+		if len(allowed) == 0 {
+			allowed = defaultAllowedSchemes
+		}
+		if !containsFold(allowed, u.Scheme) {
+			return fmt.Errorf("target scheme %q is not allowed", u.Scheme)
+		}
+	} else {
+		// host:port 形式，跳转时按 http:// 处理
+		u, err = url.Parse("http://" + target)
+		if err != nil {
+			return fmt.Errorf("invalid target format. Expected URL or host:port")
+		}
+		if u.Port() == "" {
+			return fmt.Errorf("invalid target format. Expected URL or host:port")
+		}
+	}
+
+	if u.User != nil {
+		return fmt.Errorf("target must not contain credentials")
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("target host is required")
+	}
+
+	if port := u.Port(); port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("target port %q out of range", port)
+		}
+	}
+
+	host, err = normalizeTargetHost(host)
+	if err != nil {
+		return err
+	}
+
+	for _, self := range policy.SelfHosts {
+		if self, err := normalizeTargetHost(stripPort(self)); err == nil && self == host {
+			return fmt.Errorf("target %q points back to this server", host)
+		}
+	}
+
+	if matchHostList(policy.DenyHosts, host) {
+		return fmt.Errorf("target host %q is denied", host)
+	}
+	if len(policy.AllowHosts) > 0 && !matchHostList(policy.AllowHosts, host) {
+		return fmt.Errorf("target host %q is not in the allow list", host)
+	}
+
+	return nil
+}
+
+// normalizeTargetHost 校验目标主机：IP 字面量（含 IPv6）或合法主机名
+func normalizeTargetHost(host string) (string, error) {
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return addr.Unmap().String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid target host %q: %v", host, err)
+	}
+	ascii = strings.ToLower(ascii)
+
+	// 浏览器和解析器把 2130706433、0x7f.1、0177.0.0.1 这类主机当作 IPv4 地址，
+	// 转换为标准形式后才能按 CIDR 规则检查
+	if addr, ok, err := parseNumericIPv4(ascii); ok {
+		if err != nil {
+			return "", fmt.Errorf("invalid target host %q: %v", host, err)
+		}
+		return addr.String(), nil
+	}
+
+	if err := checkHostname(ascii); err != nil {
+		return "", fmt.Errorf("invalid target host %q: %v", host, err)
+	}
+	return ascii, nil
+}
+
+// parseNumericIPv4 按 WHATWG URL 标准解析以数字结尾的主机：最后一段是数字时整个主机按 IPv4 解析，
+// 每段可以是十进制、0x 开头的十六进制或 0 开头的八进制，段数少于 4 时最后一段占据剩余的字节
+// ok 为 false 表示不是数字形式的主机；ok 为 true 且 err 不为 nil 表示无效地址，浏览器同样会拒绝
+func parseNumericIPv4(host string) (addr netip.Addr, ok bool, err error) {
+	parts := strings.Split(host, ".")
+	last := parts[len(parts)-1]
+	if _, lastErr := parseIPv4Number(last); lastErr != nil && !isDigits(last) {
+		return netip.Addr{}, false, nil
+	}
+	if len(parts) > 4 {
+		return netip.Addr{}, true, fmt.Errorf("too many parts for an IPv4 address")
+	}
+
+	var value uint64
+	for i, part := range parts {
+		n, err := parseIPv4Number(part)
+		if err != nil {
+			return netip.Addr{}, true, err
+		}
+		if i < len(parts)-1 {
+			if n > 255 {
+				return netip.Addr{}, true, fmt.Errorf("IPv4 part %q out of range", part)
+			}
+			value |= n << (8 * (3 - i))
+			continue
+		}
+		// 最后一段占据剩余的 5-len(parts) 个字节
+		if n >= 1<<(8*(5-len(parts))) {
+			return netip.Addr{}, true, fmt.Errorf("IPv4 part %q out of range", part)
+		}
+		value |= n
+	}
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true, nil
+}
+
+// parseIPv4Number 解析 IPv4 地址中的一段：十进制、0x 开头的十六进制（可以为空）或 0 开头的八进制
+func parseIPv4Number(part string) (uint64, error) {
+	if part == "" {
+		return 0, fmt.Errorf("empty IPv4 part")
+	}
+	base := 10
+	switch {
+	case strings.HasPrefix(part, "0x"):
+		base, part = 16, part[2:]
+		if part == "" {
+			return 0, nil
+		}
+	case len(part) > 1 && part[0] == '0':
+		base, part = 8, part[1:]
+	}
+	n, err := strconv.ParseUint(part, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid IPv4 part %q", part)
+	}
+	return n, nil
+}
+
+// isDigits 字符串非空且只包含 ASCII 数字
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHostname 按 RFC 1123 检查主机名
+func checkHostname(host string) error {
+	if len(host) > maxDomainLength {
+		return fmt.Errorf("longer than %d characters", maxDomainLength)
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" {
+			return fmt.Errorf("empty label")
+		}
+		if len(label) > maxLabelLength {
+			return fmt.Errorf("label %q longer than %d characters", label, maxLabelLength)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("label %q must not start or end with '-'", label)
+		}
+		for i := 0; i < len(label); i++ {
+			if !isAlnum(label[i]) && label[i] != '-' {
+				return fmt.Errorf("label %q contains invalid character %q", label, label[i])
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchHostList 检查主机是否匹配列表中的任一规则
+// 规则可以是精确主机名、"*.example.com"（匹配所有子域名）或 CIDR
+func matchHostList(patterns []string, host string) bool {
+	addr, addrErr := netip.ParseAddr(host)
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+
+		if strings.Contains(pattern, "/") {
+			if prefix, err := netip.ParsePrefix(pattern); err == nil && addrErr == nil && prefix.Contains(addr) {
+				return true
+			}
+			continue
+		}
+
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+
+		if pattern == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
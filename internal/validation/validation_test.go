@@ -0,0 +1,63 @@
+package validation
+
+import "testing"
+
+func TestValidateTargetNumericIPv4(t *testing.T) {
+	policy := TargetPolicy{DenyHosts: []string{"127.0.0.0/8", "10.0.0.0/8", "169.254.0.0/16"}}
+
+	tests := []struct {
+		target  string
+		wantErr bool
+	}{
+		{"http://2130706433/", true},        // 127.0.0.1
+		{"http://0x7f.1/", true},            // 127.0.0.1
+		{"http://0177.0.0.1/", true},        // 127.0.0.1
+		{"http://127.1/", true},             // 127.0.0.1
+		{"http://0x7f000001/", true},        // 127.0.0.1
+		{"http://10.0x10.1/", true},         // 10.16.0.1
+		{"http://0xA9.0xFE.169.254/", true}, // 169.254.169.254
+		{"http://2130706433:8080", true},
+		{"http://1.2.3.4.5/", true},   // 段数过多
+		{"http://256.1.1.1/", true},   // 超出范围
+		{"http://1.16777216/", true},  // 最后一段超出剩余的 3 字节
+		{"http://08/", true},          // 无效的八进制
+		{"http://example.123/", true}, // 以数字结尾但不是有效地址
+		{"http://99999999999999999999999/", true},
+		{"http://3232235777/", false}, // 192.168.1.1
+		{"http://0x08080808/", false}, // 8.8.8.8
+		{"http://0x7f.example.com/", false},
+		{"http://123.example.com/", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		err := ValidateTarget(tt.target, policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTarget(%q) error = %v, want error %v", tt.target, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseNumericIPv4(t *testing.T) {
+	tests := []struct {
+		host   string
+		want   string
+		ok     bool
+		hasErr bool
+	}{
+		{"2130706433", "127.0.0.1", true, false},
+		{"0x7f.1", "127.0.0.1", true, false},
+		{"0177.0.0.01", "127.0.0.1", true, false},
+		{"192.168.0x1", "192.168.0.1", true, false},
+		{"1.2.3.0x", "1.2.3.0", true, false},
+		{"4294967295", "255.255.255.255", true, false},
+		{"4294967296", "", true, true},
+		{"example.com", "", false, false},
+		{"0x7f.example", "", false, false},
+	}
+	for _, tt := range tests {
+		addr, ok, err := parseNumericIPv4(tt.host)
+		if ok != tt.ok || (err != nil) != tt.hasErr || (err == nil && ok && addr.String() != tt.want) {
+			t.Errorf("parseNumericIPv4(%q) = %v, %v, %v; want %q, %v, error %v", tt.host, addr, ok, err, tt.want, tt.ok, tt.hasErr)
+		}
+	}
+}
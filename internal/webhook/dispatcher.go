@@ -0,0 +1,556 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"redirect_helper/internal/config"
+	"redirect_helper/pkg/utils"
+)
+
+// 请求头
+const (
+	HeaderEvent     = "X-Redirect-Helper-Event"
+	HeaderDelivery  = "X-Redirect-Helper-Delivery"
+	HeaderTimestamp = "X-Redirect-Helper-Timestamp"
+	// HeaderSignature 格式为 "sha256=<hex>"，签名内容为 "<timestamp>.<body>"
+	HeaderSignature = "X-Redirect-Helper-Signature"
+)
+
+const (
+	defaultQueueSize   = 1000
+	defaultMaxAttempts = 8
+	defaultTimeout     = 10 * time.Second
+	maxLogEntries      = 200
+
+	baseBackoff = 2 * time.Second
+	maxBackoff  = 10 * time.Minute
+)
+
+// 投递日志状态
+const (
+	StatusDelivered = "delivered"
+	StatusRetrying  = "retrying"
+	StatusFailed    = "failed"
+	StatusDropped   = "dropped"
+)
+
+// Payload 投递给订阅方的 JSON 内容
+type Payload struct {
+	ID    string             `json:"id"`
+	Event string             `json:"event"`
+	Time  time.Time          `json:"time"`
+	Name  string             `json:"name"`
+	Old   *config.EntryState `json:"old,omitempty"`
+	New   *config.EntryState `json:"new,omitempty"`
+}
+
+// Delivery 队列中待投递的一条通知
+type Delivery struct {
+	ID           string          `json:"id"`
+	Subscription string          `json:"subscription"`
+	Event        string          `json:"event"`
+	Body         json.RawMessage `json:"body"`
+	Attempts     int             `json:"attempts"`
+	NextAttempt  time.Time       `json:"next_attempt"`
+	CreatedAt    time.Time       `json:"created_at"`
+	LastError    string          `json:"last_error,omitempty"`
+}
+
+// LogEntry 一次投递尝试的记录
+type LogEntry struct {
+	DeliveryID   string    `json:"delivery_id"`
+	Subscription string    `json:"subscription"`
+	Event        string    `json:"event"`
+	URL          string    `json:"url"`
+	Attempt      int       `json:"attempt"`
+	Status       string    `json:"status"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	Time         time.Time `json:"time"`
+}
+
+// SubscriptionInfo 订阅的公开信息，不包含签名密钥
+type SubscriptionInfo struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events,omitempty"`
+	Signed   bool     `json:"signed"`
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// Dispatcher 接收配置变更事件，按订阅过滤后异步投递
+// 队列有上限并持久化到磁盘，失败的投递按指数退避重试
+type Dispatcher struct {
+	subscriptions []config.WebhookSubscription
+	queueFile     string // 为空时只在内存中排队
+	queueSize     int
+	maxAttempts   int
+	client        *http.Client
+
+	mu     sync.Mutex
+	queue  []*Delivery
+	logs   []LogEntry
+	dirty  bool
+	wake   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// NewDispatcher 按配置创建投递器；persist 为 true 时队列和日志保存到 QueueFile
+func NewDispatcher(cfg *config.WebhooksConfig, persist bool) *Dispatcher {
+	d := &Dispatcher{
+		queueSize:   defaultQueueSize,
+		maxAttempts: defaultMaxAttempts,
+		client:      &http.Client{Timeout: defaultTimeout},
+		wake:        make(chan struct{}, 1),
+	}
+	if cfg == nil {
+		return d
+	}
+
+	if cfg.QueueSize > 0 {
+		d.queueSize = cfg.QueueSize
+	}
+	if cfg.MaxAttempts > 0 {
+		d.maxAttempts = cfg.MaxAttempts
+	}
+	if cfg.Timeout > 0 {
+		d.client.Timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if persist {
+		d.queueFile = cfg.QueueFile
+		if d.queueFile == "" {
+			d.queueFile = defaultQueueFile(config.GetConfigPath())
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, sub := range cfg.Subscriptions {
+		if sub.Name == "" {
+			sub.Name = sub.URL
+		}
+		if err := validateSubscription(sub); err != nil {
+			log.Printf("Ignoring webhook %q: %v", sub.Name, err)
+			continue
+		}
+		if seen[sub.Name] {
+			log.Printf("Ignoring webhook %q: duplicate name", sub.Name)
+			continue
+		}
+		seen[sub.Name] = true
+		d.subscriptions = append(d.subscriptions, sub)
+	}
+
+	return d
+}
+
+// defaultQueueFile 配置文件旁的 <name>.webhooks.json
+func defaultQueueFile(configPath string) string {
+	ext := filepath.Ext(configPath)
+	return configPath[:len(configPath)-len(ext)] + ".webhooks.json"
+}
+
+// validateSubscription 检查订阅地址和事件过滤条件
+func validateSubscription(sub config.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", sub.URL)
+	}
+	for _, pattern := range sub.Events {
+		if !validEventPattern(pattern) {
+			return fmt.Errorf("unknown event %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Enabled 是否配置了可用的订阅
+func (d *Dispatcher) Enabled() bool {
+	return len(d.subscriptions) > 0
+}
+
+// Notify 将事件加入匹配订阅的投递队列；作为 config.OnChange 监听器调用，不做任何 I/O
+func (d *Dispatcher) Notify(event config.ChangeEvent) {
+	for _, sub := range d.subscriptions {
+		if sub.Disabled || !matchEvent(sub.Events, event.Type) {
+			continue
+		}
+
+		id, err := utils.GenerateToken(32)
+		if err != nil {
+			log.Printf("Failed to generate webhook delivery id: %v", err)
+			return
+		}
+		body, err := json.Marshal(Payload{
+			ID:    id,
+			Event: event.Type,
+			Time:  event.Time,
+			Name:  event.Name,
+			Old:   event.Old,
+			New:   event.New,
+		})
+		if err != nil {
+			log.Printf("Failed to encode webhook payload: %v", err)
+			return
+		}
+
+		d.enqueue(&Delivery{
+			ID:           id,
+			Subscription: sub.Name,
+			Event:        event.Type,
+			Body:         body,
+			NextAttempt:  event.Time,
+			CreatedAt:    event.Time,
+		})
+	}
+}
+
+// enqueue 加入队列，超过上限时丢弃最旧的投递
+func (d *Dispatcher) enqueue(delivery *Delivery) {
+	d.mu.Lock()
+	d.queue = append(d.queue, delivery)
+	for len(d.queue) > d.queueSize {
+		dropped := d.queue[0]
+		d.queue = d.queue[1:]
+		d.addLog(LogEntry{
+			DeliveryID:   dropped.ID,
+			Subscription: dropped.Subscription,
+			Event:        dropped.Event,
+			Attempt:      dropped.Attempts,
+			Status:       StatusDropped,
+			Error:        "queue full",
+			Time:         time.Now(),
+		})
+	}
+	d.dirty = true
+	d.mu.Unlock()
+
+	d.signal()
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start 加载持久化的队列并启动投递协程
+func (d *Dispatcher) Start() error {
+	if d.queueFile != "" {
+		if err := d.load(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run(ctx)
+	return nil
+}
+
+// Close 停止投递协程并保存未完成的队列
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d.stop == nil {
+		return nil
+	}
+
+	close(d.stop)
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		// 中断进行中的请求，未完成的投递保留在队列中
+		d.cancel()
+		<-d.done
+	}
+	d.cancel()
+
+	return d.persist()
+}
+
+// Drain 等待队列清空（包括重试），ctx 结束时返回剩余的投递数量
+func (d *Dispatcher) Drain(ctx context.Context) int {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		d.mu.Lock()
+		pending := len(d.queue)
+		d.mu.Unlock()
+
+		if pending == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return pending
+		case <-ticker.C:
+		}
+	}
+}
+
+// run 投递循环：依次处理到期的投递，没有到期投递时等待新事件或下次重试时间
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+
+	for {
+		if err := d.persist(); err != nil {
+			log.Printf("Failed to save webhook queue: %v", err)
+		}
+
+		delivery, wait := d.nextDue()
+		if delivery != nil {
+			d.attempt(ctx, delivery)
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-d.stop:
+			timer.Stop()
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// nextDue 返回最早到期的投递；没有到期投递时返回需要等待的时间
+func (d *Dispatcher) nextDue() (*Delivery, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Hour
+	var next *Delivery
+	for _, delivery := range d.queue {
+		if next == nil || delivery.NextAttempt.Before(next.NextAttempt) {
+			next = delivery
+		}
+	}
+	if next == nil {
+		return nil, wait
+	}
+	if until := next.NextAttempt.Sub(now); until > 0 {
+		return nil, until
+	}
+	return next, 0
+}
+
+// attempt 发送一次请求并根据结果完成、重试或放弃该投递
+func (d *Dispatcher) attempt(ctx context.Context, delivery *Delivery) {
+	sub, ok := d.subscription(delivery.Subscription)
+	if !ok || sub.Disabled {
+		// 订阅已从配置中删除或被禁用
+		d.finish(delivery, LogEntry{Status: StatusDropped, Error: "subscription removed or disabled"})
+		return
+	}
+
+	start := time.Now()
+	statusCode, err := d.send(ctx, sub, delivery)
+	entry := LogEntry{
+		URL:        sub.URL,
+		StatusCode: statusCode,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	d.mu.Lock()
+	delivery.Attempts++
+	entry.Attempt = delivery.Attempts
+	d.mu.Unlock()
+
+	if err == nil {
+		entry.Status = StatusDelivered
+		d.finish(delivery, entry)
+		return
+	}
+
+	entry.Error = err.Error()
+	if ctx.Err() != nil {
+		// 关闭时被中断，不计入尝试次数，下次启动后重新投递
+		d.mu.Lock()
+		delivery.Attempts--
+		d.mu.Unlock()
+		return
+	}
+	if !retryable(statusCode) || delivery.Attempts >= d.maxAttempts {
+		entry.Status = StatusFailed
+		d.finish(delivery, entry)
+		return
+	}
+
+	entry.Status = StatusRetrying
+	d.mu.Lock()
+	delivery.LastError = entry.Error
+	delivery.NextAttempt = time.Now().Add(backoff(delivery.Attempts))
+	d.fillLog(&entry, delivery)
+	d.addLog(entry)
+	d.dirty = true
+	d.mu.Unlock()
+}
+
+// send 发送带签名的 POST 请求，非 2xx 响应视为失败
+func (d *Dispatcher) send(ctx context.Context, sub config.WebhookSubscription, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "redirect_helper-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if sub.Secret != "" {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, timestamp, delivery.Body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign 计算 "<timestamp>.<body>" 的 HMAC-SHA256，接收方可用同样方式校验
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable 网络错误、超时、429 和 5xx 会重试，其它 4xx 视为订阅方拒绝
+func retryable(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
+
+// backoff 第 n 次失败后的等待时间：2s、4s、8s……上限 10 分钟，附加最多 10% 的随机抖动
+func backoff(attempts int) time.Duration {
+	wait := maxBackoff
+	if attempts < 20 {
+		wait = min(baseBackoff<<(attempts-1), maxBackoff)
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/10+1))
+}
+
+// finish 从队列中移除投递并记录最终结果
+func (d *Dispatcher) finish(delivery *Delivery, entry LogEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, queued := range d.queue {
+		if queued == delivery {
+			d.queue = append(d.queue[:i], d.queue[i+1:]...)
+			break
+		}
+	}
+	d.fillLog(&entry, delivery)
+	d.addLog(entry)
+	d.dirty = true
+}
+
+func (d *Dispatcher) fillLog(entry *LogEntry, delivery *Delivery) {
+	entry.DeliveryID = delivery.ID
+	entry.Subscription = delivery.Subscription
+	entry.Event = delivery.Event
+	entry.Time = time.Now()
+	if entry.Attempt == 0 {
+		entry.Attempt = delivery.Attempts
+	}
+}
+
+// addLog 追加日志，只保留最近的记录，调用方需持有锁
+func (d *Dispatcher) addLog(entry LogEntry) {
+	d.logs = append(d.logs, entry)
+	if len(d.logs) > maxLogEntries {
+		d.logs = d.logs[len(d.logs)-maxLogEntries:]
+	}
+}
+
+func (d *Dispatcher) subscription(name string) (config.WebhookSubscription, bool) {
+	for _, sub := range d.subscriptions {
+		if sub.Name == name {
+			return sub, true
+		}
+	}
+	return config.WebhookSubscription{}, false
+}
+
+// Subscriptions 返回订阅列表（隐藏签名密钥）
+func (d *Dispatcher) Subscriptions() []SubscriptionInfo {
+	result := make([]SubscriptionInfo, 0, len(d.subscriptions))
+	for _, sub := range d.subscriptions {
+		result = append(result, SubscriptionInfo{
+			Name:     sub.Name,
+			URL:      sub.URL,
+			Events:   sub.Events,
+			Signed:   sub.Secret != "",
+			Disabled: sub.Disabled,
+		})
+	}
+	return result
+}
+
+// Logs 返回最近的投递日志，最新的在前；limit <= 0 时返回全部
+func (d *Dispatcher) Logs(limit int) []LogEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if limit <= 0 || limit > len(d.logs) {
+		limit = len(d.logs)
+	}
+	result := make([]LogEntry, 0, limit)
+	for i := len(d.logs) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, d.logs[i])
+	}
+	return result
+}
+
+// Pending 返回队列中等待投递的通知
+func (d *Dispatcher) Pending() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]Delivery, 0, len(d.queue))
+	for _, delivery := range d.queue {
+		result = append(result, *delivery)
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package webhook
+
+import (
+	"slices"
+	"strings"
+
+	"redirect_helper/internal/config"
+)
+
+// matchEvent 检查事件是否符合订阅的过滤条件
+// 支持完整事件名、"forwarding.*" 这样的前缀通配和 "*"；过滤条件为空时匹配全部事件
+func matchEvent(patterns []string, event string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == event {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, ".*"); ok && strings.HasPrefix(event, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// validEventPattern 过滤条件必须能匹配至少一种已知事件，避免拼写错误导致静默收不到通知
+func validEventPattern(pattern string) bool {
+	return slices.ContainsFunc(config.EventTypes, func(event string) bool {
+		return matchEvent([]string{pattern}, event)
+	})
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// queueState 持久化到磁盘的队列和投递日志
+type queueState struct {
+	Queue []*Delivery `json:"queue"`
+	Logs  []LogEntry  `json:"logs"`
+}
+
+// load 读取上次退出时未完成的投递，文件不存在时视为空队列
+func (d *Dispatcher) load() error {
+	data, err := os.ReadFile(d.queueFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read webhook queue: %v", err)
+	}
+
+	var state queueState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse webhook queue: %v", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 新事件可能已经在加载前入队，旧的投递排在前面
+	d.queue = append(state.Queue, d.queue...)
+	if len(d.queue) > d.queueSize {
+		d.queue = d.queue[len(d.queue)-d.queueSize:]
+	}
+	d.logs = append(state.Logs, d.logs...)
+	if len(d.logs) > maxLogEntries {
+		d.logs = d.logs[len(d.logs)-maxLogEntries:]
+	}
+	return nil
+}
+
+// persist 队列有变化时写入磁盘，与配置文件一样先写临时文件再重命名
+func (d *Dispatcher) persist() error {
+	if d.queueFile == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	// 不缩进，保证请求体在重启前后逐字节一致
+	data, err := json.Marshal(queueState{Queue: d.queue, Logs: d.logs})
+	d.dirty = false
+	d.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook queue: %v", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(d.queueFile), filepath.Base(d.queueFile)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write webhook queue: %v", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write webhook queue: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write webhook queue: %v", err)
+	}
+	// 队列中包含条目目标，权限与配置文件保持一致
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("failed to write webhook queue: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, d.queueFile); err != nil {
+		return fmt.Errorf("failed to replace webhook queue: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package wol 发送 Wake-on-LAN 魔术包
+package wol
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// DefaultBroadcast 未配置广播地址时使用的目标，端口 9 为 discard 服务，是 WoL 的惯例端口
+const DefaultBroadcast = "255.255.255.255:9"
+
+// ParseMAC 解析 48 位 MAC 地址，支持 00:11:22:33:44:55、00-11-22-33-44-55 和 0011.2233.4455 格式
+func ParseMAC(value string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address %q", value)
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q: Wake-on-LAN requires a 48-bit address", value)
+	}
+	return mac, nil
+}
+
+// NormalizeBroadcast 校验广播地址，只写 IP 时补上端口 9
+// 只接受 255.255.255.255 和本机所在 IPv4 网段的定向广播地址，端口只能是 WoL 惯用的 7 或 9：
+// 访问者不需要认证就能触发发送，不能让条目所属用户借此向任意内网主机和端口发送 UDP 包
+func NormalizeBroadcast(value string) (string, error) {
+	if value == "" {
+		return DefaultBroadcast, nil
+	}
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		host, port = value, "9"
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return "", fmt.Errorf("invalid broadcast address %q, expected an IPv4 address such as 192.168.1.255", value)
+	}
+	if port != "7" && port != "9" {
+		return "", fmt.Errorf("invalid broadcast port %q, expected 7 or 9", port)
+	}
+	if !ip.Equal(net.IPv4bcast) {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return "", fmt.Errorf("failed to list local networks: %v", err)
+		}
+		if !IsBroadcast(ip, addrs) {
+			return "", fmt.Errorf("%s is not the broadcast address of a local network, use 255.255.255.255 or e.g. 192.168.1.255", host)
+		}
+	}
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// IsBroadcast ip 是否为 addrs 中某个 IPv4 网段的定向广播地址（主机位全为 1），/31 和 /32 网段没有广播地址
+func IsBroadcast(ip net.IP, addrs []net.Addr) bool {
+	ip = ip.To4()
+	if ip == nil {
+		return false
+	}
+	for _, addr := range addrs {
+		network, ok := addr.(*net.IPNet)
+		if !ok || network.IP.To4() == nil {
+			continue
+		}
+		ones, bits := network.Mask.Size()
+		if bits != 32 || ones > 30 {
+			continue
+		}
+		broadcast := make(net.IP, 4)
+		for i, b := range network.IP.To4() {
+			broadcast[i] = b | ^network.Mask[len(network.Mask)-4+i]
+		}
+		if broadcast.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// MagicPacket 6 个 0xFF 后接 16 次 MAC 地址
+func MagicPacket(mac net.HardwareAddr) []byte {
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, mac...)
+	}
+	return packet
+}
+
+// Send 向广播地址发送魔术包
+func Send(macValue, broadcast string) error {
+	mac, err := ParseMAC(macValue)
+	if err != nil {
+		return err
+	}
+	addr, err := NormalizeBroadcast(broadcast)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to open socket for %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(MagicPacket(mac)); err != nil {
+		return fmt.Errorf("failed to send magic packet to %s: %v", addr, err)
+	}
+	return nil
+}
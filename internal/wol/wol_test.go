@@ -0,0 +1,74 @@
+package wol
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsBroadcast(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.IPv4(192, 168, 1, 10), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.IPv4(10, 20, 0, 1), Mask: net.CIDRMask(16, 32)},
+		&net.IPNet{IP: net.IPv4(172, 16, 0, 1), Mask: net.CIDRMask(32, 32)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)},
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"192.168.1.255", true},
+		{"10.20.255.255", true},
+		{"192.168.1.10", false},
+		{"192.168.2.255", false},
+		{"10.20.0.255", false},
+		{"172.16.0.1", false},
+		{"2001:db8::ffff", false},
+	}
+	for _, tt := range tests {
+		if got := IsBroadcast(net.ParseIP(tt.ip), addrs); got != tt.want {
+			t.Errorf("IsBroadcast(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeBroadcast(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", DefaultBroadcast, false},
+		{"255.255.255.255", "255.255.255.255:9", false},
+		{"255.255.255.255:7", "255.255.255.255:7", false},
+		{"255.255.255.255:53", "", true},
+		{"192.168.1.2", "", true}, // 主机位不可能全为 1
+		{"[::1]:9", "", true},
+		{"nas.local", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeBroadcast(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeBroadcast(%q) = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMagicPacket(t *testing.T) {
+	mac, err := ParseMAC("00:11:22:33:44:55")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := MagicPacket(mac)
+	if len(packet) != 102 {
+		t.Fatalf("len = %d, want 102", len(packet))
+	}
+	for i := 0; i < 6; i++ {
+		if packet[i] != 0xff {
+			t.Fatalf("byte %d = %#x, want 0xff", i, packet[i])
+		}
+	}
+	if net.HardwareAddr(packet[96:]).String() != "00:11:22:33:44:55" {
+		t.Errorf("last repetition = %s", net.HardwareAddr(packet[96:]))
+	}
+}